@@ -0,0 +1,293 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"homeland/models"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultWindow = 30 * 24 * time.Hour
+	// maxBuckets bounds the time series, e.g. about three years by day.
+	maxBuckets    = 1100
+	defaultTop    = 10
+	maxTop        = 50
+	intervalDay   = "day"
+	intervalWeek  = "week"
+	intervalMonth = "month"
+)
+
+var (
+	ErrInvalidRange    = errors.New("from and to must be RFC3339 or YYYY-MM-DD with from before to")
+	ErrInvalidInterval = errors.New("interval must be day, week or month")
+	ErrRangeTooLarge   = errors.New("range has too many intervals; use a coarser interval")
+	ErrInvalidTop      = errors.New("top must be between 1 and 50")
+)
+
+// Filter selects the records an analytics query aggregates. Department is
+// empty when the caller may see every department.
+type Filter struct {
+	From       time.Time
+	To         time.Time
+	Interval   string
+	Department string
+	Top        int
+}
+
+// ParseFilter reads from, to, interval and top. The range defaults to the 30
+// days before now.
+func ParseFilter(values url.Values, now time.Time) (Filter, error) {
+	f := Filter{To: now, Interval: intervalDay, Top: defaultTop}
+
+	if value := values.Get("to"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			return Filter{}, ErrInvalidRange
+		}
+		f.To = t
+	}
+	f.From = f.To.Add(-defaultWindow)
+	if value := values.Get("from"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			return Filter{}, ErrInvalidRange
+		}
+		f.From = t
+	}
+	if !f.From.Before(f.To) {
+		return Filter{}, ErrInvalidRange
+	}
+
+	if value := values.Get("interval"); value != "" {
+		switch value {
+		case intervalDay, intervalWeek, intervalMonth:
+			f.Interval = value
+		default:
+			return Filter{}, ErrInvalidInterval
+		}
+	}
+	if len(periods(f)) > maxBuckets {
+		return Filter{}, ErrRangeTooLarge
+	}
+
+	if value := values.Get("top"); value != "" {
+		top, err := strconv.Atoi(value)
+		if err != nil || top < 1 || top > maxTop {
+			return Filter{}, ErrInvalidTop
+		}
+		f.Top = top
+	}
+	return f, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// truncate mirrors PostgreSQL's date_trunc; weeks start on Monday.
+func truncate(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case intervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case intervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func next(t time.Time, interval string) time.Time {
+	switch interval {
+	case intervalMonth:
+		return t.AddDate(0, 1, 0)
+	case intervalWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// periods lists the start of every interval overlapping the range.
+func periods(f Filter) []time.Time {
+	var out []time.Time
+	for p := truncate(f.From, f.Interval); p.Before(f.To) && len(out) <= maxBuckets; p = next(p, f.Interval) {
+		out = append(out, p)
+	}
+	return out
+}
+
+// Point is the number of records created in the interval starting at Period.
+type Point struct {
+	Period time.Time `bun:"period" json:"period"`
+	Count  int       `bun:"count" json:"count"`
+}
+
+// Bucket is the number of records sharing Key.
+type Bucket struct {
+	Key   string `bun:"key" json:"key"`
+	Count int    `bun:"count" json:"count"`
+}
+
+type IncidentStats struct {
+	Total                 int      `json:"total"`
+	AveragePeopleInvolved float64  `json:"average_people_involved"`
+	Series                []Point  `json:"series"`
+	ByType                []Bucket `json:"by_type"`
+	BySeverity            []Bucket `json:"by_severity"`
+	ByDepartment          []Bucket `json:"by_department"`
+	ByStatus              []Bucket `json:"by_status"`
+	TopLocations          []Bucket `json:"top_locations"`
+}
+
+type ReportStats struct {
+	Total        int      `json:"total"`
+	Series       []Point  `json:"series"`
+	ByType       []Bucket `json:"by_type"`
+	BySeverity   []Bucket `json:"by_severity"`
+	ByDepartment []Bucket `json:"by_department"`
+	ByStatus     []Bucket `json:"by_status"`
+	TopLocations []Bucket `json:"top_locations"`
+}
+
+// incidents selects the incidents in f. A department sees the incidents it
+// owns and those dispatched to it.
+func incidents(db bun.IDB, f Filter) *bun.SelectQuery {
+	q := db.NewSelect().
+		Model((*models.Incident)(nil)).
+		Where("incident.created_at >= ?", f.From).
+		Where("incident.created_at < ?", f.To)
+	if f.Department != "" {
+		q = q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("incident.department = ?", f.Department).
+				WhereOr("EXISTS (SELECT 1 FROM dispatches AS d WHERE d.incident_id = incident.id AND d.department = ?)", f.Department)
+		})
+	}
+	return q
+}
+
+func reports(db bun.IDB, f Filter) *bun.SelectQuery {
+	q := db.NewSelect().
+		Model((*models.Report)(nil)).
+		Where("report.date_reported >= ?", f.From).
+		Where("report.date_reported < ?", f.To)
+	if f.Department != "" {
+		q = q.Where("report.department = ?", f.Department)
+	}
+	return q
+}
+
+func breakdown(ctx context.Context, q *bun.SelectQuery, expr string, limit int) ([]Bucket, error) {
+	buckets := make([]Bucket, 0)
+	q = q.ColumnExpr(expr + " AS key").
+		ColumnExpr("count(*) AS count").
+		GroupExpr(expr).
+		OrderExpr("count DESC, key")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Scan(ctx, &buckets)
+	return buckets, err
+}
+
+func series(ctx context.Context, q *bun.SelectQuery, column string, f Filter) ([]Point, error) {
+	var counted []Point
+	err := q.ColumnExpr("date_trunc(?, "+column+") AS period", f.Interval).
+		ColumnExpr("count(*) AS count").
+		GroupExpr("period").
+		Scan(ctx, &counted)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[time.Time]int, len(counted))
+	for _, p := range counted {
+		counts[p.Period.UTC()] = p.Count
+	}
+
+	// Intervals without records are reported as zero so charts have no gaps.
+	out := make([]Point, 0)
+	for _, period := range periods(f) {
+		out = append(out, Point{Period: period, Count: counts[period]})
+	}
+	return out, nil
+}
+
+// Incidents aggregates the incidents created in f.
+func Incidents(ctx context.Context, db bun.IDB, f Filter) (*IncidentStats, error) {
+	stats := &IncidentStats{}
+
+	err := incidents(db, f).
+		ColumnExpr("count(*)").
+		ColumnExpr("coalesce(avg(incident.people_involved), 0)").
+		Scan(ctx, &stats.Total, &stats.AveragePeopleInvolved)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Series, err = series(ctx, incidents(db, f), "incident.created_at", f); err != nil {
+		return nil, err
+	}
+	if stats.ByType, err = breakdown(ctx, incidents(db, f), "incident.incident_type", 0); err != nil {
+		return nil, err
+	}
+	if stats.BySeverity, err = breakdown(ctx, incidents(db, f), "incident.severity", 0); err != nil {
+		return nil, err
+	}
+	if stats.ByDepartment, err = breakdown(ctx, incidents(db, f), "incident.department", 0); err != nil {
+		return nil, err
+	}
+	if stats.ByStatus, err = breakdown(ctx, incidents(db, f), "incident.status", 0); err != nil {
+		return nil, err
+	}
+	// Locations are free text; the local government area is preferred when
+	// the incident was geolocated.
+	location := "coalesce(nullif(incident.lga, ''), initcap(trim(incident.caller_location)))"
+	if stats.TopLocations, err = breakdown(ctx, incidents(db, f), location, f.Top); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Reports aggregates the fire, EMS and AVS reports filed in f.
+func Reports(ctx context.Context, db bun.IDB, f Filter) (*ReportStats, error) {
+	stats := &ReportStats{}
+
+	total, err := reports(db, f).Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Total = total
+
+	if stats.Series, err = series(ctx, reports(db, f), "report.date_reported", f); err != nil {
+		return nil, err
+	}
+	if stats.ByType, err = breakdown(ctx, reports(db, f), "report.report_type", 0); err != nil {
+		return nil, err
+	}
+	if stats.BySeverity, err = breakdown(ctx, reports(db, f), "report.severity", 0); err != nil {
+		return nil, err
+	}
+	if stats.ByDepartment, err = breakdown(ctx, reports(db, f), "report.department", 0); err != nil {
+		return nil, err
+	}
+	if stats.ByStatus, err = breakdown(ctx, reports(db, f), "report.status", 0); err != nil {
+		return nil, err
+	}
+	location := "coalesce(nullif(report.lga, ''), initcap(trim(report.location)))"
+	if stats.TopLocations, err = breakdown(ctx, reports(db, f), location, f.Top); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -2,18 +2,29 @@ package api
 
 import (
 	"homeland/config"
-	"homeland/handlers/auth"
+	"homeland/handlers/admin"
 	"homeland/handlers/staff"
 	"homeland/middleware"
+	"homeland/rbac"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func RegisterAdminRoutes(r chi.Router, db *bun.DB, cfg *config.Config) {
+func RegisterAdminRoutes(r chi.Router, db *bun.DB, cfg *config.Config, policy *rbac.Engine) {
 	r.Route("/admin", func(r chi.Router) {
-		r.Use(middleware.RoleAuthorization("Admin", "SSA", "Director"))
-		r.Post("/onboard", staff.OnboardStaffHandler(db, cfg))
-		r.Post("/change-password", auth.ChangePasswordHandler(db, cfg))
+		r.With(middleware.RequirePermission(policy, "staff", "onboard")).Post("/onboard", staff.OnboardStaffHandler(db, cfg))
+		r.With(middleware.RequirePermission(policy, "audit", "read")).Get("/audit", admin.GetAuditEvents(db))
+		r.With(middleware.RequirePermission(policy, "audit", "read")).Get("/login-attempts", admin.GetLoginAttempts(db))
+		r.With(middleware.RequirePermission(policy, "staff", "unlock")).Post("/staff/{id}/unlock", admin.UnlockStaff(db))
+		r.With(middleware.RequirePermission(policy, "staff", "reset_mfa")).Delete("/staff/{id}/mfa", admin.ResetStaffMFA(db))
+		r.With(middleware.RequirePermission(policy, "jobs", "read")).Get("/jobs", admin.GetJobs(db))
+		r.With(middleware.RequirePermission(policy, "jobs", "retry")).Post("/jobs/{id}/retry", admin.RetryJob(db))
+
+		r.Route("/staff/{id}/sessions", func(r chi.Router) {
+			r.With(middleware.RequirePermission(policy, "sessions", "read")).Get("/", admin.GetStaffSessions(db))
+			r.With(middleware.RequirePermission(policy, "sessions", "revoke")).Delete("/", admin.RevokeAllStaffSessions(db))
+			r.With(middleware.RequirePermission(policy, "sessions", "revoke")).Delete("/{sessionID}", admin.RevokeStaffSession(db))
+		})
 	})
 }
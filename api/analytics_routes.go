@@ -0,0 +1,17 @@
+package api
+
+import (
+	"homeland/handlers/analytics"
+	"homeland/middleware"
+	"homeland/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func RegisterAnalyticsRoutes(r chi.Router, db *bun.DB, policy *rbac.Engine) {
+	r.Route("/analytics", func(r chi.Router) {
+		r.With(middleware.RequirePermission(policy, "analytics", "read")).Get("/incidents", analytics.GetIncidentAnalytics(db))
+		r.With(middleware.RequirePermission(policy, "analytics", "read")).Get("/reports", analytics.GetReportAnalytics(db))
+	})
+}
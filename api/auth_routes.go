@@ -1,15 +1,35 @@
 package api
 
 import (
+	"time"
+
 	"homeland/config"
 	"homeland/handlers/auth"
+	"homeland/mfa"
+	"homeland/middleware"
+	"homeland/ratelimit"
+	"homeland/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func RegisterAuthRoutes(r chi.Router, db *bun.DB, cfg *config.Config) {
-	r.Post("/login", auth.LoginHandler(db, cfg))
-	r.Post("/refresh", auth.RefreshTokenHandler(cfg))
+func RegisterAuthRoutes(r chi.Router, db *bun.DB, cfg *config.Config, mfaService *mfa.Service) {
+	loginLimiter := ratelimit.New(10, time.Minute)
+	r.With(middleware.RateLimit(loginLimiter, utils.ClientIP)).Post("/login", auth.LoginHandler(db, cfg))
+	r.With(middleware.RateLimit(loginLimiter, utils.ClientIP), middleware.AuditRedact("code")).Post("/login/mfa", auth.LoginMFAHandler(db, cfg, mfaService))
+	r.Post("/refresh", auth.RefreshTokenHandler(db, cfg))
 	r.Get("/auth", auth.AuthCheckHandler(db, cfg))
+
+	resetLimiter := ratelimit.New(3, time.Hour)
+	r.Route("/password", func(r chi.Router) {
+		r.Use(middleware.RateLimit(ratelimit.New(20, time.Hour), utils.ClientIP))
+		r.Post("/forgot", auth.ForgotPasswordHandler(db, cfg, resetLimiter))
+		r.Post("/reset", auth.ResetPasswordHandler(db, cfg))
+	})
+}
+
+func RegisterSessionRoutes(r chi.Router, db *bun.DB) {
+	r.Post("/logout", auth.LogoutHandler(db))
+	r.Post("/logout/all", auth.LogoutAllHandler(db))
 }
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"time"
+
+	"homeland/config"
+	"homeland/handlers/workplace"
+	"homeland/middleware"
+	"homeland/models"
+	"homeland/ratelimit"
+	"homeland/rbac"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func RegisterCalendarRoutes(r chi.Router, db *bun.DB, cfg *config.Config, policy *rbac.Engine) {
+	r.Route("/calendars", func(r chi.Router) {
+		r.Use(middleware.RequirePermission(policy, "appointments", "read"))
+		r.Get("/staff/{target}.ics", workplace.GetCalendar(db, cfg, models.FeedScopeStaff))
+		r.Get("/departments/{target}.ics", workplace.GetCalendar(db, cfg, models.FeedScopeDepartment))
+		r.Post("/feeds", workplace.CreateCalendarFeed(db))
+		r.Get("/feeds", workplace.GetCalendarFeeds(db))
+		r.Delete("/feeds/{id}", workplace.RevokeCalendarFeed(db))
+	})
+}
+
+// RegisterCalendarFeedRoutes serves subscribed calendars. Calendar clients
+// cannot send a bearer token, so these routes sit outside the authenticated
+// group and are reached with the feed's secret token instead.
+func RegisterCalendarFeedRoutes(r chi.Router, db *bun.DB, cfg *config.Config, policy *rbac.Engine) {
+	feedLimiter := ratelimit.New(120, time.Minute)
+	r.With(middleware.RateLimit(feedLimiter, utils.ClientIP)).Get("/calendars/feed/{token}.ics", workplace.GetFeedCalendar(db, cfg, policy))
+}
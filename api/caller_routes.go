@@ -0,0 +1,19 @@
+package api
+
+import (
+	"homeland/handlers/caller"
+	"homeland/middleware"
+	"homeland/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func RegisterCallerRoutes(r chi.Router, db *bun.DB, policy *rbac.Engine) {
+	r.Route("/callers", func(r chi.Router) {
+		r.With(middleware.RequirePermission(policy, "callers", "read")).Get("/", caller.GetCallers(db))
+		r.With(middleware.RequirePermission(policy, "callers", "read")).Get("/{id}", caller.GetCaller(db))
+		r.With(middleware.RequirePermission(policy, "callers", "read")).Get("/{id}/incidents", caller.GetCallerIncidents(db))
+		r.With(middleware.RequirePermission(policy, "callers", "flag")).Put("/{id}/flags", caller.FlagCaller(db))
+	})
+}
@@ -1,16 +1,33 @@
 package api
 
 import (
+	"homeland/config"
+	"homeland/events"
 	"homeland/handlers/incident"
+	"homeland/middleware"
+	"homeland/rbac"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func RegisterIncidentRoutes(r chi.Router, db *bun.DB) {
-	r.Post("/incidents", incident.CreateIncidentHandler(db))
-	r.Get("/incidents", incident.GetIncidents(db))
-	r.Get("/incidents/{id}", incident.GetIncidentByID(db))
-	r.Put("/incidents/{id}", incident.UpdateIncident(db))
-	r.Delete("/incidents/{id}", incident.DeleteIncident(db))
+func RegisterIncidentRoutes(r chi.Router, db *bun.DB, cfg *config.Config, broker *events.Broker, policy *rbac.Engine) {
+	r.With(middleware.RequirePermission(policy, "incidents", "create")).Post("/incidents", incident.CreateIncidentHandler(db, broker))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents", incident.GetIncidents(db))
+	r.With(middleware.RequirePermission(policy, "incidents", "export")).Get("/incidents/export", incident.ExportIncidents(db, policy))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents/near", incident.GetIncidentsNear(db))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents/within", incident.GetIncidentsWithin(db))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents/{id}", incident.GetIncidentByID(db))
+	r.With(middleware.RequirePermission(policy, "incidents", "update")).Put("/incidents/{id}", incident.UpdateIncident(db, broker))
+	r.With(middleware.RequirePermission(policy, "incidents", "delete")).Delete("/incidents/{id}", incident.DeleteIncident(db, broker))
+	r.With(middleware.RequirePermission(policy, "incidents", "transition")).Post("/incidents/{id}/transition", incident.TransitionIncident(db, broker))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents/{id}/history", incident.GetIncidentHistory(db))
+	r.With(middleware.RequirePermission(policy, "incidents", "dispatch")).Post("/incidents/{id}/dispatch", incident.DispatchIncident(db, broker))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents/{id}/chain", incident.GetIncidentChain(db))
+	r.With(middleware.RequirePermission(policy, "incidents", "read")).Get("/incidents/{id}/pdf", incident.PrintIncident(db, cfg, policy))
+
+	r.Route("/dispatch", func(r chi.Router) {
+		r.With(middleware.RequirePermission(policy, "dispatch", "read")).Get("/queue", incident.GetDispatchQueue(db))
+		r.With(middleware.RequirePermission(policy, "dispatch", "acknowledge")).Post("/{id}/acknowledge", incident.AcknowledgeDispatch(db))
+	})
 }
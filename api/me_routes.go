@@ -0,0 +1,30 @@
+package api
+
+import (
+	"homeland/config"
+	"homeland/handlers/auth"
+	"homeland/mfa"
+	"homeland/middleware"
+	"homeland/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func RegisterMeRoutes(r chi.Router, db *bun.DB, cfg *config.Config, policy *rbac.Engine, mfaService *mfa.Service) {
+	r.Route("/me", func(r chi.Router) {
+		r.Get("/permissions", auth.MyPermissionsHandler(policy))
+		// Every signed-in staff member may change their own password; tokens
+		// that must change it can reach nothing else.
+		r.Post("/password", auth.ChangePasswordHandler(db, cfg))
+
+		r.Route("/mfa", func(r chi.Router) {
+			// The one-time codes sent here are credentials.
+			r.Use(middleware.AuditRedact("code"))
+			r.Post("/enroll", auth.EnrollMFAHandler(db, mfaService))
+			r.Post("/confirm", auth.ConfirmMFAHandler(db, cfg, mfaService))
+			r.Post("/recovery-codes", auth.RegenerateRecoveryCodesHandler(db, mfaService))
+			r.Delete("/", auth.DisableMFAHandler(db, cfg, mfaService))
+		})
+	})
+}
@@ -1,30 +1,42 @@
 package api
 
 import (
+	"homeland/config"
+	"homeland/events"
 	"homeland/handlers/reporting"
+	"homeland/middleware"
+	"homeland/rbac"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func RegisterReportingRoutes(r chi.Router, db *bun.DB) {
+func RegisterReportingRoutes(r chi.Router, db *bun.DB, cfg *config.Config, broker *events.Broker, policy *rbac.Engine) {
 	r.Route("/reports", func(r chi.Router) {
+		r.With(middleware.RequirePermission(policy, "reports", "read")).Get("/", reporting.GetReportSummaries(db))
+		r.With(middleware.RequirePermission(policy, "reports", "export")).Get("/export", reporting.ExportReports(db))
+		r.With(middleware.RequirePermission(policy, "reports", "read")).Get("/near", reporting.GetReportsNear(db))
+		r.With(middleware.RequirePermission(policy, "reports", "read")).Get("/within", reporting.GetReportsWithin(db))
+
 		r.Route("/fire", func(r chi.Router) {
-			r.Post("/", reporting.CreateFireReport(db))
-			r.Get("/", reporting.GetFireReports(db))
-			r.Get("/{id}", reporting.GetFireReportByID(db))
+			r.With(middleware.RequirePermission(policy, "reports.fire", "create")).Post("/", reporting.CreateFireReport(db, broker))
+			r.With(middleware.RequirePermission(policy, "reports.fire", "read")).Get("/", reporting.GetFireReports(db))
+			r.With(middleware.RequirePermission(policy, "reports.fire", "read")).Get("/{id}", reporting.GetFireReportByID(db))
+			r.With(middleware.RequirePermission(policy, "reports.fire", "read")).Get("/{id}/pdf", reporting.PrintFireReport(db, cfg))
 		})
 
 		r.Route("/ems", func(r chi.Router) {
-			r.Post("/", reporting.CreateEMSReport(db))
-			r.Get("/", reporting.GetEMSReports(db))
-			r.Get("/{id}", reporting.GetEMSReportByID(db))
+			r.With(middleware.RequirePermission(policy, "reports.ems", "create")).Post("/", reporting.CreateEMSReport(db, broker))
+			r.With(middleware.RequirePermission(policy, "reports.ems", "read")).Get("/", reporting.GetEMSReports(db))
+			r.With(middleware.RequirePermission(policy, "reports.ems", "read")).Get("/{id}", reporting.GetEMSReportByID(db))
+			r.With(middleware.RequirePermission(policy, "reports.ems", "read")).Get("/{id}/pdf", reporting.PrintEMSReport(db, cfg))
 		})
 
 		r.Route("/avs", func(r chi.Router) {
-			r.Post("/", reporting.CreateAVSReport(db))
-			r.Get("/", reporting.GetAVSReports(db))
-			r.Get("/{id}", reporting.GetAVSReportByID(db))
+			r.With(middleware.RequirePermission(policy, "reports.avs", "create")).Post("/", reporting.CreateAVSReport(db, broker))
+			r.With(middleware.RequirePermission(policy, "reports.avs", "read")).Get("/", reporting.GetAVSReports(db))
+			r.With(middleware.RequirePermission(policy, "reports.avs", "read")).Get("/{id}", reporting.GetAVSReportByID(db))
+			r.With(middleware.RequirePermission(policy, "reports.avs", "read")).Get("/{id}/pdf", reporting.PrintAVSReport(db, cfg))
 		})
 	})
 }
@@ -2,16 +2,19 @@ package api
 
 import (
 	"homeland/handlers/staff"
+	"homeland/middleware"
+	"homeland/rbac"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func RegisterStaffRoutes(r chi.Router, db *bun.DB) {
+func RegisterStaffRoutes(r chi.Router, db *bun.DB, policy *rbac.Engine) {
 	r.Route("/staff", func(r chi.Router) {
-		r.Get("/{id}", staff.GetStaffHandler(db))
-		r.Get("/all", staff.GetAllStaffHandler(db))
-		r.Put("/{id}", staff.UpdateStaffHandler(db))
-		r.Delete("/{id}", staff.DeleteStaffHandler(db))
+		r.With(middleware.RequirePermission(policy, "staff", "read")).Get("/{id}", staff.GetStaffHandler(db))
+		r.With(middleware.RequirePermission(policy, "staff", "read")).Get("/all", staff.GetAllStaffHandler(db))
+		r.With(middleware.RequirePermission(policy, "staff", "export")).Get("/export", staff.ExportStaffHandler(db))
+		r.With(middleware.RequirePermission(policy, "staff", "update")).Put("/{id}", staff.UpdateStaffHandler(db))
+		r.With(middleware.RequirePermission(policy, "staff", "delete")).Delete("/{id}", staff.DeleteStaffHandler(db))
 	})
 }
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"homeland/events"
+	"homeland/handlers/stream"
+	"homeland/middleware"
+	"homeland/rbac"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterStreamRoutes(r chi.Router, broker *events.Broker, policy *rbac.Engine) {
+	r.Route("/stream", func(r chi.Router) {
+		r.Use(middleware.RequirePermission(policy, "stream", "read"))
+		r.Get("/events", stream.StreamEvents(broker))
+		r.Get("/ws", stream.StreamWebSocket(broker))
+	})
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"homeland/config"
+	"homeland/events"
+	"homeland/handlers/visitor"
+	"homeland/middleware"
+	"homeland/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func RegisterVisitorRoutes(r chi.Router, db *bun.DB, cfg *config.Config, broker *events.Broker, policy *rbac.Engine) {
+	r.Route("/visitors", func(r chi.Router) {
+		r.With(middleware.RequirePermission(policy, "visitors", "create")).Post("/", visitor.CreateVisitor(db))
+		r.With(middleware.RequirePermission(policy, "visitors", "read")).Get("/", visitor.GetVisitors(db))
+		r.With(middleware.RequirePermission(policy, "visitors", "read")).Get("/{id}", visitor.GetVisitor(db))
+		r.With(middleware.RequirePermission(policy, "visitors", "update")).Put("/{id}", visitor.UpdateVisitor(db))
+	})
+	r.Route("/visits", func(r chi.Router) {
+		r.With(middleware.RequirePermission(policy, "visitors", "check_in")).Post("/check-in", visitor.CheckIn(db, broker))
+		r.With(middleware.RequirePermission(policy, "visitors", "read")).Get("/on-site", visitor.GetOnSite(db))
+		r.With(middleware.RequirePermission(policy, "visitors", "read")).Get("/badge/{code}", visitor.GetVisitByBadge(db))
+		r.With(middleware.RequirePermission(policy, "visitors", "check_out")).Post("/badge/{code}/check-out", visitor.CheckOutByBadge(db, broker))
+		r.With(middleware.RequirePermission(policy, "visitors", "check_out")).Post("/{id}/check-out", visitor.CheckOut(db, broker))
+		r.With(middleware.RequirePermission(policy, "visitors", "check_in")).Get("/{id}/badge", visitor.PrintBadge(db, cfg))
+	})
+}
@@ -1,23 +1,41 @@
 package api
 
 import (
+	"homeland/config"
 	"homeland/handlers/workplace"
+	"homeland/middleware"
+	"homeland/rbac"
+	"homeland/storage"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func RegisterWorkplaceRoutes(r chi.Router, db *bun.DB) {
+func RegisterWorkplaceRoutes(r chi.Router, db *bun.DB, cfg *config.Config, policy *rbac.Engine, store storage.Backend) {
 	r.Route("/appointments", func(r chi.Router) {
-		r.Post("/", workplace.CreateAppointment(db))
-		r.Get("/", workplace.GetAppointments(db))
-		r.Get("/{id}", workplace.GetAppointmentByID(db))
-		r.Put("/{id}", workplace.UpdateAppointment(db))
-		r.Delete("/{id}", workplace.DeleteAppointment(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "create")).Post("/", workplace.CreateAppointment(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "read")).Get("/", workplace.GetAppointments(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "export")).Get("/export", workplace.ExportAppointments(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "import")).Post("/import", workplace.ImportAppointments(db, cfg))
+		r.With(middleware.RequirePermission(policy, "appointments", "read")).Get("/slots", workplace.GetFreeSlots(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "read")).Get("/availability/{staffID}", workplace.GetStaffAvailability(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "set_availability")).Put("/availability/{staffID}", workplace.SetStaffAvailability(db))
+		r.Route("/series", func(r chi.Router) {
+			r.With(middleware.RequirePermission(policy, "appointments", "create")).Post("/", workplace.CreateSeries(db, cfg))
+			r.With(middleware.RequirePermission(policy, "appointments", "read")).Get("/", workplace.GetSeries(db))
+			r.With(middleware.RequirePermission(policy, "appointments", "read")).Get("/{id}", workplace.GetSeriesByID(db, cfg))
+			r.With(middleware.RequirePermission(policy, "appointments", "update")).Put("/{id}", workplace.UpdateSeries(db, cfg))
+			r.With(middleware.RequirePermission(policy, "appointments", "update")).Post("/{id}/exdates", workplace.CancelOccurrence(db))
+			r.With(middleware.RequirePermission(policy, "appointments", "delete")).Delete("/{id}", workplace.DeleteSeries(db))
+		})
+		r.With(middleware.RequirePermission(policy, "appointments", "read")).Get("/{id}", workplace.GetAppointmentByID(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "update")).Put("/{id}", workplace.UpdateAppointment(db))
+		r.With(middleware.RequirePermission(policy, "appointments", "delete")).Delete("/{id}", workplace.DeleteAppointment(db))
 	})
 	r.Route("/documents", func(r chi.Router) {
-		r.Post("/upload", workplace.UploadDocument(db))
-		r.Get("/", workplace.GetDocuments(db))
-		r.Get("/{id}", workplace.GetDocumentByID(db))
+		r.With(middleware.RequirePermission(policy, "documents", "upload")).Post("/upload", workplace.UploadDocument(db, store, cfg.DocumentMaxBytes))
+		r.With(middleware.RequirePermission(policy, "documents", "read")).Get("/", workplace.GetDocuments(db))
+		r.With(middleware.RequirePermission(policy, "documents", "read")).Get("/{id}", workplace.GetDocumentByID(db))
+		r.With(middleware.RequirePermission(policy, "documents", "read")).Get("/{id}/download", workplace.DownloadDocument(db, store))
 	})
 }
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type entryKey struct{}
+
+// Entry collects what a handler knows about the resource it mutated. The audit
+// middleware creates one per request and persists it once the handler returns.
+type Entry struct {
+	mu           sync.Mutex
+	resourceType string
+	resourceID   string
+	action       string
+	before       map[string]interface{}
+	after        map[string]interface{}
+	// redacted are fields masked on this request only, on top of
+	// redactedFields.
+	redacted map[string]bool
+}
+
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+var redactedFields = map[string]bool{
+	"password":      true,
+	"old_password":  true,
+	"new_password":  true,
+	"access_token":  true,
+	"refresh_token": true,
+	"token":         true,
+	"mfa_token":     true,
+	"secret":        true,
+}
+
+func WithEntry(ctx context.Context) (context.Context, *Entry) {
+	entry := &Entry{}
+	return context.WithValue(ctx, entryKey{}, entry), entry
+}
+
+func fromContext(ctx context.Context) *Entry {
+	entry, _ := ctx.Value(entryKey{}).(*Entry)
+	return entry
+}
+
+func SetBefore(ctx context.Context, v interface{}) {
+	if entry := fromContext(ctx); entry != nil {
+		entry.mu.Lock()
+		entry.before = Snapshot(v)
+		redact(entry.before, entry.redacted)
+		entry.mu.Unlock()
+	}
+}
+
+func SetAfter(ctx context.Context, v interface{}) {
+	if entry := fromContext(ctx); entry != nil {
+		entry.mu.Lock()
+		entry.after = Snapshot(v)
+		redact(entry.after, entry.redacted)
+		entry.mu.Unlock()
+	}
+}
+
+// Redact masks fields in the request's snapshots, for names such as "code"
+// that hold a credential on some routes and ordinary data on others. It must
+// be called before the snapshots are taken.
+func Redact(ctx context.Context, fields ...string) {
+	if entry := fromContext(ctx); entry != nil {
+		entry.mu.Lock()
+		if entry.redacted == nil {
+			entry.redacted = make(map[string]bool)
+		}
+		for _, field := range fields {
+			entry.redacted[strings.ToLower(field)] = true
+		}
+		entry.mu.Unlock()
+	}
+}
+
+func SetResource(ctx context.Context, resourceType, resourceID string) {
+	if entry := fromContext(ctx); entry != nil {
+		entry.mu.Lock()
+		entry.resourceType = resourceType
+		entry.resourceID = resourceID
+		entry.mu.Unlock()
+	}
+}
+
+func SetAction(ctx context.Context, action string) {
+	if entry := fromContext(ctx); entry != nil {
+		entry.mu.Lock()
+		entry.action = action
+		entry.mu.Unlock()
+	}
+}
+
+func (e *Entry) Resource() (resourceType, resourceID, action string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resourceType, e.resourceID, e.action
+}
+
+func (e *Entry) States() (before, after map[string]interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.before, e.after
+}
+
+func (e *Entry) HasAfter() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.after != nil
+}
+
+// Snapshot converts v to its JSON object form with credential fields masked.
+// Values that do not encode to an object are returned as nil.
+func Snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+
+	var raw []byte
+	switch b := v.(type) {
+	case []byte:
+		raw = b
+	case json.RawMessage:
+		raw = b
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil
+		}
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil
+	}
+	redact(snapshot, redactedFields)
+	return snapshot
+}
+
+func redact(m map[string]interface{}, fields map[string]bool) {
+	if len(fields) == 0 {
+		return
+	}
+	for key, value := range m {
+		if fields[strings.ToLower(key)] {
+			m[key] = "[REDACTED]"
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			redact(nested, fields)
+		}
+	}
+}
+
+// Diff returns the top-level fields whose values differ between before and
+// after.
+func Diff(before, after map[string]interface{}) map[string]Change {
+	changes := make(map[string]Change)
+	for key, from := range before {
+		to, ok := after[key]
+		if !ok && after != nil {
+			continue
+		}
+		if !reflect.DeepEqual(from, to) {
+			changes[key] = Change{From: from, To: to}
+		}
+	}
+	for key, to := range after {
+		if _, ok := before[key]; !ok {
+			changes[key] = Change{To: to}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,363 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/models"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// propertyVisitor carries the visitor's name, which calendar clients have no
+// standard property for.
+const propertyVisitor = ics.ComponentProperty("X-HOMELAND-VISITOR")
+
+var (
+	errAllDay    = errors.New("all-day events are not supported")
+	errNoVisitor = errors.New("event has no X-HOMELAND-VISITOR or ATTENDEE to take the visitor's name from")
+	errNoStart   = errors.New("event has no DTSTART")
+)
+
+func AppointmentUID(a *models.Appointment) string {
+	if a.ICSUID != "" {
+		return a.ICSUID
+	}
+	return fmt.Sprintf("appointment-%d@homeland", a.ID)
+}
+
+func SeriesUID(s *models.AppointmentSeries) string {
+	if s.ICSUID != "" {
+		return s.ICSUID
+	}
+	return fmt.Sprintf("series-%d@homeland", s.ID)
+}
+
+// Feed builds an iCalendar file of appointments. Times are written in the
+// office zone.
+type Feed struct {
+	cal    *ics.Calendar
+	office *time.Location
+	stamp  time.Time
+}
+
+func NewFeed(name string, office *time.Location) *Feed {
+	cal := ics.NewCalendarFor("Homeland")
+	cal.SetMethod(ics.MethodPublish)
+	cal.SetName(name)
+	cal.SetXWRCalName(name)
+	cal.SetXWRTimezone(office.String())
+	return &Feed{cal: cal, office: office, stamp: time.Now()}
+}
+
+// AddAppointment adds a single appointment.
+func (f *Feed) AddAppointment(a *models.Appointment) {
+	f.event(AppointmentUID(a), a)
+}
+
+// AddSeries adds a recurring appointment with its cancelled occurrences.
+// The master event follows the current template, so stored occurrences that
+// may differ from it, those edited on their own and those already past, are
+// given as overrides. An override the current rule no longer produces, such
+// as a past occurrence from before the rule changed, is added as an RDATE so
+// its RECURRENCE-ID still names an instance of the series.
+func (f *Feed) AddSeries(s *models.AppointmentSeries, overrides []*models.Appointment) error {
+	rule, err := ParseRule(s.RRule, time.UTC)
+	if err != nil {
+		return err
+	}
+
+	uid := SeriesUID(s)
+	event := f.event(uid, s.Occurrence(s.StartsAt))
+	event.RemoveProperty(ics.ComponentPropertyRecurrenceId)
+	event.AddRrule(rule.ICS(f.office))
+	for _, d := range s.ExDates {
+		event.AddExdate(d.Format(localDateTimeFormat), ics.WithTZID(f.office.String()))
+	}
+
+	for _, a := range overrides {
+		rid := a.RecurrenceID
+		if len(rule.Expand(s.StartsAt, rid, rid.Add(time.Second), 1)) == 0 || s.IsExDate(rid) {
+			event.AddRdate(rid.Format(localDateTimeFormat), ics.WithTZID(f.office.String()))
+		}
+	}
+	for _, a := range overrides {
+		f.event(uid, a)
+	}
+	return nil
+}
+
+func (f *Feed) event(uid string, a *models.Appointment) *ics.VEvent {
+	event := f.cal.AddEvent(uid)
+	event.SetDtStampTime(f.stamp)
+	if !a.UpdatedAt.IsZero() {
+		event.SetLastModifiedAt(a.UpdatedAt)
+	}
+	event.SetProperty(ics.ComponentPropertyDtStart, a.TimeIn.Format(localDateTimeFormat), ics.WithTZID(f.office.String()))
+	event.SetProperty(ics.ComponentPropertyDtEnd, a.TimeOut.Format(localDateTimeFormat), ics.WithTZID(f.office.String()))
+	if !a.RecurrenceID.IsZero() {
+		event.SetProperty(ics.ComponentPropertyRecurrenceId, a.RecurrenceID.Format(localDateTimeFormat), ics.WithTZID(f.office.String()))
+	}
+	event.SetSummary(a.Purpose)
+	if a.Notes != "" {
+		event.SetDescription(a.Notes)
+	}
+	event.SetProperty(propertyVisitor, a.VisitorName)
+	if a.VisitorEmail != "" {
+		event.AddAttendee(a.VisitorEmail, ics.WithCN(a.VisitorName))
+	}
+	event.SetPriority(icsPriority(a.Priority))
+	return event
+}
+
+func (f *Feed) Write(w io.Writer) error {
+	return f.cal.SerializeTo(w)
+}
+
+func icsPriority(p models.PriorityEnum) int {
+	switch p {
+	case models.PriorityHigh:
+		return 1
+	case models.PriorityLow:
+		return 9
+	}
+	return 5
+}
+
+// priorityFromICS maps RFC 5545 priorities, where 1 is highest and 0 is
+// undefined, onto the three appointment priorities.
+func priorityFromICS(value string) models.PriorityEnum {
+	p, _ := strconv.Atoi(strings.TrimSpace(value))
+	switch {
+	case p >= 1 && p <= 4:
+		return models.PriorityHigh
+	case p >= 6 && p <= 9:
+		return models.PriorityLow
+	}
+	return models.PriorityMedium
+}
+
+// Event is a VEVENT read from an imported calendar. Times are office
+// wall-clock times.
+type Event struct {
+	UID          string
+	RecurrenceID time.Time
+	Summary      string
+	Description  string
+	Visitor      string
+	VisitorEmail string
+	Start        time.Time
+	End          time.Time
+	Rule         *Rule
+	ExDates      []time.Time
+	Priority     models.PriorityEnum
+	Cancelled    bool
+	// Err is why the event could not be read; the other fields may be
+	// incomplete.
+	Err error
+}
+
+// Entry groups the events sharing a UID: the master event, which carries
+// the recurrence rule, and any edited occurrences.
+type Entry struct {
+	UID       string
+	Master    *Event
+	Overrides []*Event
+}
+
+// Parse reads the events of an iCalendar file, grouped by UID in the order
+// they first appear. Events that cannot be read are returned with Err set
+// rather than failing the whole file.
+func Parse(r io.Reader, office *time.Location) ([]*Entry, error) {
+	cal, err := ics.ParseCalendar(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0)
+	byUID := make(map[string]*Entry)
+	for _, v := range cal.Events() {
+		event := readEvent(v, office)
+		entry, ok := byUID[event.UID]
+		if !ok {
+			entry = &Entry{UID: event.UID}
+			byUID[event.UID] = entry
+			entries = append(entries, entry)
+		}
+		if v.GetProperty(ics.ComponentPropertyRecurrenceId) == nil {
+			entry.Master = event
+		} else {
+			entry.Overrides = append(entry.Overrides, event)
+		}
+	}
+	return entries, nil
+}
+
+func readEvent(v *ics.VEvent, office *time.Location) *Event {
+	event := &Event{
+		UID:       v.Id(),
+		Summary:   propertyValue(v, ics.ComponentPropertySummary),
+		Priority:  priorityFromICS(propertyValue(v, ics.ComponentPropertyPriority)),
+		Cancelled: strings.EqualFold(propertyValue(v, ics.ComponentPropertyStatus), string(ics.ObjectStatusCancelled)),
+	}
+	event.Description = propertyValue(v, ics.ComponentPropertyDescription)
+	event.Visitor = visitorName(v)
+	event.VisitorEmail = visitorEmail(v)
+
+	fail := func(err error) *Event {
+		event.Err = err
+		return event
+	}
+
+	if p := v.GetProperty(ics.ComponentPropertyRecurrenceId); p != nil {
+		t, err := propertyTime(p, p.Value, office)
+		if err != nil {
+			return fail(fmt.Errorf("RECURRENCE-ID: %w", err))
+		}
+		event.RecurrenceID = t
+	}
+
+	start := v.GetProperty(ics.ComponentPropertyDtStart)
+	if start == nil {
+		return fail(errNoStart)
+	}
+	t, err := propertyTime(start, start.Value, office)
+	if err != nil {
+		return fail(fmt.Errorf("DTSTART: %w", err))
+	}
+	event.Start = t
+
+	switch {
+	case v.GetProperty(ics.ComponentPropertyDtEnd) != nil:
+		end := v.GetProperty(ics.ComponentPropertyDtEnd)
+		if event.End, err = propertyTime(end, end.Value, office); err != nil {
+			return fail(fmt.Errorf("DTEND: %w", err))
+		}
+	case v.GetProperty(ics.ComponentPropertyDuration) != nil:
+		d, err := parseDuration(propertyValue(v, ics.ComponentPropertyDuration))
+		if err != nil {
+			return fail(fmt.Errorf("DURATION: %w", err))
+		}
+		event.End = event.Start.Add(d)
+	default:
+		event.End = event.Start
+	}
+
+	if p := v.GetProperty(ics.ComponentPropertyRrule); p != nil && event.RecurrenceID.IsZero() {
+		if event.Rule, err = ParseRule(p.Value, office); err != nil {
+			return fail(err)
+		}
+	}
+	for _, p := range v.GetProperties(ics.ComponentPropertyExdate) {
+		for _, value := range strings.Split(p.Value, ",") {
+			t, err := propertyTime(p, value, office)
+			if err != nil {
+				return fail(fmt.Errorf("EXDATE: %w", err))
+			}
+			event.ExDates = append(event.ExDates, t)
+		}
+	}
+
+	// Edited occurrences may leave the visitor to their recurring event.
+	if event.Visitor == "" && !event.Cancelled && event.RecurrenceID.IsZero() {
+		return fail(errNoVisitor)
+	}
+	return event
+}
+
+func propertyValue(v *ics.VEvent, property ics.ComponentProperty) string {
+	if p := v.GetProperty(property); p != nil {
+		return strings.TrimSpace(p.Value)
+	}
+	return ""
+}
+
+// visitorName takes the visitor from X-HOMELAND-VISITOR, or else from the
+// first attendee's name or address.
+func visitorName(v *ics.VEvent) string {
+	if name := propertyValue(v, propertyVisitor); name != "" {
+		return name
+	}
+	for _, attendee := range v.Attendees() {
+		if cn := attendee.ICalParameters[string(ics.ParameterCn)]; len(cn) > 0 && cn[0] != "" {
+			return cn[0]
+		}
+		if email := attendee.Email(); email != "" {
+			return email
+		}
+	}
+	return ""
+}
+
+// visitorEmail takes the visitor's address from the first attendee that has
+// one.
+func visitorEmail(v *ics.VEvent) string {
+	for _, attendee := range v.Attendees() {
+		if email := attendee.Email(); email != "" {
+			return email
+		}
+	}
+	return ""
+}
+
+// propertyTime reads a DATE-TIME value of p. UTC times and times with a
+// TZID are converted to office time; floating times are taken as office
+// time already.
+func propertyTime(p *ics.IANAProperty, value string, office *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if kinds := p.ICalParameters[string(ics.ParameterValue)]; len(kinds) > 0 && strings.EqualFold(kinds[0], string(ics.ValueDataTypeDate)) {
+		return time.Time{}, errAllDay
+	}
+	if len(value) == len("20060102") {
+		return time.Time{}, errAllDay
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(localDateTimeFormat+"Z", value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return models.WallClock(t.In(office)), nil
+	}
+	if tzid := p.ICalParameters[string(ics.ParameterTzid)]; len(tzid) > 0 {
+		loc, err := time.LoadLocation(strings.TrimPrefix(strings.Trim(tzid[0], `"`), "/"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q", tzid[0])
+		}
+		t, err := time.ParseInLocation(localDateTimeFormat, value, loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return models.WallClock(t.In(office)), nil
+	}
+	t, err := time.Parse(localDateTimeFormat, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return models.WallClock(t), nil
+}
+
+var durationPattern = regexp.MustCompile(`^\+?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration reads a positive RFC 5545 DURATION such as "PT1H30M".
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.ToUpper(value)
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || strings.HasSuffix(value, "P") || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			d += time.Duration(n) * unit
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,118 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestPropertyTime(t *testing.T) {
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string][]string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "floating", value: "20261005T090000", want: "2026-10-05 09:00"},
+		{name: "utc", value: "20261005T080000Z", want: "2026-10-05 09:00"},
+		{
+			name:   "tzid",
+			params: map[string][]string{"TZID": {"Europe/London"}},
+			value:  "20261005T090000",
+			want:   "2026-10-05 09:00",
+		},
+		{
+			name:   "quoted tzid with leading slash",
+			params: map[string][]string{"TZID": {`"/America/New_York"`}},
+			value:  "20261005T040000",
+			want:   "2026-10-05 09:00",
+		},
+		{name: "date value", value: "20261005", wantErr: errAllDay},
+		{
+			name:    "date type",
+			params:  map[string][]string{"VALUE": {"DATE"}},
+			value:   "20261005T000000",
+			wantErr: errAllDay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ics.IANAProperty{BaseProperty: ics.BaseProperty{ICalParameters: tt.params}}
+			if p.ICalParameters == nil {
+				p.ICalParameters = map[string][]string{}
+			}
+			got, err := propertyTime(p, tt.value, lagos)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("propertyTime(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("propertyTime(%q) error = %v", tt.value, err)
+			}
+			if want := wallClock(tt.want); !got.Equal(want) {
+				t.Errorf("propertyTime(%q) = %v, want %v", tt.value, got, want)
+			}
+		})
+	}
+
+	t.Run("unknown tzid", func(t *testing.T) {
+		p := &ics.IANAProperty{BaseProperty: ics.BaseProperty{ICalParameters: map[string][]string{"TZID": {"Mars/Olympus"}}}}
+		if _, err := propertyTime(p, "20261005T090000", lagos); err == nil {
+			t.Fatal("propertyTime() with an unknown zone succeeded")
+		}
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "PT1H30M", want: 90 * time.Minute},
+		{value: "PT45S", want: 45 * time.Second},
+		{value: "P1D", want: 24 * time.Hour},
+		{value: "P1W", want: 7 * 24 * time.Hour},
+		{value: "P1DT2H", want: 26 * time.Hour},
+		{value: "+PT15M", want: 15 * time.Minute},
+		{value: "pt30m", want: 30 * time.Minute},
+		{value: "P", wantErr: true},
+		{value: "p", wantErr: true},
+		{value: "PT", wantErr: true},
+		{value: "+P", wantErr: true},
+		{value: "pt", wantErr: true},
+		{value: "P1DT", wantErr: true},
+		{value: "-PT1H", wantErr: true},
+		{value: "1H", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDuration(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDuration(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDuration(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
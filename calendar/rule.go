@@ -0,0 +1,141 @@
+// Package calendar expands recurrence rules and reads and writes iCalendar
+// (RFC 5545) data for appointments.
+//
+// Appointment times are office wall-clock times stored as UTC-labelled
+// values (see models.WallClock). Recurrence rules are stored in that form
+// too: UNTIL is written without a zone and means office time.
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	// Feeds name the office zone, so the zone database must be available
+	// even where the host has none installed.
+	_ "time/tzdata"
+
+	"homeland/models"
+
+	"github.com/teambition/rrule-go"
+)
+
+const localDateTimeFormat = "20060102T150405"
+
+var (
+	ErrInvalidRule     = errors.New("rrule is not a valid recurrence rule")
+	ErrRuleTooFrequent = errors.New("rrule must not repeat more often than daily")
+)
+
+// Rule is a parsed recurrence rule without its start.
+type Rule struct {
+	opt rrule.ROption
+}
+
+// ParseRule reads an RRULE value, with or without the "RRULE:" prefix. A
+// local UNTIL is taken as office wall-clock time; a UTC one is converted to
+// it using office.
+func ParseRule(value string, office *time.Location) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "RRULE:")
+	if value == "" || strings.ContainsAny(value, "\r\n") {
+		return nil, ErrInvalidRule
+	}
+
+	// rrule-go reads a zone-less UNTIL in the location it is given and a
+	// UTC one as UTC, so the UTC form needs converting afterwards.
+	utcUntil := false
+	for _, part := range strings.Split(value, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok && strings.EqualFold(k, "UNTIL") {
+			utcUntil = strings.HasSuffix(strings.ToUpper(v), "Z")
+		}
+	}
+
+	opt, err := rrule.StrToROptionInLocation(value, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	if opt.Freq > rrule.DAILY {
+		return nil, ErrRuleTooFrequent
+	}
+	if len(opt.Byhour) > 0 || len(opt.Byminute) > 0 || len(opt.Bysecond) > 0 {
+		return nil, fmt.Errorf("%w: the time of day comes from the start, so BYHOUR, BYMINUTE and BYSECOND are not supported", ErrInvalidRule)
+	}
+	if !opt.Dtstart.IsZero() {
+		return nil, fmt.Errorf("%w: DTSTART belongs in starts_at", ErrInvalidRule)
+	}
+	if utcUntil {
+		opt.Until = models.WallClock(opt.Until.In(office))
+	}
+	if _, err := rrule.NewRRule(*opt); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return &Rule{opt: *opt}, nil
+}
+
+// String returns the rule in its stored form, with a zone-less UNTIL.
+func (r *Rule) String() string {
+	return r.format(r.opt.Until.Format(localDateTimeFormat))
+}
+
+// ICS returns the rule as written to a calendar whose DTSTART carries the
+// office zone; RFC 5545 then requires UNTIL in UTC.
+func (r *Rule) ICS(office *time.Location) string {
+	return r.format(Instant(r.opt.Until, office).UTC().Format(localDateTimeFormat + "Z"))
+}
+
+func (r *Rule) format(until string) string {
+	opt := r.opt
+	opt.Until = time.Time{}
+	value := opt.RRuleString()
+	if !r.opt.Until.IsZero() {
+		value += ";UNTIL=" + until
+	}
+	return value
+}
+
+// Expand returns the starts of the occurrences beginning at dtstart that fall
+// in [from, to), at most max of them.
+func (r *Rule) Expand(dtstart, from, to time.Time, max int) []time.Time {
+	opt := r.opt
+	opt.Dtstart = dtstart
+	rule, err := rrule.NewRRule(opt)
+	if err != nil {
+		return nil
+	}
+
+	starts := make([]time.Time, 0)
+	next := rule.Iterator()
+	for len(starts) < max {
+		start, ok := next()
+		if !ok || !start.Before(to) {
+			break
+		}
+		if !start.Before(from) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// Office loads the named office time zone, falling back to UTC when the name
+// is unknown.
+func Office(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown office time zone %q, using UTC: %v", name, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// Instant is the moment at which the office wall-clock time t happens.
+func Instant(t time.Time, office *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, office)
+}
+
+// Now is the current office wall-clock time.
+func Now(office *time.Location) time.Time {
+	return models.WallClock(time.Now().In(office))
+}
@@ -0,0 +1,150 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func wallClock(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseRule(t *testing.T) {
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		stored  string
+		ics     string
+		wantErr error
+	}{
+		{
+			name:   "weekly",
+			value:  "FREQ=WEEKLY;BYDAY=MO,WE",
+			stored: "FREQ=WEEKLY;BYDAY=MO,WE",
+			ics:    "FREQ=WEEKLY;BYDAY=MO,WE",
+		},
+		{
+			name:   "prefix and whitespace",
+			value:  "  RRULE:FREQ=DAILY;COUNT=5 ",
+			stored: "FREQ=DAILY;COUNT=5",
+			ics:    "FREQ=DAILY;COUNT=5",
+		},
+		{
+			name:   "local until is office time",
+			value:  "FREQ=WEEKLY;UNTIL=20261231T170000",
+			stored: "FREQ=WEEKLY;UNTIL=20261231T170000",
+			ics:    "FREQ=WEEKLY;UNTIL=20261231T160000Z",
+		},
+		{
+			name:   "utc until is converted to office time",
+			value:  "FREQ=WEEKLY;UNTIL=20261231T160000Z",
+			stored: "FREQ=WEEKLY;UNTIL=20261231T170000",
+			ics:    "FREQ=WEEKLY;UNTIL=20261231T160000Z",
+		},
+		{name: "empty", value: "", wantErr: ErrInvalidRule},
+		{name: "line break", value: "FREQ=DAILY\r\nSUMMARY:x", wantErr: ErrInvalidRule},
+		{name: "garbage", value: "FREQ=SOMETIMES", wantErr: ErrInvalidRule},
+		{name: "hourly", value: "FREQ=HOURLY", wantErr: ErrRuleTooFrequent},
+		{name: "byhour", value: "FREQ=DAILY;BYHOUR=9", wantErr: ErrInvalidRule},
+		{name: "dtstart", value: "DTSTART=20260101T090000;FREQ=DAILY", wantErr: ErrInvalidRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.value, lagos)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseRule(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRule(%q) error = %v", tt.value, err)
+			}
+			if got := rule.String(); got != tt.stored {
+				t.Errorf("String() = %q, want %q", got, tt.stored)
+			}
+			if got := rule.ICS(lagos); got != tt.ics {
+				t.Errorf("ICS() = %q, want %q", got, tt.ics)
+			}
+		})
+	}
+}
+
+func TestRuleExpand(t *testing.T) {
+	// 2026-10-05 is a Monday.
+	dtstart := wallClock("2026-10-05 09:00")
+
+	tests := []struct {
+		name     string
+		rule     string
+		from, to string
+		max      int
+		want     []string
+	}{
+		{
+			name: "weekly on two days",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE",
+			from: "2026-10-05 00:00", to: "2026-10-15 00:00", max: 10,
+			want: []string{"2026-10-05 09:00", "2026-10-07 09:00", "2026-10-12 09:00", "2026-10-14 09:00"},
+		},
+		{
+			name: "from skips earlier occurrences",
+			rule: "FREQ=DAILY",
+			from: "2026-10-07 09:00", to: "2026-10-09 09:00", max: 10,
+			want: []string{"2026-10-07 09:00", "2026-10-08 09:00"},
+		},
+		{
+			name: "until is inclusive",
+			rule: "FREQ=DAILY;UNTIL=20261007T090000",
+			from: "2026-10-01 00:00", to: "2026-12-01 00:00", max: 10,
+			want: []string{"2026-10-05 09:00", "2026-10-06 09:00", "2026-10-07 09:00"},
+		},
+		{
+			name: "count",
+			rule: "FREQ=WEEKLY;COUNT=2",
+			from: "2026-10-01 00:00", to: "2026-12-01 00:00", max: 10,
+			want: []string{"2026-10-05 09:00", "2026-10-12 09:00"},
+		},
+		{
+			name: "max",
+			rule: "FREQ=DAILY",
+			from: "2026-10-01 00:00", to: "2026-12-01 00:00", max: 2,
+			want: []string{"2026-10-05 09:00", "2026-10-06 09:00"},
+		},
+		{
+			name: "empty window",
+			rule: "FREQ=DAILY",
+			from: "2026-10-05 09:00", to: "2026-10-05 09:00", max: 10,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule, time.UTC)
+			if err != nil {
+				t.Fatalf("ParseRule(%q) error = %v", tt.rule, err)
+			}
+			got := rule.Expand(dtstart, wallClock(tt.from), wallClock(tt.to), tt.max)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expand() = %v, want %v", got, tt.want)
+			}
+			for i, start := range got {
+				if want := wallClock(tt.want[i]); !start.Equal(want) {
+					t.Errorf("Expand()[%d] = %v, want %v", i, start, want)
+				}
+			}
+		})
+	}
+}
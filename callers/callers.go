@@ -0,0 +1,117 @@
+package callers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// A caller who reports RepeatThreshold or more incidents within RepeatWindow
+// is flagged as a repeat caller.
+const (
+	RepeatThreshold = 3
+	RepeatWindow    = 7 * 24 * time.Hour
+)
+
+// Profile is what the call-center operator sees about a caller while an
+// incident is being logged.
+type Profile struct {
+	*models.Caller
+	PriorIncidents  int      `json:"prior_incidents"`
+	RecentIncidents int      `json:"recent_incidents"`
+	Alerts          []string `json:"alerts,omitempty"`
+}
+
+// Link finds or creates the caller for incident's phone number, updates their
+// details from the incident and sets incident.CallerID. It must run before the
+// incident is inserted, in the same transaction.
+func Link(ctx context.Context, db bun.IDB, incident *models.Incident) (*Profile, error) {
+	phone, err := utils.NormalizePhoneNumber(incident.CallerPhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	caller := &models.Caller{
+		PhoneNumber:       phone,
+		FullName:          incident.CallerFullName,
+		LastKnownLocation: incident.CallerLocation,
+		IncidentCount:     1,
+		FirstSeenAt:       now,
+		LastSeenAt:        now,
+	}
+	_, err = db.NewInsert().
+		Model(caller).
+		On("CONFLICT (phone_number) DO UPDATE").
+		Set("full_name = COALESCE(NULLIF(EXCLUDED.full_name, ''), caller.full_name)").
+		Set("last_known_location = COALESCE(NULLIF(EXCLUDED.last_known_location, ''), caller.last_known_location)").
+		Set("incident_count = caller.incident_count + 1").
+		Set("last_seen_at = EXCLUDED.last_seen_at").
+		Set("updated_at = EXCLUDED.last_seen_at").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recent, err := db.NewSelect().
+		Model((*models.Incident)(nil)).
+		Where("caller_id = ?", caller.ID).
+		Where("created_at > ?", now.Add(-RepeatWindow)).
+		Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !caller.IsRepeat && recent+1 >= RepeatThreshold {
+		caller.IsRepeat = true
+		if _, err := db.NewUpdate().Model(caller).Column("is_repeat").WherePK().Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	incident.CallerID = caller.ID
+	incident.CallerPhoneNumber = phone
+
+	return &Profile{
+		Caller:          caller,
+		PriorIncidents:  caller.IncidentCount - 1,
+		RecentIncidents: recent,
+		Alerts:          alerts(caller, recent),
+	}, nil
+}
+
+// Unlink reverses Link's count when an incident stops belonging to a caller.
+func Unlink(ctx context.Context, db bun.IDB, callerID int64) error {
+	if callerID == 0 {
+		return nil
+	}
+	_, err := db.NewUpdate().
+		Model((*models.Caller)(nil)).
+		Set("incident_count = GREATEST(incident_count - 1, 0)").
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", callerID).
+		Exec(ctx)
+	return err
+}
+
+func alerts(caller *models.Caller, recent int) []string {
+	var list []string
+	if caller.IsPrank {
+		alert := "Caller is flagged as a prank caller"
+		if caller.FlagReason != "" {
+			alert += ": " + caller.FlagReason
+		}
+		list = append(list, alert)
+	}
+	if caller.IsRepeat {
+		list = append(list, fmt.Sprintf("Repeat caller: %d previous incidents, %d in the last %d days",
+			caller.IncidentCount-1, recent, int(RepeatWindow.Hours()/24)))
+	}
+	return list
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +19,111 @@ type Config struct {
 	JWTSecret     string
 	AdminEmail    string
 	AdminPassword string
+	// RBACPolicyFile overrides the bundled permission policy when set.
+	RBACPolicyFile string
+	// StorageDir is where the local storage backend keeps uploaded files.
+	StorageDir       string
+	DocumentMaxBytes int64
+	// PasswordResetURL is the page that accepts reset tokens; the token is
+	// appended as a "token" query parameter.
+	PasswordResetURL string
+	// Password policy applied wherever a password is set.
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordHistorySize   int
+	// MFARequiredRoles must enroll in two-factor authentication before they
+	// can use the API.
+	MFARequiredRoles []string
+	MFAIssuer        string
+	// MFAEncryptionKey encrypts stored TOTP secrets. It is required and kept
+	// apart from JWTSecret so either can be rotated on its own.
+	MFAEncryptionKey string
+	// Agency details printed in the header of PDF printouts. AgencyLogo is
+	// an optional path to a PNG or JPEG file.
+	AgencyName    string
+	AgencyAddress string
+	AgencyLogo    string
+	// OfficeTimezone is the IANA zone appointment times are in. It is
+	// written to calendar feeds so clients elsewhere show the right time.
+	OfficeTimezone string
+	// ReminderLeadTimes are how long before an appointment its host and
+	// visitor are emailed a reminder.
+	ReminderLeadTimes []time.Duration
+	// Outgoing mail. Email is disabled while SMTPHost is empty; SMTPFrom
+	// defaults to SMTPUser.
+	SMTPHost string
+	SMTPPort string
+	SMTPUser string
+	SMTPPass string
+	SMTPFrom string
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header gives the client address.
+	TrustedProxies []string
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvDurations(key string, fallback []time.Duration) []time.Duration {
+	var list []time.Duration
+	for _, item := range getEnvList(key, nil) {
+		d, err := time.ParseDuration(item)
+		if err != nil || d <= 0 {
+			log.Printf("Ignoring invalid duration %q in %s", item, key)
+			continue
+		}
+		list = append(list, d)
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
 
 func LoadConfig() *Config {
@@ -24,16 +132,44 @@ func LoadConfig() *Config {
 	}
 
 	config := &Config{
-		DBHost:        os.Getenv("DB_HOST"),
-		DBPort:        os.Getenv("DB_PORT"),
-		DBUser:        os.Getenv("DB_USER"),
-		DBPass:        os.Getenv("DB_PASSWORD"),
-		DBName:        os.Getenv("DB_NAME"),
-		JWTSecret:     os.Getenv("JWT_SECRET"),
-		AdminEmail:    os.Getenv("ADMIN_EMAIL"),
-		AdminPassword: os.Getenv("ADMIN_PASSWORD"),
-	}
+		DBHost:           os.Getenv("DB_HOST"),
+		DBPort:           os.Getenv("DB_PORT"),
+		DBUser:           os.Getenv("DB_USER"),
+		DBPass:           os.Getenv("DB_PASSWORD"),
+		DBName:           os.Getenv("DB_NAME"),
+		JWTSecret:        os.Getenv("JWT_SECRET"),
+		AdminEmail:       os.Getenv("ADMIN_EMAIL"),
+		AdminPassword:    os.Getenv("ADMIN_PASSWORD"),
+		RBACPolicyFile:   os.Getenv("RBAC_POLICY_FILE"),
+		StorageDir:       getEnv("STORAGE_DIR", "data/documents"),
+		DocumentMaxBytes: getEnvInt64("DOCUMENT_MAX_BYTES", 20<<20),
+		PasswordResetURL: os.Getenv("PASSWORD_RESET_URL"),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordRequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+		PasswordRequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+		PasswordRequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordHistorySize:   getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+
+		MFARequiredRoles: getEnvList("MFA_REQUIRED_ROLES", []string{"Admin", "SSA", "Director"}),
+		MFAIssuer:        getEnv("MFA_ISSUER", "Homeland"),
+		MFAEncryptionKey: os.Getenv("MFA_ENCRYPTION_KEY"),
+
+		AgencyName:    getEnv("AGENCY_NAME", "Homeland Security"),
+		AgencyAddress: os.Getenv("AGENCY_ADDRESS"),
+		AgencyLogo:    os.Getenv("AGENCY_LOGO"),
 
-	log.Printf("Loaded Config: %+v\n", config) // Debugging
+		OfficeTimezone:    getEnv("OFFICE_TIMEZONE", "Africa/Lagos"),
+		ReminderLeadTimes: getEnvDurations("REMINDER_LEAD_TIMES", []time.Duration{24 * time.Hour, time.Hour}),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", nil),
+
+		SMTPHost: os.Getenv("SMTP_HOST"),
+		SMTPPort: getEnv("SMTP_PORT", "587"),
+		SMTPUser: os.Getenv("SMTP_USER"),
+		SMTPPass: os.Getenv("SMTP_PASS"),
+		SMTPFrom: getEnv("SMTP_FROM", os.Getenv("SMTP_USER")),
+	}
 	return config
 }
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	IncidentCreated = "incident.created"
+	IncidentUpdated = "incident.updated"
+	IncidentDeleted = "incident.deleted"
+	ReportCreated   = "report.created"
+
+	VisitorCheckedIn  = "visitor.checked_in"
+	VisitorCheckedOut = "visitor.checked_out"
+)
+
+type Event struct {
+	ID          int64       `json:"id"`
+	Type        string      `json:"type"`
+	Departments []string    `json:"departments,omitempty"`
+	Data        interface{} `json:"data"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Broker fans events out to live subscribers and keeps the most recent ones in
+// a ring buffer so reconnecting clients can replay what they missed.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      int64
+	buffer      []Event
+	start       int
+	count       int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C      chan Event
+	filter func(Event) bool
+	broker *Broker
+	once   sync.Once
+}
+
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	return &Broker{
+		// IDs continue from the clock rather than from 1, so an ID a client
+		// kept from before a restart is never mistaken for a new event.
+		nextID:      time.Now().UnixMicro(),
+		buffer:      make([]Event, bufferSize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Publish(eventType string, data interface{}, departments ...string) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{
+		ID:          b.nextID,
+		Type:        eventType,
+		Departments: departments,
+		Data:        data,
+		CreatedAt:   time.Now(),
+	}
+	b.nextID++
+
+	end := (b.start + b.count) % len(b.buffer)
+	b.buffer[end] = event
+	if b.count < len(b.buffer) {
+		b.count++
+	} else {
+		b.start = (b.start + 1) % len(b.buffer)
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+			// A subscriber that can't keep up is dropped; it reconnects with
+			// its last event ID and replays from the buffer.
+			b.remove(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a listener and returns the buffered events after
+// lastID that pass filter. complete is false when events after lastID have
+// already been evicted from the buffer and the client must refetch state.
+func (b *Broker) Subscribe(lastID int64, filter func(Event) bool) (sub *Subscription, backlog []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastID > 0 {
+		// With nothing buffered, as after a restart, only the last ID
+		// handed out is still up to date.
+		oldest := b.nextID
+		if b.count > 0 {
+			oldest = b.buffer[b.start].ID
+		}
+		if lastID < oldest-1 || lastID >= b.nextID {
+			complete = false
+		}
+		for i := 0; i < b.count; i++ {
+			event := b.buffer[(b.start+i)%len(b.buffer)]
+			if event.ID > lastID && filter(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	sub = &Subscription{
+		C:      make(chan Event, 64),
+		filter: filter,
+		broker: b,
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return sub, backlog, complete
+}
+
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	sub.once.Do(func() { close(sub.C) })
+}
@@ -0,0 +1,263 @@
+// Package export streams query results to CSV or XLSX downloads one row at a
+// time, so large extracts are never held in memory.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// MaxXLSXRows is the data rows that fit on a worksheet below the header.
+const MaxXLSXRows = 1_048_575
+
+var (
+	ErrInvalidFormat = errors.New("format must be csv or xlsx")
+	ErrTooManyRows   = fmt.Errorf("more than %d rows match; narrow the filters or export as csv", MaxXLSXRows)
+)
+
+// ParseFormat reads the format parameter, defaulting to CSV.
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case "", CSV:
+		return CSV, nil
+	case XLSX:
+		return XLSX, nil
+	}
+	return "", ErrInvalidFormat
+}
+
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Column is one exported field. Header names are part of the export format
+// and must not change once published.
+type Column[T any] struct {
+	Header string
+	Value  func(*T) interface{}
+}
+
+type rowWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvCell(v)
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// Flush regularly so the client starts receiving data at once.
+	if c.rows++; c.rows%500 == 0 {
+		c.w.Flush()
+	}
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// xlsxWriter writes through excelize's stream writer, which spills rows to a
+// temporary file instead of keeping them in memory.
+type xlsxWriter struct {
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	out    io.Writer
+	row    int
+}
+
+func newXLSXWriter(out io.Writer, sheet string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{file: file, stream: stream, out: out}, nil
+}
+
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		values[i] = xlsxCell(v)
+	}
+	return x.stream.SetRow(cell, values)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.out)
+}
+
+func timeCell(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// plainNumber matches signed numbers and E.164 phone numbers, which start
+// with + or - but evaluate to nothing but themselves.
+var plainNumber = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?$`)
+
+// csvCell formats a value and neutralises text a spreadsheet would otherwise
+// evaluate as a formula.
+func csvCell(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case time.Time:
+		return timeCell(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) && !plainNumber.MatchString(s) {
+		return "'" + s
+	}
+	return s
+}
+
+func xlsxCell(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return timeCell(t)
+	}
+	return v
+}
+
+// Filename is name with today's date and the format's extension.
+func Filename(name string, format Format) string {
+	return fmt.Sprintf("%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+}
+
+// Stream runs q and writes every row to w as an attachment. Errors before the
+// first byte is written are returned for the handler to report; later ones
+// can only be logged and cut the download short.
+func Stream[T any](ctx context.Context, w http.ResponseWriter, db *bun.DB, q *bun.SelectQuery, format Format, name string, columns []Column[T]) error {
+	if format == XLSX {
+		count, err := q.Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count > MaxXLSXRows {
+			return ErrTooManyRows
+		}
+	}
+
+	rows, err := q.Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var out rowWriter
+	if format == XLSX {
+		if out, err = newXLSXWriter(w, name); err != nil {
+			return err
+		}
+	} else {
+		out = &csvWriter{w: csv.NewWriter(w)}
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": Filename(name, format)}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	err = writeRows(ctx, out, db, rows, columns)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+	}
+	return nil
+}
+
+func writeRows[T any](ctx context.Context, out rowWriter, db *bun.DB, rows *sql.Rows, columns []Column[T]) error {
+	headers := make([]interface{}, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Header
+	}
+	if err := out.WriteRow(headers); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	for rows.Next() {
+		var item T
+		if err := db.ScanRow(ctx, rows, &item); err != nil {
+			return err
+		}
+		for i, column := range columns {
+			values[i] = column.Value(&item)
+		}
+		if err := out.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Float formats an optional number, such as a coordinate.
+func Float(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// MaskPhone hides all but the last four digits of a phone number.
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
@@ -0,0 +1,185 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+const earthRadiusKm = 6371.0
+
+// MaxRadiusKm bounds proximity searches so a single query cannot scan the
+// whole table.
+const MaxRadiusKm = 500.0
+
+const (
+	DefaultResults = 100
+	// MaxResults bounds how many features a map query returns.
+	MaxResults = 1000
+)
+
+var (
+	ErrInvalidPoint       = errors.New("lat and lng must be valid coordinates")
+	ErrInvalidRadius      = errors.New("radius_km must be greater than 0 and at most 500")
+	ErrInvalidBoundingBox = errors.New("bbox must be min_lng,min_lat,max_lng,max_lat")
+)
+
+// DistanceKm is the great-circle distance between two points by the haversine
+// formula.
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// parseFloat reads a finite number; strconv accepts NaN and Inf, which would
+// slip past every range check below.
+func parseFloat(value string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// ParseLimit reads the limit query parameter, defaulting to DefaultResults
+// and capped at MaxResults.
+func ParseLimit(value string) int {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return DefaultResults
+	}
+	if limit > MaxResults {
+		return MaxResults
+	}
+	return limit
+}
+
+// ParsePoint reads a latitude and longitude from query parameter values.
+func ParsePoint(latValue, lngValue string) (float64, float64, error) {
+	lat, ok := parseFloat(latValue)
+	if !ok || lat < -90 || lat > 90 {
+		return 0, 0, ErrInvalidPoint
+	}
+	lng, ok := parseFloat(lngValue)
+	if !ok || lng < -180 || lng > 180 {
+		return 0, 0, ErrInvalidPoint
+	}
+	return lat, lng, nil
+}
+
+// ParseRadius reads a search radius in kilometres.
+func ParseRadius(value string) (float64, error) {
+	radius, ok := parseFloat(value)
+	if !ok || radius <= 0 || radius > MaxRadiusKm {
+		return 0, ErrInvalidRadius
+	}
+	return radius, nil
+}
+
+type BoundingBox struct {
+	MinLat, MinLng, MaxLat, MaxLng float64
+}
+
+// ParseBoundingBox reads a bbox in GeoJSON order: min_lng,min_lat,max_lng,max_lat.
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, ErrInvalidBoundingBox
+	}
+
+	var coords [4]float64
+	for i, part := range parts {
+		v, ok := parseFloat(part)
+		if !ok {
+			return BoundingBox{}, ErrInvalidBoundingBox
+		}
+		coords[i] = v
+	}
+
+	box := BoundingBox{MinLng: coords[0], MinLat: coords[1], MaxLng: coords[2], MaxLat: coords[3]}
+	if box.MinLat < -90 || box.MaxLat > 90 || box.MinLng < -180 || box.MaxLng > 180 ||
+		box.MinLat > box.MaxLat || box.MinLng > box.MaxLng {
+		return BoundingBox{}, ErrInvalidBoundingBox
+	}
+	return box, nil
+}
+
+// Around returns the smallest box containing every point within radiusKm of
+// the centre. It is used to narrow a radius search with the coordinate index.
+func Around(lat, lng, radiusKm float64) BoundingBox {
+	dLat := radiusKm / earthRadiusKm * 180 / math.Pi
+	dLng := 180.0
+	if cos := math.Cos(radians(lat)); cos > 1e-9 {
+		dLng = math.Min(dLat/cos, 180)
+	}
+	return BoundingBox{
+		MinLat: math.Max(lat-dLat, -90),
+		MaxLat: math.Min(lat+dLat, 90),
+		MinLng: math.Max(lng-dLng, -180),
+		MaxLng: math.Min(lng+dLng, 180),
+	}
+}
+
+// Within restricts q to rows whose latitude and longitude columns fall inside
+// the box.
+func (b BoundingBox) Within(q *bun.SelectQuery) *bun.SelectQuery {
+	return q.
+		Where("?TableAlias.latitude BETWEEN ? AND ?", b.MinLat, b.MaxLat).
+		Where("?TableAlias.longitude BETWEEN ? AND ?", b.MinLng, b.MaxLng)
+}
+
+// haversineSQL computes the distance in km from (?, ?) to the row.
+const haversineSQL = `2 * 6371 * asin(sqrt(
+	power(sin(radians(?TableAlias.latitude - ?) / 2), 2) +
+	cos(radians(?)) * cos(radians(?TableAlias.latitude)) *
+	power(sin(radians(?TableAlias.longitude - ?) / 2), 2)))`
+
+// Near restricts q to rows within radiusKm of the point, nearest first.
+func Near(q *bun.SelectQuery, lat, lng, radiusKm float64) *bun.SelectQuery {
+	return Around(lat, lng, radiusKm).Within(q).
+		Where(haversineSQL+" <= ?", lat, lat, lng, radiusKm).
+		OrderExpr(haversineSQL, lat, lat, lng)
+}
+
+type Geometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type Feature struct {
+	Type       string      `json:"type"`
+	ID         interface{} `json:"id"`
+	Geometry   Geometry    `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// NewFeatureCollection returns an empty collection ready for Add.
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+}
+
+// Add appends a point feature. The id must be unique within the collection
+// and a string or number. GeoJSON orders coordinates longitude first.
+func (c *FeatureCollection) Add(id interface{}, lat, lng float64, properties interface{}) {
+	c.Features = append(c.Features, Feature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   Geometry{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: properties,
+	})
+}
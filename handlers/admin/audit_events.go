@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// auditEventListSpec is the filter grammar of GET /admin/audit; see listquery.
+var auditEventListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"actor_id":      listquery.Int,
+		"actor_email":   listquery.Text,
+		"resource_type": listquery.Text,
+		"resource_id":   listquery.Text,
+		"action":        listquery.Text,
+		"status_code":   listquery.Int,
+		"created_at":    listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"created_at": "",
+	},
+	DefaultSort:  "-created_at",
+	DateField:    "created_at",
+	DefaultLimit: 50,
+	MaxLimit:     500,
+}
+
+func GetAuditEvents(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, err := listquery.Parse(r.URL.Query(), auditEventListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var events []models.AuditEvent
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&events)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch audit events")
+			return
+		}
+
+		events, page := listquery.Paginate(list, r, events, total, func(item models.AuditEvent) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       events,
+			"pagination": page,
+		})
+	}
+}
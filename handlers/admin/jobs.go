@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/audit"
+	"homeland/jobs"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+var jobListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"status":     listquery.Text,
+		"kind":       listquery.Text,
+		"run_at":     listquery.Time,
+		"created_at": listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"run_at":     "",
+		"created_at": "",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "created_at",
+}
+
+// GetJobs lists background jobs; status=dead shows the dead-letter queue.
+func GetJobs(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, err := listquery.Parse(r.URL.Query(), jobListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var found []models.Job
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&found)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch jobs")
+			return
+		}
+
+		found, page := listquery.Paginate(list, r, found, total, func(item models.Job) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       found,
+			"pagination": page,
+		})
+	}
+}
+
+// RetryJob puts a dead job back in the queue with a fresh set of attempts.
+func RetryJob(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid job ID")
+			return
+		}
+		audit.SetResource(r.Context(), "job", strconv.FormatInt(id, 10))
+
+		job, err := jobs.Retry(ctx, db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Dead job not found")
+			return
+		}
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retry job")
+			return
+		}
+
+		audit.SetAfter(r.Context(), job)
+		utils.RespondWithJSON(w, http.StatusOK, job)
+	}
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/audit"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func UnlockStaff(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		var staff models.Staff
+		res, err := db.NewUpdate().
+			Model(&staff).
+			Set("failed_logins = 0").
+			Set("locked_until = NULL").
+			Where("id = ?", staffID).
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlock account")
+			return
+		}
+
+		rowsAffected, _ := res.RowsAffected()
+		if rowsAffected == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "Staff not found")
+			return
+		}
+
+		audit.SetResource(r.Context(), "staff", strconv.FormatInt(staffID, 10))
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Account unlocked"})
+	}
+}
+
+// loginAttemptListSpec is the filter grammar of GET /admin/login-attempts; see
+// listquery.
+var loginAttemptListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"email":      listquery.Text,
+		"staff_id":   listquery.Int,
+		"ip_address": listquery.Text,
+		"success":    listquery.Bool,
+		"reason":     listquery.Text,
+		"created_at": listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"created_at": "",
+	},
+	DefaultSort:  "-created_at",
+	DateField:    "created_at",
+	DefaultLimit: 50,
+	MaxLimit:     500,
+}
+
+func GetLoginAttempts(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, err := listquery.Parse(r.URL.Query(), loginAttemptListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var attempts []models.LoginAttempt
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&attempts)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch login attempts")
+			return
+		}
+
+		attempts, page := listquery.Paginate(list, r, attempts, total, func(item models.LoginAttempt) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       attempts,
+			"pagination": page,
+		})
+	}
+}
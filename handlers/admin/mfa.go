@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"homeland/audit"
+	"homeland/mfa"
+	"homeland/models"
+	"homeland/sessions"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+// ResetStaffMFA removes a staff member's second factor, e.g. after a lost
+// phone, and signs them out so the next login goes through enrollment again.
+func ResetStaffMFA(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		exists, err := db.NewSelect().Model((*models.Staff)(nil)).Where("id = ?", staffID).Exists(r.Context())
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset two-factor authentication")
+			return
+		}
+		if !exists {
+			utils.RespondWithError(w, http.StatusNotFound, "Staff not found")
+			return
+		}
+
+		err = db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := mfa.Disable(ctx, tx, staffID); err != nil {
+				return err
+			}
+			_, err := sessions.RevokeAll(ctx, tx, staffID, sessions.ReasonAdminRevoke)
+			return err
+		})
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset two-factor authentication")
+			return
+		}
+
+		audit.SetResource(r.Context(), "staff", strconv.FormatInt(staffID, 10))
+		audit.SetAction(r.Context(), "reset_mfa")
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication reset"})
+	}
+}
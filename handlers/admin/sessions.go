@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"homeland/sessions"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+func GetStaffSessions(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		list, err := sessions.ListActive(r.Context(), db, staffID)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch sessions")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": list})
+	}
+}
+
+func RevokeStaffSession(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+
+		revoked, err := sessions.Revoke(r.Context(), db, staffID, sessionID, sessions.ReasonAdminRevoke)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+			return
+		}
+		if !revoked {
+			utils.RespondWithError(w, http.StatusNotFound, "Active session not found")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+	}
+}
+
+func RevokeAllStaffSessions(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		revoked, err := sessions.RevokeAll(r.Context(), db, staffID, sessions.ReasonAdminRevoke)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":  "Sessions revoked",
+			"sessions": revoked,
+		})
+	}
+}
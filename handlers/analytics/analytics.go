@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/analytics"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// filterFor parses the request and applies the caller's department scope.
+// Staff who see every department may narrow to one with ?department=; others
+// always get their own.
+func filterFor(w http.ResponseWriter, r *http.Request) (analytics.Filter, bool) {
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return analytics.Filter{}, false
+	}
+
+	filter, err := analytics.ParseFilter(r.URL.Query(), time.Now().UTC())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return analytics.Filter{}, false
+	}
+
+	department := r.URL.Query().Get("department")
+	if !models.SeesAllDepartments(models.RoleEnum(user.Role), models.DepartmentEnum(user.Department)) {
+		if department != "" && department != user.Department {
+			utils.RespondWithError(w, http.StatusForbidden, "You can only view analytics for your own department")
+			return analytics.Filter{}, false
+		}
+		department = user.Department
+	}
+	filter.Department = department
+
+	return filter, true
+}
+
+func respondWithStats(w http.ResponseWriter, filter analytics.Filter, stats interface{}) {
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"data": stats,
+		"filter": map[string]interface{}{
+			"from":       filter.From,
+			"to":         filter.To,
+			"interval":   filter.Interval,
+			"department": filter.Department,
+		},
+	})
+}
+
+// GetIncidentAnalytics aggregates incidents for the dashboard.
+func GetIncidentAnalytics(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		filter, ok := filterFor(w, r)
+		if !ok {
+			return
+		}
+
+		stats, err := analytics.Incidents(ctx, db, filter)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to compute incident analytics")
+			return
+		}
+
+		respondWithStats(w, filter, stats)
+	}
+}
+
+// GetReportAnalytics aggregates fire, EMS and AVS reports for the dashboard.
+func GetReportAnalytics(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		filter, ok := filterFor(w, r)
+		if !ok {
+			return
+		}
+
+		stats, err := analytics.Reports(ctx, db, filter)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to compute report analytics")
+			return
+		}
+
+		respondWithStats(w, filter, stats)
+	}
+}
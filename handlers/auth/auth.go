@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"homeland/config"
+	"homeland/mfa"
 	"homeland/models"
+	"homeland/sessions"
 	"homeland/utils"
 
 	"github.com/uptrace/bun"
@@ -26,6 +30,13 @@ type LoginResponse struct {
 	Data         interface{} `json:"data,omitempty"`
 }
 
+type MFAChallengeResponse struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
 type ErrorResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
@@ -54,67 +65,112 @@ func LoginHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		email := strings.ToLower(strings.TrimSpace(req.Email))
+
 		var staff models.Staff
-		err := db.NewSelect().Model(&staff).Where("email = ?", req.Email).Scan(r.Context())
+		err := db.NewSelect().Model(&staff).Where("LOWER(email) = ?", email).Scan(r.Context())
 		if err != nil {
-			log.Printf("User not found: %v", err)
+			compareDummyPassword(req.Password)
+			recordLoginAttempt(r.Context(), db, r, email, 0, false, "unknown_email")
 			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
 				Status:  "error",
-				Message: "Invalid email or password",
+				Message: invalidCredentialsMessage,
 			})
 			return
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(staff.Password), []byte(req.Password)); err != nil {
-			log.Printf("Incorrect password for user: %s", req.Email)
+		if staff.LockedUntil.After(time.Now()) {
+			compareDummyPassword(req.Password)
+			recordLoginAttempt(r.Context(), db, r, email, staff.ID, false, "locked")
 			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
 				Status:  "error",
-				Message: "Your Password is incorrect",
+				Message: invalidCredentialsMessage,
 			})
 			return
 		}
 
-		accessToken, err := utils.GenerateToken(staff.ID, staff.Email, string(staff.Role), cfg.JWTSecret)
-		if err != nil {
-			log.Printf("Failed to generate access token: %v", err)
-			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+		if err := bcrypt.CompareHashAndPassword([]byte(staff.Password), []byte(req.Password)); err != nil {
+			recordFailedLogin(r.Context(), db, &staff)
+			recordLoginAttempt(r.Context(), db, r, email, staff.ID, false, "bad_password")
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
 				Status:  "error",
-				Message: "Failed to generate access token",
+				Message: invalidCredentialsMessage,
 			})
 			return
 		}
 
-		refreshToken, err := utils.GenerateRefreshToken(staff.ID, cfg.JWTSecret)
-		if err != nil {
-			log.Printf("Failed to generate refresh token: %v", err)
-			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
-				Status:  "error",
-				Message: "Failed to generate refresh token",
+		if staff.MFAEnabled {
+			token, err := mfa.CreateChallenge(r.Context(), db, staff.ID, r.UserAgent(), utils.ClientIP(r))
+			if err != nil {
+				log.Printf("Failed to create MFA challenge: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+					Status:  "error",
+					Message: "Failed to start two-factor authentication",
+				})
+				return
+			}
+
+			recordLoginAttempt(r.Context(), db, r, email, staff.ID, false, "mfa_challenge")
+			jsonResponse(w, http.StatusOK, MFAChallengeResponse{
+				Status:    "mfa_required",
+				Message:   "Enter the code from your authenticator app or a recovery code",
+				MFAToken:  token,
+				ExpiresIn: int(mfa.ChallengeTTL.Seconds()),
 			})
 			return
 		}
 
-		jsonResponse(w, http.StatusOK, LoginResponse{
-			Status:       "success",
-			Message:      "Login successful",
-			AccessToken:  accessToken,
-			RefreshToken: refreshToken,
-			Data: map[string]interface{}{
-				"id":               staff.ID,
-				"first_name":       staff.FirstName,
-				"middle_name":      staff.MiddleName,
-				"last_name":        staff.LastName,
-				"email":            staff.Email,
-				"agent_id":         staff.AgentID,
-				"profile_photo":    staff.ProfilePhoto,
-				"position":         staff.Position,
-				"address":          staff.Address,
-				"department":       staff.Department,
-				"date_of_birth":    staff.DateOfBirth.Format("2006-01-02"),
-				"state_of_origin":  staff.StateOfOrigin,
-				"role":             staff.Role,
-				"password_changed": staff.MustChangePassword,
-			},
+		resetFailedLogins(r.Context(), db, &staff)
+		recordLoginAttempt(r.Context(), db, r, email, staff.ID, true, "")
+		completeLogin(w, r, db, cfg, &staff)
+	}
+}
+
+// completeLogin opens a session for an authenticated staff member and responds
+// with its tokens.
+func completeLogin(w http.ResponseWriter, r *http.Request, db *bun.DB, cfg *config.Config, staff *models.Staff) {
+	session, refreshToken, err := sessions.Create(r.Context(), db, staff.ID, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		log.Printf("Failed to create session: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Status:  "error",
+			Message: "Failed to generate refresh token",
 		})
+		return
 	}
+
+	accessToken, err := accessTokenFor(staff, session.ID, cfg)
+	if err != nil {
+		log.Printf("Failed to generate access token: %v", err)
+		jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Status:  "error",
+			Message: "Failed to generate access token",
+		})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, LoginResponse{
+		Status:       "success",
+		Message:      "Login successful",
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Data: map[string]interface{}{
+			"id":               staff.ID,
+			"first_name":       staff.FirstName,
+			"middle_name":      staff.MiddleName,
+			"last_name":        staff.LastName,
+			"email":            staff.Email,
+			"agent_id":         staff.AgentID,
+			"profile_photo":    staff.ProfilePhoto,
+			"position":         staff.Position,
+			"address":          staff.Address,
+			"department":       staff.Department,
+			"date_of_birth":    staff.DateOfBirth.Format("2006-01-02"),
+			"state_of_origin":  staff.StateOfOrigin,
+			"role":             staff.Role,
+			"password_changed": !staff.MustChangePassword,
+			"mfa_enabled":      staff.MFAEnabled,
+			"mfa_required":     mfa.Required(cfg, staff.Role),
+		},
+	})
 }
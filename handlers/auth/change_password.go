@@ -1,11 +1,16 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"homeland/config"
 	"homeland/models"
+	"homeland/passwordpolicy"
 	"homeland/utils"
 
 	"github.com/uptrace/bun"
@@ -17,10 +22,33 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password"`
 }
 
+type PolicyErrorResponse struct {
+	Status     string                    `json:"status"`
+	Message    string                    `json:"message"`
+	Violations passwordpolicy.Violations `json:"violations"`
+}
+
+// respondWithPolicyError writes a 422 listing every rule the password broke
+// and reports whether err was a policy rejection.
+func respondWithPolicyError(w http.ResponseWriter, err error) bool {
+	var violations passwordpolicy.Violations
+	if !errors.As(err, &violations) {
+		return false
+	}
+	jsonResponse(w, http.StatusUnprocessableEntity, PolicyErrorResponse{
+		Status:     "error",
+		Message:    "Password does not meet the password policy",
+		Violations: violations,
+	})
+	return true
+}
+
 func ChangePasswordHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	policy := passwordpolicy.New(cfg)
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := utils.GetUserIDFromContext(r.Context())
-		if err != nil {
+		claims := utils.GetUserFromContext(r.Context())
+		if claims == nil {
 			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
 				Status:  "error",
 				Message: "Unauthorized",
@@ -37,16 +65,16 @@ func ChangePasswordHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		if len(req.NewPassword) < 8 {
+		if req.NewPassword == req.OldPassword {
 			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
 				Status:  "error",
-				Message: "New password must be at least 8 characters long",
+				Message: "New password must differ from the current one",
 			})
 			return
 		}
 
 		var staff models.Staff
-		err = db.NewSelect().Model(&staff).Where("id = ?", userID).Scan(r.Context())
+		err := db.NewSelect().Model(&staff).Where("id = ?", claims.UserID).Scan(r.Context())
 		if err != nil {
 			jsonResponse(w, http.StatusNotFound, ErrorResponse{
 				Status:  "error",
@@ -63,6 +91,18 @@ func ChangePasswordHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		if err := policy.Check(r.Context(), db, "new_password", req.NewPassword, &staff); err != nil {
+			if respondWithPolicyError(w, err) {
+				return
+			}
+			log.Printf("Failed to check password policy: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to validate new password",
+			})
+			return
+		}
+
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 		if err != nil {
 			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
@@ -72,13 +112,21 @@ func ChangePasswordHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		_, err = db.NewUpdate().
-			Model(&staff).
-			Set("password = ?", string(hashedPassword)).
-			Where("id = ?", userID).
-			Exec(r.Context())
-
+		err = db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.NewUpdate().
+				Model(&staff).
+				Set("password = ?", string(hashedPassword)).
+				Set("must_change_password = ?", false).
+				Set("updated_at = ?", time.Now()).
+				Where("id = ?", claims.UserID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			return policy.Record(ctx, tx, staff.ID, string(hashedPassword))
+		})
 		if err != nil {
+			log.Printf("Failed to update password: %v", err)
 			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
 				Status:  "error",
 				Message: "Failed to update password",
@@ -86,9 +134,23 @@ func ChangePasswordHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		// The caller's token may still carry the must-change-password flag, so
+		// hand back one that reflects the new state.
+		staff.MustChangePassword = false
+		accessToken, err := accessTokenFor(&staff, claims.SessionID, cfg)
+		if err != nil {
+			log.Printf("Failed to generate access token: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to generate access token",
+			})
+			return
+		}
+
 		jsonResponse(w, http.StatusOK, map[string]interface{}{
-			"status":  "success",
-			"message": "Password updated successfully",
+			"status":       "success",
+			"message":      "Password updated successfully",
+			"access_token": accessToken,
 		})
 	}
 }
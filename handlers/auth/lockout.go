@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxFailedLogins = 5
+	baseLockout     = time.Minute
+	maxLockout      = 24 * time.Hour
+)
+
+const invalidCredentialsMessage = "Invalid email or password"
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyPassword spends the same bcrypt work as a real check so response
+// timing does not reveal whether an email is registered.
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("homeland-dummy-password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// lockoutDuration doubles with every failure past the threshold, starting at
+// baseLockout and capped at maxLockout.
+func lockoutDuration(failures int) time.Duration {
+	if failures < maxFailedLogins {
+		return 0
+	}
+	duration := baseLockout
+	for i := maxFailedLogins; i < failures && duration < maxLockout; i++ {
+		duration *= 2
+	}
+	if duration > maxLockout {
+		duration = maxLockout
+	}
+	return duration
+}
+
+func recordFailedLogin(ctx context.Context, db *bun.DB, staff *models.Staff) {
+	var failures int
+	err := db.NewUpdate().
+		Model((*models.Staff)(nil)).
+		Set("failed_logins = failed_logins + 1").
+		Where("id = ?", staff.ID).
+		Returning("failed_logins").
+		Scan(ctx, &failures)
+	if err != nil {
+		log.Printf("Failed to record failed login for staff %d: %v", staff.ID, err)
+		return
+	}
+
+	if duration := lockoutDuration(failures); duration > 0 {
+		_, err := db.NewUpdate().
+			Model((*models.Staff)(nil)).
+			Set("locked_until = ?", time.Now().Add(duration)).
+			Where("id = ?", staff.ID).
+			Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to lock staff %d: %v", staff.ID, err)
+			return
+		}
+		log.Printf("Account %d locked for %s after %d failed logins", staff.ID, duration, failures)
+	}
+}
+
+func resetFailedLogins(ctx context.Context, db *bun.DB, staff *models.Staff) {
+	if staff.FailedLogins == 0 && staff.LockedUntil.IsZero() {
+		return
+	}
+	_, err := db.NewUpdate().
+		Model((*models.Staff)(nil)).
+		Set("failed_logins = 0").
+		Set("locked_until = NULL").
+		Where("id = ?", staff.ID).
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to reset failed logins for staff %d: %v", staff.ID, err)
+	}
+}
+
+func recordLoginAttempt(ctx context.Context, db *bun.DB, r *http.Request, email string, staffID int64, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		Email:     email,
+		StaffID:   staffID,
+		IPAddress: utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	}
+	if _, err := db.NewInsert().Model(&attempt).Exec(ctx); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+
+	"homeland/sessions"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+func LogoutHandler(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := utils.GetUserFromContext(r.Context())
+		if claims == nil {
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+				Status:  "error",
+				Message: "Unauthorized",
+			})
+			return
+		}
+
+		if _, err := sessions.Revoke(r.Context(), db, claims.UserID, claims.SessionID, sessions.ReasonLogout); err != nil {
+			log.Printf("Failed to revoke session %d: %v", claims.SessionID, err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to log out",
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "Logged out",
+		})
+	}
+}
+
+func LogoutAllHandler(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := utils.GetUserFromContext(r.Context())
+		if claims == nil {
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+				Status:  "error",
+				Message: "Unauthorized",
+			})
+			return
+		}
+
+		revoked, err := sessions.RevokeAll(r.Context(), db, claims.UserID, sessions.ReasonLogoutAll)
+		if err != nil {
+			log.Printf("Failed to revoke sessions for user %d: %v", claims.UserID, err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to log out all devices",
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":   "success",
+			"message":  "Logged out of all devices",
+			"sessions": revoked,
+		})
+	}
+}
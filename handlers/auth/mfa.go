@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"homeland/config"
+	"homeland/mfa"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+const qrCodeSize = 256
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginMFAHandler completes a login started by LoginHandler for an account
+// with two-factor authentication, exchanging the challenge token and a TOTP or
+// recovery code for a session.
+func LoginMFAHandler(db *bun.DB, cfg *config.Config, service *mfa.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req MFALoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid request payload",
+			})
+			return
+		}
+
+		staff, err := service.ResolveChallenge(r.Context(), db, req.MFAToken, req.Code)
+		if err != nil {
+			if errors.Is(err, mfa.ErrInvalidCode) {
+				recordFailedLogin(r.Context(), db, staff)
+				recordLoginAttempt(r.Context(), db, r, strings.ToLower(staff.Email), staff.ID, false, "bad_mfa_code")
+				jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+					Status:  "error",
+					Message: "Invalid authentication code",
+				})
+				return
+			}
+
+			if errors.Is(err, mfa.ErrInvalidChallenge) {
+				jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+					Status:  "error",
+					Message: "Two-factor challenge is invalid or has expired, please log in again",
+				})
+				return
+			}
+
+			log.Printf("Failed to resolve MFA challenge: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to verify authentication code",
+			})
+			return
+		}
+
+		if staff.LockedUntil.After(time.Now()) {
+			recordLoginAttempt(r.Context(), db, r, strings.ToLower(staff.Email), staff.ID, false, "locked")
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+				Status:  "error",
+				Message: invalidCredentialsMessage,
+			})
+			return
+		}
+
+		resetFailedLogins(r.Context(), db, staff)
+		recordLoginAttempt(r.Context(), db, r, strings.ToLower(staff.Email), staff.ID, true, "")
+		completeLogin(w, r, db, cfg, staff)
+	}
+}
+
+// currentStaff loads the caller's account, writing an error response and
+// returning nil if that fails.
+func currentStaff(w http.ResponseWriter, r *http.Request, db *bun.DB) (*utils.Claims, *models.Staff) {
+	claims := utils.GetUserFromContext(r.Context())
+	if claims == nil {
+		jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+			Status:  "error",
+			Message: "Unauthorized",
+		})
+		return nil, nil
+	}
+
+	var staff models.Staff
+	if err := db.NewSelect().Model(&staff).Where("id = ?", claims.UserID).Scan(r.Context()); err != nil {
+		jsonResponse(w, http.StatusNotFound, ErrorResponse{
+			Status:  "error",
+			Message: "User not found",
+		})
+		return nil, nil
+	}
+	return claims, &staff
+}
+
+// EnrollMFAHandler generates a new TOTP secret for the caller. It stays
+// inactive until ConfirmMFAHandler receives a code produced from it.
+func EnrollMFAHandler(db *bun.DB, service *mfa.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, staff := currentStaff(w, r, db)
+		if staff == nil {
+			return
+		}
+
+		if staff.MFAEnabled {
+			jsonResponse(w, http.StatusConflict, ErrorResponse{
+				Status:  "error",
+				Message: "Two-factor authentication is already enabled",
+			})
+			return
+		}
+
+		key, err := service.NewKey(staff.Email)
+		if err != nil {
+			log.Printf("Failed to generate TOTP key: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to start enrollment",
+			})
+			return
+		}
+
+		sealed, err := service.Seal(key.Secret())
+		if err != nil {
+			log.Printf("Failed to encrypt TOTP secret: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to start enrollment",
+			})
+			return
+		}
+
+		staff.MFASecret = sealed
+		staff.MFALastStep = 0
+		if _, err := db.NewUpdate().Model(staff).Column("mfa_secret", "mfa_last_step").WherePK().Exec(r.Context()); err != nil {
+			log.Printf("DB error: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to start enrollment",
+			})
+			return
+		}
+
+		var qr bytes.Buffer
+		if img, err := key.Image(qrCodeSize, qrCodeSize); err == nil {
+			png.Encode(&qr, img)
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":           "success",
+			"message":          "Scan the QR code, then confirm with a code from your authenticator app",
+			"secret":           key.Secret(),
+			"provisioning_uri": key.URL(),
+			"qr_code":          "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+		})
+	}
+}
+
+// ConfirmMFAHandler activates the pending secret once the caller proves their
+// authenticator produces valid codes, and returns one-time recovery codes.
+func ConfirmMFAHandler(db *bun.DB, cfg *config.Config, service *mfa.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req MFACodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid request payload",
+			})
+			return
+		}
+
+		claims, staff := currentStaff(w, r, db)
+		if staff == nil {
+			return
+		}
+
+		if staff.MFAEnabled {
+			jsonResponse(w, http.StatusConflict, ErrorResponse{
+				Status:  "error",
+				Message: "Two-factor authentication is already enabled",
+			})
+			return
+		}
+
+		step, err := service.VerifyTOTP(staff, strings.TrimSpace(req.Code))
+		if err != nil {
+			if errors.Is(err, mfa.ErrNotEnrolled) {
+				jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+					Status:  "error",
+					Message: "Start enrollment before confirming it",
+				})
+				return
+			}
+			if !errors.Is(err, mfa.ErrInvalidCode) {
+				log.Printf("Failed to verify TOTP code: %v", err)
+			}
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid authentication code",
+			})
+			return
+		}
+
+		var codes []string
+		err = db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			staff.MFAEnabled = true
+			staff.MFAEnrolledAt = time.Now()
+			staff.MFALastStep = step
+			_, err := tx.NewUpdate().
+				Model(staff).
+				Column("mfa_enabled", "mfa_enrolled_at", "mfa_last_step").
+				WherePK().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			codes, err = mfa.GenerateRecoveryCodes(ctx, tx, staff.ID)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to enable MFA for staff %d: %v", staff.ID, err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to enable two-factor authentication",
+			})
+			return
+		}
+
+		// The caller's token may still be restricted to enrollment.
+		accessToken, err := accessTokenFor(staff, claims.SessionID, cfg)
+		if err != nil {
+			log.Printf("Failed to generate access token: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to generate access token",
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":         "success",
+			"message":        "Two-factor authentication enabled. Store the recovery codes somewhere safe; they will not be shown again",
+			"recovery_codes": codes,
+			"access_token":   accessToken,
+		})
+	}
+}
+
+// RegenerateRecoveryCodesHandler replaces the caller's recovery codes after
+// checking a current TOTP or recovery code.
+func RegenerateRecoveryCodesHandler(db *bun.DB, service *mfa.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req MFACodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid request payload",
+			})
+			return
+		}
+
+		_, staff := currentStaff(w, r, db)
+		if staff == nil {
+			return
+		}
+
+		var codes []string
+		err := db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := service.Authenticate(ctx, tx, staff, req.Code); err != nil {
+				return err
+			}
+
+			var err error
+			codes, err = mfa.GenerateRecoveryCodes(ctx, tx, staff.ID)
+			return err
+		})
+		if err != nil {
+			respondWithMFAError(w, err, "Failed to regenerate recovery codes")
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":         "success",
+			"message":        "Recovery codes regenerated; the previous codes no longer work",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableMFAHandler turns two-factor authentication off for the caller, unless
+// their role requires it.
+func DisableMFAHandler(db *bun.DB, cfg *config.Config, service *mfa.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req MFACodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid request payload",
+			})
+			return
+		}
+
+		_, staff := currentStaff(w, r, db)
+		if staff == nil {
+			return
+		}
+
+		if mfa.Required(cfg, staff.Role) {
+			jsonResponse(w, http.StatusForbidden, ErrorResponse{
+				Status:  "error",
+				Message: "Two-factor authentication is mandatory for your role",
+			})
+			return
+		}
+
+		err := db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := service.Authenticate(ctx, tx, staff, req.Code); err != nil {
+				return err
+			}
+			return mfa.Disable(ctx, tx, staff.ID)
+		})
+		if err != nil {
+			respondWithMFAError(w, err, "Failed to disable two-factor authentication")
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "Two-factor authentication disabled",
+		})
+	}
+}
+
+func respondWithMFAError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+			Status:  "error",
+			Message: "Two-factor authentication is not enabled",
+		})
+	case errors.Is(err, mfa.ErrInvalidCode):
+		jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+			Status:  "error",
+			Message: "Invalid authentication code",
+		})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Status:  "error",
+			Message: fallback,
+		})
+	}
+}
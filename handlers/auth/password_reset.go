@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"homeland/config"
+	"homeland/jobs"
+	"homeland/models"
+	"homeland/passwordpolicy"
+	"homeland/ratelimit"
+	"homeland/sessions"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+const forgotPasswordMessage = "If an account exists for that email, a password reset link has been sent"
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func passwordResetEmailBody(staff *models.Staff, token string, cfg *config.Config) string {
+	link := token
+	if cfg.PasswordResetURL != "" {
+		link = cfg.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	}
+
+	return fmt.Sprintf(`Hello %s,
+
+A password reset was requested for your account. Use the link below within %d minutes to choose a new password:
+
+%s
+
+If you did not request this, you can ignore this email and your password will stay the same.
+`, staff.FirstName, int(passwordResetTTL.Minutes()), link)
+}
+
+// KindPasswordReset is the job that issues and emails a reset token.
+const KindPasswordReset = "auth.password_reset"
+
+type passwordResetJob struct {
+	Email     string `json:"email"`
+	IPAddress string `json:"ip_address"`
+}
+
+// ForgotPasswordHandler queues a reset email for the account with the given
+// address. Looking the account up is left to the job, so the request does the
+// same work, and takes as long, whether or not the account exists.
+func ForgotPasswordHandler(db *bun.DB, cfg *config.Config, limiter *ratelimit.Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ForgotPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid request payload",
+			})
+			return
+		}
+
+		email := strings.ToLower(strings.TrimSpace(req.Email))
+		if ok, _ := limiter.Allow(email); ok {
+			payload := passwordResetJob{Email: email, IPAddress: utils.ClientIP(r)}
+			// A reset link arriving long after it was asked for is of no use,
+			// so delivery is only retried briefly.
+			if _, err := jobs.Enqueue(r.Context(), db, KindPasswordReset, payload, jobs.MaxAttempts(3)); err != nil {
+				log.Printf("Failed to queue password reset: %v", err)
+			}
+		} else {
+			log.Printf("Password reset for %s throttled", email)
+		}
+
+		jsonResponse(w, http.StatusAccepted, map[string]interface{}{
+			"status":  "success",
+			"message": forgotPasswordMessage,
+		})
+	}
+}
+
+// SendPasswordReset runs KindPasswordReset jobs: it issues a reset token for
+// the account with the job's address, if there is one, and emails it.
+func SendPasswordReset(db *bun.DB, cfg *config.Config) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var req passwordResetJob
+		if err := json.Unmarshal(job.Payload, &req); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var staff models.Staff
+		err := db.NewSelect().Model(&staff).Where("LOWER(email) = ?", req.Email).Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !utils.EmailConfigured(cfg) {
+			log.Printf("Password reset for staff %d not sent: %v", staff.ID, utils.ErrEmailNotConfigured)
+			return nil
+		}
+
+		token, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			return err
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			// Only the most recently requested token is usable.
+			_, err := tx.NewUpdate().
+				Model((*models.PasswordReset)(nil)).
+				Set("used_at = ?", time.Now()).
+				Where("staff_id = ?", staff.ID).
+				Where("used_at IS NULL").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.NewInsert().Model(&models.PasswordReset{
+				StaffID:   staff.ID,
+				TokenHash: utils.HashToken(token),
+				ExpiresAt: time.Now().Add(passwordResetTTL),
+				IPAddress: req.IPAddress,
+			}).Exec(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		return utils.SendEmail(ctx, cfg, staff.Email, "Reset your Homeland password", passwordResetEmailBody(&staff, token, cfg))
+	}
+}
+
+// ResetPasswordHandler sets a new password for the owner of a valid reset
+// token, consumes the token and signs the owner out everywhere.
+func ResetPasswordHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	policy := passwordpolicy.New(cfg)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid request payload",
+			})
+			return
+		}
+
+		err := db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			var reset models.PasswordReset
+			err := tx.NewSelect().
+				Model(&reset).
+				Where("token_hash = ?", utils.HashToken(req.Token)).
+				For("UPDATE").
+				Scan(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errInvalidResetToken
+			}
+			if err != nil {
+				return err
+			}
+			if !reset.UsedAt.IsZero() || time.Now().After(reset.ExpiresAt) {
+				return errInvalidResetToken
+			}
+
+			reset.UsedAt = time.Now()
+			if _, err := tx.NewUpdate().Model(&reset).Column("used_at").WherePK().Exec(ctx); err != nil {
+				return err
+			}
+
+			var staff models.Staff
+			if err := tx.NewSelect().Model(&staff).Where("id = ?", reset.StaffID).Scan(ctx); err != nil {
+				return err
+			}
+
+			if err := policy.Check(ctx, tx, "new_password", req.NewPassword, &staff); err != nil {
+				return err
+			}
+
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.NewUpdate().
+				Model((*models.Staff)(nil)).
+				Set("password = ?", string(hashedPassword)).
+				Set("must_change_password = ?", false).
+				Set("failed_logins = 0").
+				Set("locked_until = NULL").
+				Set("updated_at = ?", time.Now()).
+				Where("id = ?", reset.StaffID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := policy.Record(ctx, tx, staff.ID, string(hashedPassword)); err != nil {
+				return err
+			}
+
+			_, err = sessions.RevokeAll(ctx, tx, reset.StaffID, sessions.ReasonPasswordReset)
+			return err
+		})
+		if err != nil {
+			if respondWithPolicyError(w, err) {
+				return
+			}
+
+			if errors.Is(err, errInvalidResetToken) {
+				jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+					Status:  "error",
+					Message: "Invalid or expired reset token",
+				})
+				return
+			}
+
+			log.Printf("Failed to reset password: %v", err)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Failed to reset password",
+			})
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "Password has been reset, please log in with your new password",
+		})
+	}
+}
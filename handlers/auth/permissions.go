@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"net/http"
+
+	"homeland/middleware"
+	"homeland/rbac"
+)
+
+type PermissionsResponse struct {
+	Status      string       `json:"status"`
+	Subject     rbac.Subject `json:"subject"`
+	Permissions []string     `json:"permissions"`
+}
+
+func MyPermissionsHandler(policy *rbac.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middleware.FromContext(r)
+		if claims == nil {
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+				Status:  "error",
+				Message: "Unauthorized",
+			})
+			return
+		}
+
+		subject := middleware.SubjectFromClaims(claims)
+		jsonResponse(w, http.StatusOK, PermissionsResponse{
+			Status:      "success",
+			Subject:     subject,
+			Permissions: policy.Permissions(subject),
+		})
+	}
+}
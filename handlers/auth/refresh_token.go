@@ -2,11 +2,17 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"strconv"
 
 	"homeland/config"
+	"homeland/mfa"
+	"homeland/models"
+	"homeland/sessions"
 	"homeland/utils"
+
+	"github.com/uptrace/bun"
 )
 
 type RefreshRequest struct {
@@ -14,37 +20,79 @@ type RefreshRequest struct {
 }
 
 type RefreshResponse struct {
-	Status      string `json:"status"`
-	Message     string `json:"message"`
-	AccessToken string `json:"access_token"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func accessTokenFor(staff *models.Staff, sessionID int64, cfg *config.Config) (string, error) {
+	return utils.GenerateToken(utils.Claims{
+		UserID:             staff.ID,
+		Email:              staff.Email,
+		Role:               string(staff.Role),
+		Department:         string(staff.Department),
+		Position:           string(staff.Position),
+		SessionID:          sessionID,
+		MustChangePassword: staff.MustChangePassword,
+		MFAEnrollment:      mfa.EnrollmentPending(cfg, staff),
+	}, cfg.JWTSecret)
 }
 
-func RefreshTokenHandler(cfg *config.Config) http.HandlerFunc {
+func RefreshTokenHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req RefreshRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, `{"status":"error","message":"Invalid payload"}`, http.StatusBadRequest)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+			jsonResponse(w, http.StatusBadRequest, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid payload",
+			})
 			return
 		}
 
-		claims, err := utils.ValidateRefreshToken(req.RefreshToken, cfg.JWTSecret)
+		session, refreshToken, err := sessions.Rotate(r.Context(), db, req.RefreshToken)
 		if err != nil {
-			http.Error(w, `{"status":"error","message":"Invalid or expired refresh token"}`, http.StatusUnauthorized)
+			if errors.Is(err, sessions.ErrTokenReused) {
+				log.Printf("Refresh token reuse detected; session revoked")
+			} else if !errors.Is(err, sessions.ErrInvalidToken) && !errors.Is(err, sessions.ErrSessionRevoked) {
+				log.Printf("Failed to rotate refresh token: %v", err)
+				jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+					Status:  "error",
+					Message: "Could not refresh session",
+				})
+				return
+			}
+
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+				Status:  "error",
+				Message: "Invalid or expired refresh token",
+			})
+			return
+		}
+
+		var staff models.Staff
+		if err := db.NewSelect().Model(&staff).Where("id = ?", session.StaffID).Scan(r.Context()); err != nil {
+			jsonResponse(w, http.StatusUnauthorized, ErrorResponse{
+				Status:  "error",
+				Message: "User not found",
+			})
 			return
 		}
 
-		userID, _ := strconv.ParseInt(claims.Subject, 10, 64)
-		newAccessToken, err := utils.GenerateToken(userID, "", "", cfg.JWTSecret)
+		accessToken, err := accessTokenFor(&staff, session.ID, cfg)
 		if err != nil {
-			http.Error(w, `{"status":"error","message":"Could not generate new access token"}`, http.StatusInternalServerError)
+			jsonResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Status:  "error",
+				Message: "Could not generate new access token",
+			})
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(RefreshResponse{
-			Status:      "success",
-			Message:     "New access token generated",
-			AccessToken: newAccessToken,
+		jsonResponse(w, http.StatusOK, RefreshResponse{
+			Status:       "success",
+			Message:      "New access token generated",
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
 		})
 	}
 }
@@ -6,6 +6,7 @@ import (
 
 	"homeland/config"
 	"homeland/models"
+	"homeland/sessions"
 	"homeland/utils"
 
 	"github.com/uptrace/bun"
@@ -46,6 +47,14 @@ func AuthCheckHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		if active, err := sessions.IsActive(r.Context(), db, claims.SessionID); err != nil || !active {
+			jsonResponse(w, http.StatusUnauthorized, AuthResponse{
+				Status:  "error",
+				Message: "Session has ended",
+			})
+			return
+		}
+
 		userID := claims.UserID
 
 		var staff models.Staff
@@ -66,7 +75,7 @@ func AuthCheckHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 				"email":            staff.Email,
 				"agent_id":         staff.AgentID,
 				"role":             staff.Role,
-				"password_changed": staff.MustChangePassword,
+				"password_changed": !staff.MustChangePassword,
 			},
 		})
 	}
@@ -0,0 +1,268 @@
+package caller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/audit"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+type FlagRequest struct {
+	IsPrank  *bool  `json:"is_prank"`
+	IsRepeat *bool  `json:"is_repeat"`
+	Reason   string `json:"reason"`
+}
+
+// callerListSpec is the filter grammar of GET /callers; see listquery. phone,
+// prank and repeat are read by the handler.
+var callerListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"is_prank":       listquery.Bool,
+		"is_repeat":      listquery.Bool,
+		"incident_count": listquery.Int,
+		"last_seen_at":   listquery.Time,
+		"first_seen_at":  listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":             "",
+		"incident_count": "",
+		"first_seen_at":  "",
+		"last_seen_at":   "",
+	},
+	DefaultSort:  "-last_seen_at",
+	DateField:    "last_seen_at",
+	DefaultLimit: 20,
+}
+
+// callerIncidentListSpec is the filter grammar of GET /callers/{id}/incidents.
+var callerIncidentListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"incident_type": listquery.Text,
+		"severity":      listquery.Text,
+		"department":    listquery.Text,
+		"status":        listquery.Text,
+		"created_at":    listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"created_at": "",
+	},
+	DefaultSort:  "-created_at",
+	DateField:    "created_at",
+	DefaultLimit: 20,
+}
+
+// GetCallers looks a caller up by phone number in any format, or lists
+// callers, optionally only flagged ones.
+func GetCallers(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		q := r.URL.Query()
+		list, err := listquery.Parse(q, callerListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var found []models.Caller
+		query := db.NewSelect().Model(&found)
+
+		if phone := q.Get("phone"); phone != "" {
+			normalized, err := utils.NormalizePhoneNumber(phone)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid phone number")
+				return
+			}
+			query = query.Where("phone_number = ?", normalized)
+		}
+		if q.Get("prank") == "true" {
+			query = query.Where("is_prank")
+		}
+		if q.Get("repeat") == "true" {
+			query = query.Where("is_repeat")
+		}
+
+		total, err := list.Scan(ctx, list.Apply(query))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch callers")
+			return
+		}
+
+		found, page := listquery.Paginate(list, r, found, total, func(item models.Caller) pagination.Key {
+			return pagination.Key{Time: item.LastSeenAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       found,
+			"pagination": page,
+		})
+	}
+}
+
+func GetCaller(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid caller ID")
+			return
+		}
+
+		var caller models.Caller
+		if err := db.NewSelect().Model(&caller).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Caller not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch caller")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, caller)
+	}
+}
+
+// GetCallerIncidents lists every incident reported from the caller's number,
+// newest first.
+func GetCallerIncidents(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid caller ID")
+			return
+		}
+
+		list, err := listquery.Parse(r.URL.Query(), callerIncidentListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var caller models.Caller
+		if err := db.NewSelect().Model(&caller).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Caller not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch caller")
+			return
+		}
+
+		var incidents []models.Incident
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&incidents).Where("caller_id = ?", id)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch caller history")
+			return
+		}
+
+		incidents, page := listquery.Paginate(list, r, incidents, total, func(item models.Incident) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"caller":     caller,
+			"data":       incidents,
+			"pagination": page,
+		})
+	}
+}
+
+// FlagCaller sets or clears the prank and repeat flags on a caller.
+func FlagCaller(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid caller ID")
+			return
+		}
+
+		var req FlagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		if req.IsPrank == nil && req.IsRepeat == nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Set is_prank, is_repeat or both")
+			return
+		}
+		if req.IsPrank != nil && *req.IsPrank && req.Reason == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "A reason is required when flagging a prank caller")
+			return
+		}
+
+		var before models.Caller
+		if err := db.NewSelect().Model(&before).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Caller not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to flag caller")
+			return
+		}
+		audit.SetBefore(r.Context(), before)
+
+		now := time.Now()
+		caller := before
+		if req.IsPrank != nil {
+			caller.IsPrank = *req.IsPrank
+		}
+		if req.IsRepeat != nil {
+			caller.IsRepeat = *req.IsRepeat
+		}
+		// Setting only is_repeat keeps the reason given for a prank flag.
+		if req.IsPrank != nil || req.Reason != "" {
+			caller.FlagReason = req.Reason
+		}
+		caller.FlaggedBy = user.UserID
+		caller.FlaggedAt = now
+		caller.UpdatedAt = now
+
+		_, err = db.NewUpdate().
+			Model(&caller).
+			Column("is_prank", "is_repeat", "flag_reason", "flagged_by", "flagged_at", "updated_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to flag caller")
+			return
+		}
+
+		audit.SetAfter(r.Context(), caller)
+		utils.RespondWithJSON(w, http.StatusOK, caller)
+	}
+}
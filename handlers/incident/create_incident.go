@@ -3,8 +3,12 @@ package incident
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"homeland/callers"
+	"homeland/events"
 	"homeland/models"
 	"homeland/utils"
+	"log"
 	"net/http"
 
 	"github.com/uptrace/bun"
@@ -20,9 +24,10 @@ type CreateIncidentRequest struct {
 	CallerLocation    string                  `json:"caller_location"`
 	PeopleInvolved    int                     `json:"people_involved"`
 	IncidentReport    string                  `json:"incident_report"`
+	models.Geolocation
 }
 
-func CreateIncidentHandler(db *bun.DB) http.HandlerFunc {
+func CreateIncidentHandler(db *bun.DB, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req CreateIncidentRequest
 
@@ -31,6 +36,11 @@ func CreateIncidentHandler(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
+		if err := req.ValidateCoordinates(); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		var staff models.Staff
 		err := db.NewSelect().
 			Model(&staff).
@@ -54,14 +64,47 @@ func CreateIncidentHandler(db *bun.DB) http.HandlerFunc {
 			PeopleInvolved:    req.PeopleInvolved,
 			IncidentReport:    req.IncidentReport,
 			StaffID:           staff.ID,
+			Status:            models.StatusReported,
+			Geolocation:       req.Geolocation,
 		}
 
-		_, err = db.NewInsert().Model(&incident).Exec(context.Background())
+		var caller *callers.Profile
+		err = db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+			profile, err := callers.Link(ctx, tx, &incident)
+			if err != nil {
+				return err
+			}
+			caller = profile
+
+			if _, err := tx.NewInsert().Model(&incident).Exec(ctx); err != nil {
+				return err
+			}
+
+			history := models.IncidentStatusHistory{
+				IncidentID: incident.ID,
+				ToStatus:   models.StatusReported,
+				ChangedBy:  staff.ID,
+				Reason:     "Incident reported",
+			}
+			_, err = tx.NewInsert().Model(&history).Exec(ctx)
+			return err
+		})
 		if err != nil {
+			if errors.Is(err, utils.ErrInvalidPhoneNumber) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid caller phone number")
+				return
+			}
+			log.Printf("DB error: %v", err)
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create incident")
 			return
 		}
 
-		utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Incident created successfully"})
+		broker.Publish(events.IncidentCreated, incident, string(incident.Department))
+
+		utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Incident created successfully",
+			"data":    incident,
+			"caller":  caller,
+		})
 	}
 }
@@ -2,10 +2,16 @@ package incident
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"homeland/audit"
+	"homeland/callers"
+	"homeland/events"
 	"homeland/models"
 	"homeland/utils"
 
@@ -13,23 +19,45 @@ import (
 	"github.com/uptrace/bun"
 )
 
-func DeleteIncident(db *bun.DB) http.HandlerFunc {
+func DeleteIncident(db *bun.DB, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		id := chi.URLParam(r, "id")
-		if id == "" {
-			utils.RespondWithError(w, http.StatusBadRequest, "Incident ID is required")
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid incident ID")
 			return
 		}
 
-		res, err := db.NewDelete().
-			Model((*models.Incident)(nil)).
-			Where("id = ?", id).
-			Exec(ctx)
+		var incident models.Incident
+		var departments []string
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			err := tx.NewSelect().
+				Model(&incident).
+				Where("id = ?", id).
+				For("UPDATE").
+				Scan(ctx)
+			if err != nil {
+				return err
+			}
+			// Read the dispatches before the delete cascades to them.
+			if departments, err = eventDepartments(ctx, tx, &incident); err != nil {
+				return err
+			}
+			if _, err := tx.NewDelete().Model(&incident).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			// The incident no longer counts towards its caller's history.
+			return callers.Unlink(ctx, tx, incident.CallerID)
+		})
 
 		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
+				return
+			}
+
 			log.Printf("DB error: %v", err)
 
 			if ctx.Err() == context.DeadlineExceeded {
@@ -41,11 +69,9 @@ func DeleteIncident(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		rowsAffected, _ := res.RowsAffected()
-		if rowsAffected == 0 {
-			utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
-			return
-		}
+		audit.SetBefore(r.Context(), incident)
+
+		broker.Publish(events.IncidentDeleted, map[string]int64{"id": incident.ID}, departments...)
 
 		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Incident deleted successfully"})
 	}
@@ -0,0 +1,279 @@
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/events"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+type DispatchTarget struct {
+	Department models.DepartmentEnum `json:"department"`
+	Unit       string                `json:"unit"`
+}
+
+type DispatchRequest struct {
+	Targets []DispatchTarget `json:"targets"`
+	Notes   string           `json:"notes"`
+}
+
+var respondingDepartments = map[models.DepartmentEnum]bool{
+	models.DeptFireService: true,
+	models.DeptEMS:         true,
+	models.DeptAVS:         true,
+}
+
+var errNotDispatchable = errors.New("incident cannot be dispatched in its current status")
+
+// eventDepartments lists the departments that follow incident on the event
+// stream: the one it belongs to and every one it has been dispatched to.
+func eventDepartments(ctx context.Context, db bun.IDB, incident *models.Incident) ([]string, error) {
+	var dispatched []string
+	err := db.NewSelect().
+		Model((*models.Dispatch)(nil)).
+		ColumnExpr("DISTINCT department").
+		Where("incident_id = ?", incident.ID).
+		Where("department != ?", incident.Department).
+		Scan(ctx, &dispatched)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{string(incident.Department)}, dispatched...), nil
+}
+
+func DispatchIncident(db *bun.DB, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+			return
+		}
+
+		var req DispatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+
+		if len(req.Targets) == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "At least one department must be dispatched")
+			return
+		}
+		for _, target := range req.Targets {
+			if !respondingDepartments[target.Department] {
+				utils.RespondWithError(w, http.StatusBadRequest, "Incidents can only be dispatched to Fire Service, EMS or AVS")
+				return
+			}
+		}
+
+		var incident models.Incident
+		var dispatches []models.Dispatch
+		var departments []string
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			err := tx.NewSelect().
+				Model(&incident).
+				Where("id = ?", id).
+				For("UPDATE").
+				Scan(ctx)
+			if err != nil {
+				return err
+			}
+
+			switch incident.Status {
+			case models.StatusTriaged:
+				if err := recordStatusChange(ctx, tx, &incident, models.StatusDispatched, user.UserID, "Dispatched to responding departments"); err != nil {
+					return err
+				}
+			case models.StatusDispatched, models.StatusOnScene:
+			default:
+				return errNotDispatchable
+			}
+
+			for _, target := range req.Targets {
+				dispatches = append(dispatches, models.Dispatch{
+					IncidentID:   incident.ID,
+					Department:   target.Department,
+					Unit:         target.Unit,
+					Status:       models.DispatchPending,
+					Notes:        req.Notes,
+					DispatchedBy: user.UserID,
+				})
+			}
+
+			if _, err := tx.NewInsert().Model(&dispatches).Exec(ctx); err != nil {
+				return err
+			}
+			departments, err = eventDepartments(ctx, tx, &incident)
+			return err
+		})
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
+				return
+			}
+
+			if errors.Is(err, errNotDispatchable) {
+				utils.RespondWithError(w, http.StatusConflict, "Incident must be triaged before it can be dispatched")
+				return
+			}
+
+			log.Printf("DB error: %v", err)
+
+			if ctx.Err() == context.DeadlineExceeded {
+				utils.RespondWithError(w, http.StatusGatewayTimeout, "Database request timed out")
+				return
+			}
+
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to dispatch incident")
+			return
+		}
+
+		broker.Publish(events.IncidentUpdated, map[string]interface{}{
+			"incident":   incident,
+			"dispatches": dispatches,
+		}, departments...)
+
+		utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{"data": dispatches})
+	}
+}
+
+func GetDispatchQueue(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		query := db.NewSelect().
+			Model((*models.Dispatch)(nil)).
+			Relation("Incident").
+			Where("dispatch.department = ?", user.Department).
+			Order("dispatch.created_at DESC")
+
+		if status := r.URL.Query().Get("status"); status != "" {
+			query = query.Where("dispatch.status = ?", status)
+		} else {
+			query = query.Where("dispatch.status != ?", models.DispatchCompleted)
+		}
+
+		var dispatches []models.Dispatch
+		if err := query.Scan(ctx, &dispatches); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch dispatch queue")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": dispatches})
+	}
+}
+
+func AcknowledgeDispatch(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid dispatch ID")
+			return
+		}
+
+		var dispatch models.Dispatch
+		res, err := db.NewUpdate().
+			Model(&dispatch).
+			Set("status = ?", models.DispatchAcknowledged).
+			Set("acknowledged_by = ?", user.UserID).
+			Set("acknowledged_at = ?", time.Now()).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", id).
+			Where("department = ?", user.Department).
+			Where("status = ?", models.DispatchPending).
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to acknowledge dispatch")
+			return
+		}
+
+		rowsAffected, _ := res.RowsAffected()
+		if rowsAffected == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "No pending dispatch found for your department")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, dispatch)
+	}
+}
+
+func GetIncidentChain(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+			return
+		}
+
+		var incident models.Incident
+		err = db.NewSelect().
+			Model(&incident).
+			Relation("Staff").
+			Relation("Dispatches", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("created_at ASC")
+			}).
+			Relation("Reports", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("date_reported ASC")
+			}).
+			Relation("History", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("created_at ASC", "id ASC")
+			}).
+			Where("incident.id = ?", id).
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
+				return
+			}
+
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident chain")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, incident)
+	}
+}
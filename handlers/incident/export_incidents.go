@@ -0,0 +1,86 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/export"
+	"homeland/listquery"
+	"homeland/middleware"
+	"homeland/models"
+	"homeland/rbac"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// incidentColumns lists the exported incident fields. Caller phone numbers
+// are masked for staff who may not read caller records.
+func incidentColumns(showPhone bool) []export.Column[models.Incident] {
+	return []export.Column[models.Incident]{
+		{Header: "id", Value: func(i *models.Incident) interface{} { return i.ID }},
+		{Header: "created_at", Value: func(i *models.Incident) interface{} { return i.CreatedAt }},
+		{Header: "status", Value: func(i *models.Incident) interface{} { return string(i.Status) }},
+		{Header: "incident_type", Value: func(i *models.Incident) interface{} { return string(i.IncidentType) }},
+		{Header: "severity", Value: func(i *models.Incident) interface{} { return string(i.Severity) }},
+		{Header: "department", Value: func(i *models.Incident) interface{} { return string(i.Department) }},
+		{Header: "agent_id", Value: func(i *models.Incident) interface{} { return i.AgentID }},
+		{Header: "staff_id", Value: func(i *models.Incident) interface{} { return i.StaffID }},
+		{Header: "caller_full_name", Value: func(i *models.Incident) interface{} { return i.CallerFullName }},
+		{Header: "caller_phone_number", Value: func(i *models.Incident) interface{} {
+			if showPhone {
+				return i.CallerPhoneNumber
+			}
+			return export.MaskPhone(i.CallerPhoneNumber)
+		}},
+		{Header: "caller_location", Value: func(i *models.Incident) interface{} { return i.CallerLocation }},
+		{Header: "lga", Value: func(i *models.Incident) interface{} { return i.LGA }},
+		{Header: "state", Value: func(i *models.Incident) interface{} { return i.State }},
+		{Header: "latitude", Value: func(i *models.Incident) interface{} { return export.Float(i.Latitude) }},
+		{Header: "longitude", Value: func(i *models.Incident) interface{} { return export.Float(i.Longitude) }},
+		{Header: "people_involved", Value: func(i *models.Incident) interface{} { return i.PeopleInvolved }},
+		{Header: "incident_report", Value: func(i *models.Incident) interface{} { return i.IncidentReport }},
+		{Header: "updated_at", Value: func(i *models.Incident) interface{} { return i.UpdatedAt }},
+	}
+}
+
+// ExportIncidents streams the incidents matching the list filters as CSV or
+// XLSX.
+func ExportIncidents(db *bun.DB, policy *rbac.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		format, err := export.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, err := listquery.Parse(r.URL.Query(), incidentListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		showPhone := policy.Allowed(middleware.SubjectFromClaims(user), "callers", "read")
+		query := list.Order(list.Filter(db.NewSelect().Model((*models.Incident)(nil))))
+
+		if err := export.Stream(ctx, w, db, query, format, "incidents", incidentColumns(showPhone)); err != nil {
+			if errors.Is(err, export.ErrTooManyRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export incidents")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package incident
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/geo"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+type nearbyIncident struct {
+	models.Incident
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// applyIncidentGeoFilters narrows a map query by the optional status, type,
+// from and to parameters.
+func applyIncidentGeoFilters(q *bun.SelectQuery, r *http.Request) *bun.SelectQuery {
+	params := r.URL.Query()
+	if status := params.Get("status"); status != "" {
+		q = q.Where("incident.status = ?", status)
+	}
+	if incidentType := params.Get("incident_type"); incidentType != "" {
+		q = q.Where("incident.incident_type = ?", incidentType)
+	}
+	if from, err := time.Parse(time.RFC3339, params.Get("from")); err == nil {
+		q = q.Where("incident.created_at >= ?", from)
+	}
+	if to, err := time.Parse(time.RFC3339, params.Get("to")); err == nil {
+		q = q.Where("incident.created_at < ?", to)
+	}
+	return q
+}
+
+func incidentFeatures(incidents []models.Incident) *geo.FeatureCollection {
+	collection := geo.NewFeatureCollection()
+	for _, incident := range incidents {
+		if incident.HasCoordinates() {
+			collection.Add(incident.ID, *incident.Latitude, *incident.Longitude, incident)
+		}
+	}
+	return collection
+}
+
+// GetIncidentsNear lists incidents within radius_km of lat/lng, nearest first.
+func GetIncidentsNear(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		params := r.URL.Query()
+		lat, lng, err := geo.ParsePoint(params.Get("lat"), params.Get("lng"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		radius, err := geo.ParseRadius(params.Get("radius_km"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var incidents []models.Incident
+		query := db.NewSelect().Model(&incidents)
+		query = geo.Near(applyIncidentGeoFilters(query, r), lat, lng, radius)
+		if err := query.Limit(geo.ParseLimit(r.URL.Query().Get("limit"))).Scan(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search incidents")
+			return
+		}
+
+		if utils.WantsGeoJSON(r) {
+			utils.RespondWithGeoJSON(w, http.StatusOK, incidentFeatures(incidents))
+			return
+		}
+
+		nearby := make([]nearbyIncident, len(incidents))
+		for i, incident := range incidents {
+			nearby[i] = nearbyIncident{
+				Incident:   incident,
+				DistanceKm: geo.DistanceKm(lat, lng, *incident.Latitude, *incident.Longitude),
+			}
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":      nearby,
+			"center":    map[string]float64{"lat": lat, "lng": lng},
+			"radius_km": radius,
+		})
+	}
+}
+
+// GetIncidentsWithin lists incidents inside the bbox query parameter.
+func GetIncidentsWithin(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		box, err := geo.ParseBoundingBox(r.URL.Query().Get("bbox"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var incidents []models.Incident
+		query := box.Within(applyIncidentGeoFilters(db.NewSelect().Model(&incidents), r)).
+			Order("incident.created_at DESC").
+			Limit(geo.ParseLimit(r.URL.Query().Get("limit")))
+		if err := query.Scan(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search incidents")
+			return
+		}
+
+		if utils.WantsGeoJSON(r) {
+			utils.RespondWithGeoJSON(w, http.StatusOK, incidentFeatures(incidents))
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": incidents})
+	}
+}
@@ -6,38 +6,62 @@ import (
 	"errors"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
+	"homeland/listquery"
 	"homeland/models"
+	"homeland/pagination"
 	"homeland/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
+// incidentListSpec is the filter grammar of GET /incidents; see listquery.
+var incidentListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"incident_type":   listquery.Text,
+		"severity":        listquery.Text,
+		"department":      listquery.Text,
+		"status":          listquery.Text,
+		"agent_id":        listquery.Text,
+		"staff_id":        listquery.Int,
+		"caller_id":       listquery.Int,
+		"people_involved": listquery.Int,
+		"lga":             listquery.Text,
+		"state":           listquery.Text,
+		"created_at":      listquery.Time,
+		"updated_at":      listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":              "",
+		"created_at":      "",
+		"updated_at":      "",
+		"incident_type":   "",
+		"department":      "",
+		"status":          "",
+		"people_involved": "",
+		"severity":        models.SeverityRankSQL,
+	},
+	DefaultSort: "-created_at",
+	DateField:   "created_at",
+	Search:      "?TableAlias.search_vector",
+}
+
 func GetIncidents(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil || limit <= 0 {
-			limit = 10
-		}
-
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-		if err != nil || offset < 0 {
-			offset = 0
+		list, err := listquery.Parse(r.URL.Query(), incidentListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
 		var incidents []models.Incident
 
-		err = db.NewSelect().Model(&incidents).
-			Limit(limit).
-			Offset(offset).
-			Order("date_reported DESC").
-			Scan(ctx)
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&incidents)))
 
 		if err != nil {
 			log.Printf("DB error: %v", err)
@@ -56,16 +80,13 @@ func GetIncidents(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		total, err := db.NewSelect().Model((*models.Incident)(nil)).Count(ctx)
-		if err != nil {
-			log.Printf("Count query error: %v", err)
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident count.")
-			return
-		}
+		incidents, page := listquery.Paginate(list, r, incidents, total, func(item models.Incident) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
 
 		response := map[string]interface{}{
 			"data":       incidents,
-			"pagination": map[string]int{"total": total, "limit": limit, "offset": offset},
+			"pagination": page,
 		}
 
 		utils.RespondWithJSON(w, http.StatusOK, response)
@@ -0,0 +1,247 @@
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/config"
+	"homeland/export"
+	"homeland/middleware"
+	"homeland/models"
+	"homeland/printout"
+	"homeland/rbac"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+// PrintIncident renders an incident as a PDF for filing, with its caller,
+// assigned staff, dispatches, status history and linked reports. As in
+// exports, the caller's phone number is masked for staff without
+// callers:read.
+func PrintIncident(db *bun.DB, cfg *config.Config, policy *rbac.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+			return
+		}
+
+		var incident models.Incident
+		err = db.NewSelect().
+			Model(&incident).
+			Relation("Staff").
+			Relation("Caller").
+			Relation("Dispatches", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("created_at ASC")
+			}).
+			Relation("Reports", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("date_reported ASC")
+			}).
+			Relation("History", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("created_at ASC", "id ASC")
+			}).
+			Where("incident.id = ?", id).
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
+				return
+			}
+
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident")
+			return
+		}
+
+		names, err := staffNames(ctx, db, &incident)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident")
+			return
+		}
+
+		// Count only what the caller had reported before this incident, so an
+		// older printout is not inflated by later calls.
+		var previous int
+		if incident.CallerID != 0 {
+			previous, err = db.NewSelect().
+				Model((*models.Incident)(nil)).
+				Where("caller_id = ?", incident.CallerID).
+				Where("created_at < ?", incident.CreatedAt).
+				Count(ctx)
+			if err != nil {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident")
+				return
+			}
+		}
+
+		showPhone := policy.Allowed(middleware.SubjectFromClaims(user), "callers", "read")
+		doc := incidentDocument(printout.HeaderFromConfig(cfg), &incident, previous, names, user.Email, showPhone)
+		if err := doc.Serve(w, fmt.Sprintf("incident-%d.pdf", incident.ID)); err != nil {
+			log.Printf("Print incident %d failed: %v", incident.ID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render incident")
+		}
+	}
+}
+
+// staffNames resolves the staff who dispatched, acknowledged or changed the
+// status of the incident.
+func staffNames(ctx context.Context, db *bun.DB, incident *models.Incident) (map[int64]string, error) {
+	var ids []int64
+	for _, d := range incident.Dispatches {
+		ids = append(ids, d.DispatchedBy)
+		if d.AcknowledgedBy != 0 {
+			ids = append(ids, d.AcknowledgedBy)
+		}
+	}
+	for _, h := range incident.History {
+		ids = append(ids, h.ChangedBy)
+	}
+
+	names := make(map[int64]string)
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	var staff []*models.Staff
+	err := db.NewSelect().
+		Model(&staff).
+		Column("id", "first_name", "middle_name", "last_name", "agent_id").
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range staff {
+		names[s.ID] = printout.StaffName(s)
+	}
+	return names, nil
+}
+
+// previous is the number of incidents the caller reported before this one.
+func incidentDocument(header printout.Header, incident *models.Incident, previous int, names map[int64]string, printedBy string, showPhone bool) *printout.Document {
+	doc := printout.New(header, "Incident Report", fmt.Sprintf("INC-%06d", incident.ID), printedBy)
+
+	doc.Section("Incident")
+	doc.Fields(
+		printout.Field{Label: "Type", Value: string(incident.IncidentType)},
+		printout.Field{Label: "Severity", Value: string(incident.Severity)},
+		printout.Field{Label: "Status", Value: string(incident.Status)},
+		printout.Field{Label: "Department", Value: string(incident.Department)},
+		printout.Field{Label: "People involved", Value: strconv.Itoa(incident.PeopleInvolved)},
+		printout.Field{Label: "Location", Value: incident.CallerLocation},
+		printout.Field{Label: "LGA / State", Value: printout.Join(nonEmpty(incident.LGA, incident.State))},
+		printout.Field{Label: "Coordinates", Value: printout.Coordinates(incident.Latitude, incident.Longitude)},
+		printout.Field{Label: "Reported", Value: printout.Time(incident.CreatedAt)},
+		printout.Field{Label: "Last updated", Value: printout.Time(incident.UpdatedAt)},
+	)
+
+	doc.Section("Caller")
+	phone := incident.CallerPhoneNumber
+	if !showPhone {
+		phone = export.MaskPhone(phone)
+	}
+	callerFields := []printout.Field{
+		{Label: "Name", Value: incident.CallerFullName},
+		{Label: "Phone number", Value: phone},
+	}
+	if c := incident.Caller; c != nil {
+		callerFields = append(callerFields,
+			printout.Field{Label: "Previous incidents", Value: strconv.Itoa(previous)},
+			printout.Field{Label: "First seen", Value: printout.Time(c.FirstSeenAt)},
+		)
+		if c.IsPrank {
+			callerFields = append(callerFields, printout.Field{Label: "Flagged", Value: c.FlagReason})
+		}
+	}
+	doc.Fields(callerFields...)
+
+	doc.Section("Assigned staff")
+	staffFields := []printout.Field{{Label: "Agent ID", Value: incident.AgentID}}
+	if s := incident.Staff; s != nil {
+		staffFields = append(staffFields,
+			printout.Field{Label: "Name", Value: printout.StaffName(s)},
+			printout.Field{Label: "Position", Value: string(s.Position)},
+			printout.Field{Label: "Department", Value: string(s.Department)},
+		)
+	}
+	doc.Fields(staffFields...)
+
+	doc.Section("Incident narrative")
+	doc.Text(incident.IncidentReport)
+
+	doc.Section("Dispatches")
+	var dispatches [][]string
+	for _, d := range incident.Dispatches {
+		dispatches = append(dispatches, []string{
+			printout.Time(d.CreatedAt), string(d.Department), d.Unit, string(d.Status),
+			names[d.DispatchedBy], printout.Time(d.AcknowledgedAt),
+		})
+	}
+	doc.Table(
+		[]string{"Dispatched", "Department", "Unit", "Status", "Dispatched by", "Acknowledged"},
+		[]float64{0.16, 0.17, 0.14, 0.13, 0.24, 0.16},
+		dispatches,
+	)
+
+	doc.Section("Status history")
+	var history [][]string
+	for _, h := range incident.History {
+		from := string(h.FromStatus)
+		if from == "" {
+			from = "-"
+		}
+		history = append(history, []string{
+			printout.Time(h.CreatedAt), from + " -> " + string(h.ToStatus), names[h.ChangedBy], h.Reason,
+		})
+	}
+	doc.Table(
+		[]string{"Changed", "Status", "Changed by", "Reason"},
+		[]float64{0.16, 0.26, 0.26, 0.32},
+		history,
+	)
+
+	doc.Section("Linked reports")
+	var reports [][]string
+	for _, rep := range incident.Reports {
+		reports = append(reports, []string{
+			fmt.Sprintf("%s #%d", rep.ReportType, rep.ID), rep.ReportName, rep.Severity, rep.Status, printout.Time(rep.DateReported),
+		})
+	}
+	doc.Table(
+		[]string{"Report", "Name", "Severity", "Status", "Filed"},
+		[]float64{0.14, 0.38, 0.14, 0.16, 0.18},
+		reports,
+	)
+
+	doc.Signatures("Prepared by", "Reviewed by (Supervisor)", "Received by")
+	return doc
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
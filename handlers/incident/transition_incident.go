@@ -0,0 +1,206 @@
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/events"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+type TransitionRequest struct {
+	Status models.IncidentStatusEnum `json:"status"`
+	Reason string                    `json:"reason"`
+}
+
+var (
+	errIllegalTransition = errors.New("illegal status transition")
+	errOtherDepartment   = errors.New("incident belongs to another department")
+)
+
+// recordStatusChange moves the incident to the given status and appends the
+// matching history row. It must run inside the caller's transaction so the two
+// writes cannot drift apart.
+func recordStatusChange(ctx context.Context, tx bun.Tx, incident *models.Incident, to models.IncidentStatusEnum, changedBy int64, reason string) error {
+	from := incident.Status
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", errIllegalTransition, from, to)
+	}
+
+	incident.Status = to
+	incident.UpdatedAt = time.Now()
+
+	_, err := tx.NewUpdate().
+		Model(incident).
+		Column("status", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	history := models.IncidentStatusHistory{
+		IncidentID: incident.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  changedBy,
+		Reason:     reason,
+	}
+	_, err = tx.NewInsert().Model(&history).Exec(ctx)
+	return err
+}
+
+// canTransition reports whether user may change the incident's status: staff
+// who see every department, the owning department, and any department the
+// incident has been dispatched to. It must run inside the transaction holding
+// the incident's row lock.
+func canTransition(ctx context.Context, tx bun.Tx, incident *models.Incident, user *utils.Claims) (bool, error) {
+	department := models.DepartmentEnum(user.Department)
+	if models.SeesAllDepartments(models.RoleEnum(user.Role), department) || incident.Department == department {
+		return true, nil
+	}
+	return tx.NewSelect().
+		Model((*models.Dispatch)(nil)).
+		Where("incident_id = ?", incident.ID).
+		Where("department = ?", department).
+		Exists(ctx)
+}
+
+func TransitionIncident(db *bun.DB, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+			return
+		}
+
+		var req TransitionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+
+		if !req.Status.IsValid() {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown incident status")
+			return
+		}
+
+		var incident models.Incident
+		var departments []string
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			err := tx.NewSelect().
+				Model(&incident).
+				Where("id = ?", id).
+				For("UPDATE").
+				Scan(ctx)
+			if err != nil {
+				return err
+			}
+			allowed, err := canTransition(ctx, tx, &incident, user)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				return errOtherDepartment
+			}
+
+			if err := recordStatusChange(ctx, tx, &incident, req.Status, user.UserID, req.Reason); err != nil {
+				return err
+			}
+			departments, err = eventDepartments(ctx, tx, &incident)
+			return err
+		})
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
+				return
+			}
+
+			if errors.Is(err, errOtherDepartment) {
+				utils.RespondWithError(w, http.StatusForbidden, "You may only change the status of incidents owned by or dispatched to your department")
+				return
+			}
+
+			if errors.Is(err, errIllegalTransition) {
+				utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+					"error":   fmt.Sprintf("Cannot move incident from %s to %s", incident.Status, req.Status),
+					"allowed": incident.Status.AllowedTransitions(),
+				})
+				return
+			}
+
+			log.Printf("DB error: %v", err)
+
+			if ctx.Err() == context.DeadlineExceeded {
+				utils.RespondWithError(w, http.StatusGatewayTimeout, "Database request timed out")
+				return
+			}
+
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update incident status")
+			return
+		}
+
+		broker.Publish(events.IncidentUpdated, incident, departments...)
+
+		utils.RespondWithJSON(w, http.StatusOK, incident)
+	}
+}
+
+func GetIncidentHistory(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+			return
+		}
+
+		exists, err := db.NewSelect().Model((*models.Incident)(nil)).Where("id = ?", id).Exists(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident history")
+			return
+		}
+		if !exists {
+			utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
+			return
+		}
+
+		var history []models.IncidentStatusHistory
+		err = db.NewSelect().
+			Model(&history).
+			Where("incident_id = ?", id).
+			Order("created_at ASC", "id ASC").
+			Scan(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch incident history")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": history})
+	}
+}
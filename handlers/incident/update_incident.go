@@ -3,10 +3,14 @@ package incident
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"homeland/audit"
+	"homeland/callers"
+	"homeland/events"
 	"homeland/models"
 	"homeland/utils"
 
@@ -14,7 +18,7 @@ import (
 	"github.com/uptrace/bun"
 )
 
-func UpdateIncident(db *bun.DB) http.HandlerFunc {
+func UpdateIncident(db *bun.DB, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
@@ -31,12 +35,66 @@ func UpdateIncident(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		res, err := db.NewUpdate().
-			Model(&input).
-			Where("id = ?", id).
-			Exec(ctx)
+		if input.Status != "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Incident status cannot be changed here; use the transition endpoint")
+			return
+		}
+
+		if err := input.ValidateCoordinates(); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
+		// Compare numbers in their stored form, so the same number written
+		// another way keeps its caller.
+		phone, err := utils.NormalizePhoneNumber(input.CallerPhoneNumber)
 		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid caller phone number")
+			return
+		}
+		input.CallerPhoneNumber = phone
+
+		var before models.Incident
+		if err := db.NewSelect().Model(&before).Where("id = ?", id).Scan(ctx); err == nil {
+			audit.SetBefore(r.Context(), before)
+		}
+
+		var rowsAffected int64
+		var departments []string
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			// A corrected phone number moves the incident to another caller.
+			input.CallerID = before.CallerID
+			if before.ID != 0 && input.CallerPhoneNumber != before.CallerPhoneNumber {
+				if err := callers.Unlink(ctx, tx, before.CallerID); err != nil {
+					return err
+				}
+				if _, err := callers.Link(ctx, tx, &input); err != nil {
+					return err
+				}
+			}
+
+			res, err := tx.NewUpdate().
+				Model(&input).
+				ExcludeColumn("status").
+				Where("id = ?", id).
+				Returning("*").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if rowsAffected, _ = res.RowsAffected(); rowsAffected == 0 {
+				return nil
+			}
+			departments, err = eventDepartments(ctx, tx, &input)
+			return err
+		})
+
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidPhoneNumber) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid caller phone number")
+				return
+			}
+
 			log.Printf("DB error: %v", err)
 
 			if ctx.Err() == context.DeadlineExceeded {
@@ -48,12 +106,15 @@ func UpdateIncident(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		rowsAffected, _ := res.RowsAffected()
 		if rowsAffected == 0 {
 			utils.RespondWithError(w, http.StatusNotFound, "Incident not found")
 			return
 		}
 
+		audit.SetAfter(r.Context(), input)
+
+		broker.Publish(events.IncidentUpdated, input, departments...)
+
 		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Incident updated successfully"})
 	}
 }
@@ -0,0 +1,97 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"homeland/listquery"
+	"homeland/models"
+
+	"github.com/uptrace/bun"
+)
+
+// reportListSpec is the filter grammar shared by the report lists; see
+// listquery.
+var reportListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"severity":      listquery.Text,
+		"status":        listquery.Text,
+		"department":    listquery.Text,
+		"reported_by":   listquery.Text,
+		"incident_id":   listquery.Int,
+		"lga":           listquery.Text,
+		"state":         listquery.Text,
+		"date_reported": listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":            "",
+		"report_name":   "",
+		"status":        "",
+		"department":    "",
+		"date_reported": "",
+		"severity":      models.SeverityRankSQL,
+	},
+	DefaultSort: "-date_reported",
+	DateField:   "date_reported",
+	Search:      listquery.TextSearch("report_name", "location", "action_description"),
+}
+
+// summaryListSpec adds the report type to reportListSpec for the combined
+// list. Each report table numbers its rows separately, so a report is
+// identified by its type and id.
+var summaryListSpec = func() *listquery.Spec {
+	spec := *reportListSpec
+	spec.TieBreak = "report_type"
+	spec.Filters = map[string]listquery.Kind{"report_type": listquery.Text}
+	for field, kind := range reportListSpec.Filters {
+		spec.Filters[field] = kind
+	}
+	return &spec
+}()
+
+var (
+	errIncidentNotDispatched = errors.New("incident was not dispatched to this department")
+	errNoActiveDispatch      = errors.New("incident has no active dispatch for this department")
+)
+
+// createLinkedReport inserts a department report and, when it answers an
+// incident, checks the incident was dispatched to that department and marks
+// its active dispatches completed. Dispatches completed by an earlier report
+// are left alone.
+func createLinkedReport(ctx context.Context, db *bun.DB, report interface{}, incidentID int64, department models.DepartmentEnum) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if incidentID != 0 {
+			res, err := tx.NewUpdate().
+				Model((*models.Dispatch)(nil)).
+				Set("status = ?", models.DispatchCompleted).
+				Set("updated_at = ?", time.Now()).
+				Where("incident_id = ?", incidentID).
+				Where("department = ?", department).
+				Where("status IN (?)", bun.In(models.ActiveDispatchStatuses)).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			rowsAffected, _ := res.RowsAffected()
+			if rowsAffected == 0 {
+				dispatched, err := tx.NewSelect().
+					Model((*models.Dispatch)(nil)).
+					Where("incident_id = ?", incidentID).
+					Where("department = ?", department).
+					Exists(ctx)
+				if err != nil {
+					return err
+				}
+				if dispatched {
+					return errNoActiveDispatch
+				}
+				return errIncidentNotDispatched
+			}
+		}
+
+		_, err := tx.NewInsert().Model(report).Exec(ctx)
+		return err
+	})
+}
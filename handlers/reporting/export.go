@@ -0,0 +1,72 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"homeland/export"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+var reportColumns = []export.Column[models.Report]{
+	{Header: "id", Value: func(r *models.Report) interface{} { return r.ID }},
+	{Header: "report_type", Value: func(r *models.Report) interface{} { return string(r.ReportType) }},
+	{Header: "date_reported", Value: func(r *models.Report) interface{} { return r.DateReported }},
+	{Header: "report_name", Value: func(r *models.Report) interface{} { return r.ReportName }},
+	{Header: "status", Value: func(r *models.Report) interface{} { return r.Status }},
+	{Header: "severity", Value: func(r *models.Report) interface{} { return r.Severity }},
+	{Header: "department", Value: func(r *models.Report) interface{} { return r.Department }},
+	{Header: "reported_by", Value: func(r *models.Report) interface{} { return r.ReportedBy }},
+	{Header: "incident_id", Value: func(r *models.Report) interface{} {
+		if r.IncidentID == 0 {
+			return nil
+		}
+		return r.IncidentID
+	}},
+	{Header: "location", Value: func(r *models.Report) interface{} { return r.Location }},
+	{Header: "lga", Value: func(r *models.Report) interface{} { return r.LGA }},
+	{Header: "state", Value: func(r *models.Report) interface{} { return r.State }},
+	{Header: "latitude", Value: func(r *models.Report) interface{} { return export.Float(r.Latitude) }},
+	{Header: "longitude", Value: func(r *models.Report) interface{} { return export.Float(r.Longitude) }},
+	{Header: "action_description", Value: func(r *models.Report) interface{} { return r.ActionDescription }},
+	{Header: "photo_urls", Value: func(r *models.Report) interface{} { return strings.Join(r.PhotoUrls, " ") }},
+}
+
+// ExportReports streams fire, EMS and AVS reports matching the summary list
+// filters as CSV or XLSX.
+func ExportReports(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		format, err := export.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, err := listquery.Parse(r.URL.Query(), summaryListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		query := list.Order(list.Filter(db.NewSelect().Model((*models.Report)(nil))))
+
+		if err := export.Stream(ctx, w, db, query, format, "reports", reportColumns); err != nil {
+			if errors.Is(err, export.ErrTooManyRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export reports")
+		}
+	}
+}
@@ -3,42 +3,56 @@ package reporting
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
+	"homeland/events"
+	"homeland/listquery"
 	"homeland/models"
+	"homeland/pagination"
 	"homeland/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-func CreateFireReport(db *bun.DB) http.HandlerFunc {
+func CreateFireReport(db *bun.DB, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := utils.GetUserFromContext(r.Context())
 
-		if user.Department != string(models.DeptFireService) {
-			utils.RespondWithError(w, http.StatusForbidden, "Unauthorized: Only Fire Service can report incidents")
-			return
-		}
-
 		var report models.FireReport
 		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
+		if err := report.Validate(); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		report.ReportedBy = user.Email
 		report.DateReported = time.Now()
 		report.Department = string(models.DeptFireService)
 
-		_, err := db.NewInsert().Model(&report).Exec(context.Background())
+		err := createLinkedReport(context.Background(), db, &report, report.IncidentID, models.DeptFireService)
 		if err != nil {
+			if errors.Is(err, errIncidentNotDispatched) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Incident was not dispatched to your department")
+				return
+			}
+			if errors.Is(err, errNoActiveDispatch) {
+				utils.RespondWithError(w, http.StatusConflict, "Your department's dispatches to this incident are already completed")
+				return
+			}
+
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create fire report")
 			return
 		}
 
+		broker.Publish(events.ReportCreated, report, report.Department)
+
 		utils.RespondWithJSON(w, http.StatusCreated, report)
 	}
 }
@@ -48,44 +62,29 @@ func GetFireReports(db *bun.DB) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil || limit <= 0 {
-			limit = 10
-		}
-
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-		if err != nil || offset < 0 {
-			offset = 0
+		list, err := listquery.Parse(r.URL.Query(), reportListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
 		var reports []models.FireReport
 
-		err = db.NewSelect().Model(&reports).
-			Limit(limit).
-			Offset(offset).
-			Order("date_reported DESC").
-			Scan(ctx)
-
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&reports)))
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch fire reports")
 			return
 		}
 
-		total, err := db.NewSelect().Model((*models.FireReport)(nil)).Count(ctx)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve report count")
-			return
-		}
+		reports, page := listquery.Paginate(list, r, reports, total, func(item models.FireReport) pagination.Key {
+			return pagination.Key{Time: item.DateReported, ID: item.ID}
+		})
 
 		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-			"status":  "success",
-			"message": "Fire reports retrieved successfully",
-			"data":    reports,
-			"pagination": map[string]int{
-				"total":  total,
-				"limit":  limit,
-				"offset": offset,
-			},
+			"status":     "success",
+			"message":    "Fire reports retrieved successfully",
+			"data":       reports,
+			"pagination": page,
 		})
 	}
 }
@@ -0,0 +1,118 @@
+package reporting
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/geo"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+type nearbyReport struct {
+	models.Report
+	DistanceKm float64 `json:"distance_km"`
+}
+
+func applyReportGeoFilters(q *bun.SelectQuery, r *http.Request) *bun.SelectQuery {
+	if reportType := r.URL.Query().Get("report_type"); reportType != "" {
+		q = q.Where("report.report_type = ?", reportType)
+	}
+	if severity := r.URL.Query().Get("severity"); severity != "" {
+		q = q.Where("report.severity = ?", severity)
+	}
+	return q
+}
+
+func reportFeatures(reports []models.Report) *geo.FeatureCollection {
+	collection := geo.NewFeatureCollection()
+	for _, report := range reports {
+		if report.HasCoordinates() {
+			collection.Add(report.Key(), *report.Latitude, *report.Longitude, report)
+		}
+	}
+	return collection
+}
+
+// GetReportsNear lists reports of every type within radius_km of lat/lng,
+// nearest first.
+func GetReportsNear(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		params := r.URL.Query()
+		lat, lng, err := geo.ParsePoint(params.Get("lat"), params.Get("lng"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		radius, err := geo.ParseRadius(params.Get("radius_km"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var reports []models.Report
+		query := geo.Near(applyReportGeoFilters(db.NewSelect().Model(&reports), r), lat, lng, radius)
+		if err := query.Limit(geo.ParseLimit(r.URL.Query().Get("limit"))).Scan(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search reports")
+			return
+		}
+
+		if utils.WantsGeoJSON(r) {
+			utils.RespondWithGeoJSON(w, http.StatusOK, reportFeatures(reports))
+			return
+		}
+
+		nearby := make([]nearbyReport, len(reports))
+		for i, report := range reports {
+			nearby[i] = nearbyReport{
+				Report:     report,
+				DistanceKm: geo.DistanceKm(lat, lng, *report.Latitude, *report.Longitude),
+			}
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":      nearby,
+			"center":    map[string]float64{"lat": lat, "lng": lng},
+			"radius_km": radius,
+		})
+	}
+}
+
+// GetReportsWithin lists reports inside the bbox query parameter.
+func GetReportsWithin(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		box, err := geo.ParseBoundingBox(r.URL.Query().Get("bbox"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var reports []models.Report
+		query := box.Within(applyReportGeoFilters(db.NewSelect().Model(&reports), r)).
+			Order("report.date_reported DESC").
+			Limit(geo.ParseLimit(r.URL.Query().Get("limit")))
+		if err := query.Scan(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search reports")
+			return
+		}
+
+		if utils.WantsGeoJSON(r) {
+			utils.RespondWithGeoJSON(w, http.StatusOK, reportFeatures(reports))
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": reports})
+	}
+}
@@ -0,0 +1,137 @@
+package reporting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/config"
+	"homeland/models"
+	"homeland/printout"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+// printReport loads the report into model and renders it with the common
+// report fields followed by the type-specific ones returned by details.
+func printReport(db *bun.DB, cfg *config.Config, w http.ResponseWriter, r *http.Request, model interface{}, base *models.ReportBase, kind, title, prefix string, details func() []printout.Field) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	if err := db.NewSelect().Model(model).Where("?TableAlias.id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, kind+" report not found")
+			return
+		}
+		log.Printf("DB error: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch "+kind+" report")
+		return
+	}
+
+	reference := fmt.Sprintf("%s-%06d", prefix, base.ID)
+	doc := printout.New(printout.HeaderFromConfig(cfg), title, reference, user.Email)
+
+	doc.Section("Report")
+	incident := "-"
+	if base.IncidentID != 0 {
+		incident = fmt.Sprintf("INC-%06d", base.IncidentID)
+	}
+	area := make([]string, 0, 2)
+	for _, v := range []string{base.LGA, base.State} {
+		if v != "" {
+			area = append(area, v)
+		}
+	}
+	doc.Fields(
+		printout.Field{Label: "Name", Value: base.ReportName},
+		printout.Field{Label: "Severity", Value: base.Severity},
+		printout.Field{Label: "Status", Value: base.Status},
+		printout.Field{Label: "Department", Value: base.Department},
+		printout.Field{Label: "Reported by", Value: base.ReportedBy},
+		printout.Field{Label: "Date reported", Value: printout.Time(base.DateReported)},
+		printout.Field{Label: "Linked incident", Value: incident},
+		printout.Field{Label: "Location", Value: base.Location},
+		printout.Field{Label: "LGA / State", Value: printout.Join(area)},
+		printout.Field{Label: "Coordinates", Value: printout.Coordinates(base.Latitude, base.Longitude)},
+	)
+
+	doc.Section(kind + " details")
+	doc.Fields(details()...)
+
+	doc.Section("Action taken")
+	doc.Text(base.ActionDescription)
+
+	doc.Section("Photographs")
+	doc.Text(strings.Join(base.PhotoUrls, "\n"))
+
+	doc.Signatures("Prepared by", "Reviewed by (Supervisor)", "Received by")
+
+	if err := doc.Serve(w, strings.ToLower(reference)+".pdf"); err != nil {
+		log.Printf("Print %s failed: %v", reference, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render "+kind+" report")
+	}
+}
+
+// PrintFireReport renders a fire report as a PDF for filing.
+func PrintFireReport(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var report models.FireReport
+		printReport(db, cfg, w, r, &report, &report.ReportBase, "Fire", "Fire Incident Report", "FIRE", func() []printout.Field {
+			return []printout.Field{
+				{Label: "Cause", Value: report.Cause},
+				{Label: "Units deployed", Value: strconv.Itoa(report.UnitsDeployed)},
+				{Label: "Property damage estimate", Value: strconv.FormatFloat(report.PropertyDamageEstimate, 'f', 2, 64)},
+				{Label: "Casualties", Value: strconv.Itoa(report.Casualties)},
+			}
+		})
+	}
+}
+
+// PrintEMSReport renders an EMS report as a PDF for filing.
+func PrintEMSReport(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var report models.EMSReport
+		printReport(db, cfg, w, r, &report, &report.ReportBase, "EMS", "Emergency Medical Services Report", "EMS", func() []printout.Field {
+			return []printout.Field{
+				{Label: "Patients", Value: strconv.Itoa(report.Patients)},
+				{Label: "Triage category", Value: string(report.TriageCategory)},
+				{Label: "Transported to", Value: report.HospitalTransportedTo},
+			}
+		})
+	}
+}
+
+// PrintAVSReport renders a road traffic (AVS) report as a PDF for filing.
+func PrintAVSReport(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var report models.AVSReport
+		printReport(db, cfg, w, r, &report, &report.ReportBase, "AVS", "Road Traffic Accident Report", "AVS", func() []printout.Field {
+			return []printout.Field{
+				{Label: "Vehicles involved", Value: strconv.Itoa(report.VehiclesInvolved)},
+				{Label: "Plate numbers", Value: printout.Join(report.PlateNumbers)},
+				{Label: "Road", Value: report.Road},
+				{Label: "Injuries", Value: strconv.Itoa(report.Injuries)},
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package reporting
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+func GetReportSummaries(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, err := listquery.Parse(r.URL.Query(), summaryListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var reports []models.Report
+
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&reports)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch report summaries")
+			return
+		}
+
+		reports, page := listquery.Paginate(list, r, reports, total, func(item models.Report) pagination.Key {
+			return pagination.Key{Time: item.DateReported, ID: item.ID, Tie: string(item.ReportType)}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "success",
+			"message":    "Report summaries retrieved successfully",
+			"data":       reports,
+			"pagination": page,
+		})
+	}
+}
@@ -2,22 +2,47 @@ package staff
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"homeland/audit"
 	"homeland/models"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
 )
 
+// foreignKeyViolation is the Postgres error code for a delete blocked by a
+// row that still references the one being deleted.
+const foreignKeyViolation = "23503"
+
 func DeleteStaffHandler(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		staffID := chi.URLParam(r, "id")
 
-		_, err := db.NewDelete().Model((*models.Staff)(nil)).Where("id = ?", staffID).Exec(r.Context())
+		var staff models.Staff
+		res, err := db.NewDelete().Model(&staff).Where("id = ?", staffID).Returning("*").Exec(r.Context())
+		var pgErr pgdriver.Error
+		if errors.As(err, &pgErr) && pgErr.Field('C') == foreignKeyViolation {
+			// Incidents, dispatches, status history, appointments and visit
+			// records keep the staff member who handled them, so they have to
+			// be reassigned or removed first. The constraint names the table.
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Staff member is still referenced by other records (%s); reassign or remove them first", pgErr.Field('n')))
+			return
+		}
 		if err != nil {
+			log.Printf("DB error: %v", err)
 			respondWithError(w, http.StatusInternalServerError, "Failed to delete staff")
 			return
 		}
+		if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+			respondWithError(w, http.StatusNotFound, "Staff not found")
+			return
+		}
+
+		audit.SetBefore(r.Context(), staff)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -0,0 +1,63 @@
+package staff
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/export"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// staffColumns deliberately leaves out credentials and MFA secrets.
+var staffColumns = []export.Column[models.Staff]{
+	{Header: "id", Value: func(s *models.Staff) interface{} { return s.ID }},
+	{Header: "agent_id", Value: func(s *models.Staff) interface{} { return s.AgentID }},
+	{Header: "first_name", Value: func(s *models.Staff) interface{} { return s.FirstName }},
+	{Header: "middle_name", Value: func(s *models.Staff) interface{} { return s.MiddleName }},
+	{Header: "last_name", Value: func(s *models.Staff) interface{} { return s.LastName }},
+	{Header: "email", Value: func(s *models.Staff) interface{} { return s.Email }},
+	{Header: "department", Value: func(s *models.Staff) interface{} { return string(s.Department) }},
+	{Header: "position", Value: func(s *models.Staff) interface{} { return string(s.Position) }},
+	{Header: "role", Value: func(s *models.Staff) interface{} { return string(s.Role) }},
+	{Header: "state_of_origin", Value: func(s *models.Staff) interface{} { return s.StateOfOrigin }},
+	{Header: "mfa_enabled", Value: func(s *models.Staff) interface{} { return s.MFAEnabled }},
+	{Header: "created_at", Value: func(s *models.Staff) interface{} { return s.CreatedAt }},
+}
+
+// ExportStaffHandler streams the staff matching the list filters as CSV or
+// XLSX.
+func ExportStaffHandler(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		format, err := export.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, err := listquery.Parse(r.URL.Query(), staffListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		query := list.Order(list.Filter(db.NewSelect().Model((*models.Staff)(nil))))
+
+		if err := export.Stream(ctx, w, db, query, format, "staff", staffColumns); err != nil {
+			if errors.Is(err, export.ErrTooManyRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export staff records")
+		}
+	}
+}
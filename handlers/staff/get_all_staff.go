@@ -4,59 +4,71 @@ import (
 	"context"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
+	"homeland/listquery"
 	"homeland/models"
+	"homeland/pagination"
 	"homeland/utils"
 
 	"github.com/uptrace/bun"
 )
 
+// staffListSpec is the filter grammar of GET /admin/staff; see listquery.
+var staffListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"department":      listquery.Text,
+		"position":        listquery.Text,
+		"role":            listquery.Text,
+		"agent_id":        listquery.Text,
+		"email":           listquery.Text,
+		"state_of_origin": listquery.Text,
+		"mfa_enabled":     listquery.Bool,
+		"created_at":      listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"first_name": "",
+		"last_name":  "",
+		"department": "",
+		"position":   "",
+		"role":       "",
+		"created_at": "",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "created_at",
+	Search:      listquery.TextSearch("first_name", "middle_name", "last_name", "email", "agent_id"),
+}
+
 func GetAllStaffHandler(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil || limit <= 0 {
-			limit = 10
-		}
-
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-		if err != nil || offset < 0 {
-			offset = 0
+		list, err := listquery.Parse(r.URL.Query(), staffListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
 		var staffList []models.Staff
 
-		err = db.NewSelect().Model(&staffList).
-			Limit(limit).
-			Offset(offset).
-			Order("created_at DESC").
-			Scan(ctx)
-
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&staffList)))
 		if err != nil {
 			log.Printf("DB error: %v", err)
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve staff records")
 			return
 		}
 
-		total, err := db.NewSelect().Model((*models.Staff)(nil)).Count(ctx)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve staff count")
-			return
-		}
+		staffList, page := listquery.Paginate(list, r, staffList, total, func(item models.Staff) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
 
 		response := map[string]interface{}{
-			"status":  "success",
-			"message": "Staff records retrieved successfully",
-			"data":    staffList,
-			"pagination": map[string]int{
-				"total":  total,
-				"limit":  limit,
-				"offset": offset,
-			},
+			"status":     "success",
+			"message":    "Staff records retrieved successfully",
+			"data":       staffList,
+			"pagination": page,
 		}
 
 		utils.RespondWithJSON(w, http.StatusOK, response)
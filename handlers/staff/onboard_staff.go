@@ -1,12 +1,19 @@
 package staff
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"homeland/audit"
 	"homeland/config"
 	"homeland/models"
+	"homeland/passwordpolicy"
+	"homeland/utils"
 
 	"github.com/uptrace/bun"
 	"golang.org/x/crypto/bcrypt"
@@ -17,7 +24,6 @@ type OnboardRequest struct {
 	MiddleName    string `json:"middle_name,omitempty"`
 	LastName      string `json:"last_name"`
 	Email         string `json:"email"`
-	Password      string `json:"password"`
 	AgentID       string `json:"agent_id"`
 	ProfilePhoto  string `json:"profile_photo,omitempty"`
 	Position      string `json:"position"`
@@ -28,7 +34,42 @@ type OnboardRequest struct {
 	Role          string `json:"role"`
 }
 
+const onboardingEmailSubject = "Your Homeland account"
+
+func onboardingEmailBody(staff *models.Staff, tempPassword string) string {
+	return fmt.Sprintf(`Hello %s,
+
+An account has been created for you.
+
+Email: %s
+Temporary password: %s
+
+You will be asked to choose a new password when you first log in. The temporary password cannot be used for anything else.
+`, staff.FirstName, staff.Email, tempPassword)
+}
+
+// maxTempPasswordAttempts bounds the retries when a random temporary password
+// happens to break the policy, e.g. by lacking a digit.
+const maxTempPasswordAttempts = 20
+
+// tempPasswordFor generates a temporary password the policy accepts for staff.
+func tempPasswordFor(policy *passwordpolicy.Policy, staff *models.Staff) (string, error) {
+	var violations passwordpolicy.Violations
+	for i := 0; i < maxTempPasswordAttempts; i++ {
+		password, err := utils.GenerateTempPassword()
+		if err != nil {
+			return "", err
+		}
+		if violations = policy.Validate("password", password, staff); len(violations) == 0 {
+			return password, nil
+		}
+	}
+	return "", violations
+}
+
 func OnboardStaffHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	policy := passwordpolicy.New(cfg)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req OnboardRequest
 
@@ -39,6 +80,13 @@ func OnboardStaffHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		// The temporary password only ever reaches its owner by email, so an
+		// account created without it could never be logged in to.
+		if !utils.EmailConfigured(cfg) {
+			respondWithError(w, http.StatusServiceUnavailable, "Email is not configured, so the temporary password cannot be sent")
+			return
+		}
+
 		var existingStaff models.Staff
 		err := db.NewSelect().Model(&existingStaff).Where("email = ?", req.Email).Scan(r.Context())
 		if err == nil {
@@ -46,29 +94,17 @@ func OnboardStaffHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		if len(req.Password) < 8 {
-			respondWithError(w, http.StatusBadRequest, "Password must be at least 8 characters long")
-			return
-		}
-
 		parsedDOB, err := time.Parse("2006-01-02", req.DateOfBirth)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD")
 			return
 		}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
-			return
-		}
-
 		staff := models.Staff{
 			FirstName:          req.FirstName,
 			MiddleName:         req.MiddleName,
 			LastName:           req.LastName,
 			Email:              req.Email,
-			Password:           string(hashedPassword),
 			AgentID:            req.AgentID,
 			ProfilePhoto:       req.ProfilePhoto,
 			Position:           models.PositionEnum(req.Position),
@@ -77,15 +113,54 @@ func OnboardStaffHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 			DateOfBirth:        parsedDOB,
 			StateOfOrigin:      req.StateOfOrigin,
 			Role:               models.RoleEnum(req.Role),
-			MustChangePassword: false,
+			MustChangePassword: true,
+		}
+
+		tempPassword, err := tempPasswordFor(policy, &staff)
+		if err != nil {
+			log.Printf("Failed to generate temporary password: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate temporary password")
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(tempPassword), bcrypt.DefaultCost)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+			return
 		}
+		staff.Password = string(hashedPassword)
 
-		_, err = db.NewInsert().Model(&staff).Exec(r.Context())
+		err = db.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewInsert().Model(&staff).Exec(ctx); err != nil {
+				return err
+			}
+			return policy.Record(ctx, tx, staff.ID, staff.Password)
+		})
 		if err != nil {
+			log.Printf("Failed to onboard staff %s: %v", req.Email, err)
 			respondWithError(w, http.StatusInternalServerError, "Failed to onboard staff")
 			return
 		}
 
+		// The email goes out after the commit so SMTP never holds the
+		// transaction open. The account is only kept if the temporary password
+		// reached its owner.
+		if err := utils.SendEmail(r.Context(), cfg, staff.Email, onboardingEmailSubject, onboardingEmailBody(&staff, tempPassword)); err != nil {
+			log.Printf("Failed to send onboarding email to %s: %v", staff.Email, err)
+
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer cancel()
+			if _, err := db.NewDelete().Model(&staff).WherePK().Exec(ctx); err != nil {
+				log.Printf("Failed to remove staff %d after the onboarding email failed: %v", staff.ID, err)
+			}
+
+			respondWithError(w, http.StatusServiceUnavailable, "Failed to send the onboarding email; the account was not created, please try again")
+			return
+		}
+
+		audit.SetResource(r.Context(), "staff", strconv.FormatInt(staff.ID, 10))
+		audit.SetAfter(r.Context(), staff)
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -105,7 +180,7 @@ func OnboardStaffHandler(db *bun.DB, cfg *config.Config) http.HandlerFunc {
 				"date_of_birth":    staff.DateOfBirth.Format("2006-01-02"),
 				"state_of_origin":  staff.StateOfOrigin,
 				"role":             staff.Role,
-				"password_changed": staff.MustChangePassword,
+				"password_changed": !staff.MustChangePassword,
 			},
 		})
 	}
@@ -5,22 +5,13 @@ import (
 	"net/http"
 	"time"
 
+	"homeland/audit"
 	"homeland/models"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-var allowedRoles = map[models.RoleEnum]bool{
-	models.RoleAdmin:    true,
-	models.RoleSSA:      true,
-	models.RoleDirector: true,
-}
-
-func isAuthorized(role models.RoleEnum) bool {
-	return allowedRoles[role]
-}
-
 func UpdateStaffHandler(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		staffID := chi.URLParam(r, "id")
@@ -40,10 +31,7 @@ func UpdateStaffHandler(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		if !isAuthorized(models.RoleEnum(req.Role)) {
-			respondWithError(w, http.StatusForbidden, "You are not authorized to update staff")
-			return
-		}
+		audit.SetBefore(r.Context(), staff)
 
 		staff.FirstName = req.FirstName
 		staff.MiddleName = req.MiddleName
@@ -61,6 +49,8 @@ func UpdateStaffHandler(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
+		audit.SetAfter(r.Context(), staff)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":  "success",
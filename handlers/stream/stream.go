@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/events"
+	"homeland/middleware"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+const heartbeatInterval = 25 * time.Second
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Browsers authenticate by offering the token after this protocol;
+	// only the protocol name is echoed back.
+	Subprotocols: []string{middleware.WebSocketTokenProtocol},
+}
+
+// eventFilter limits a subscriber to the event types it asked for and to the
+// departments its claims allow. Homeland Security and privileged roles see
+// every event; responding departments only see events addressed to them.
+func eventFilter(claims *utils.Claims, types string) func(events.Event) bool {
+	wanted := make(map[string]bool)
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			wanted[t] = true
+		}
+	}
+
+	seesAll := models.SeesAllDepartments(models.RoleEnum(claims.Role), models.DepartmentEnum(claims.Department))
+
+	return func(event events.Event) bool {
+		if len(wanted) > 0 && !wanted[event.Type] {
+			return false
+		}
+		if seesAll {
+			return true
+		}
+		for _, department := range event.Departments {
+			if department == claims.Department {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func lastEventID(r *http.Request) int64 {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseInt(raw, 10, 64)
+	return id
+}
+
+func StreamEvents(broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := utils.GetUserFromContext(r.Context())
+		if claims == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Streaming unsupported")
+			return
+		}
+
+		sub, backlog, complete := broker.Subscribe(lastEventID(r), eventFilter(claims, r.URL.Query().Get("types")))
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if !complete {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, event := range backlog {
+			writeSSE(w, event)
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				writeSSE(w, event)
+				flusher.Flush()
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event events.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode event %d: %v", event.ID, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+func StreamWebSocket(broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := utils.GetUserFromContext(r.Context())
+		if claims == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		sub, backlog, complete := broker.Subscribe(lastEventID(r), eventFilter(claims, r.URL.Query().Get("types")))
+		defer sub.Close()
+
+		// The read loop only exists to notice the client going away.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		if !complete {
+			if err := conn.WriteJSON(events.Event{Type: "reset"}); err != nil {
+				return
+			}
+		}
+		for _, event := range backlog {
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"))
+					return
+				}
+				if err := conn.WriteJSON(event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package visitor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/audit"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+// visitorListSpec is the filter grammar of GET /visitors; see listquery.
+var visitorListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"id_document_type":   listquery.Text,
+		"id_document_number": listquery.Text,
+		"phone_number":       listquery.Text,
+		"organization":       listquery.Text,
+		"created_at":         listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"full_name":  "",
+		"created_at": "",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "created_at",
+	Search:      listquery.TextSearch("full_name", "organization", "id_document_number", "phone_number"),
+}
+
+// normalize tidies the phone number and checks the photo reference.
+func normalize(ctx context.Context, db bun.IDB, visitor *models.Visitor) (int, error) {
+	if err := visitor.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if visitor.PhoneNumber != "" {
+		phone, err := utils.NormalizePhoneNumber(visitor.PhoneNumber)
+		if err != nil {
+			return http.StatusBadRequest, utils.ErrInvalidPhoneNumber
+		}
+		visitor.PhoneNumber = phone
+	}
+	if visitor.PhotoDocumentID != 0 {
+		exists, err := db.NewSelect().Model((*models.Document)(nil)).Where("id = ?", visitor.PhotoDocumentID).Exists(ctx)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !exists {
+			return http.StatusBadRequest, errors.New("photo_document_id does not match a document")
+		}
+	}
+	return 0, nil
+}
+
+// findByDocument returns the visitor registered with the same ID document,
+// other than exclude, or nil.
+func findByDocument(ctx context.Context, db bun.IDB, visitor *models.Visitor, exclude int64) (*models.Visitor, error) {
+	var existing models.Visitor
+	q := db.NewSelect().
+		Model(&existing).
+		Where("id_document_type = ?", visitor.IDDocumentType).
+		Where("lower(id_document_number) = lower(?)", visitor.IDDocumentNumber)
+	if exclude != 0 {
+		q = q.Where("id <> ?", exclude)
+	}
+	if err := q.Limit(1).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// CreateVisitor registers a visitor. A visitor is identified by their ID
+// document, so registering the same document twice answers 409 with the
+// existing record.
+func CreateVisitor(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var visitor models.Visitor
+		if err := json.NewDecoder(r.Body).Decode(&visitor); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		visitor.ID = 0
+
+		if status, err := normalize(ctx, db, &visitor); err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, status, "Failed to register visitor")
+				return
+			}
+			utils.RespondWithError(w, status, err.Error())
+			return
+		}
+
+		existing, err := findByDocument(ctx, db, &visitor, 0)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to register visitor")
+			return
+		}
+		if existing != nil {
+			utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":   "A visitor with this ID document is already registered",
+				"visitor": existing,
+			})
+			return
+		}
+
+		visitor.CreatedAt = time.Now()
+		visitor.UpdatedAt = visitor.CreatedAt
+		if _, err := db.NewInsert().Model(&visitor).Exec(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to register visitor")
+			return
+		}
+
+		audit.SetResource(r.Context(), "visitors", strconv.FormatInt(visitor.ID, 10))
+		audit.SetAfter(r.Context(), visitor)
+		utils.RespondWithJSON(w, http.StatusCreated, visitor)
+	}
+}
+
+func GetVisitors(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, err := listquery.Parse(r.URL.Query(), visitorListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var visitors []models.Visitor
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&visitors)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch visitors")
+			return
+		}
+
+		visitors, page := listquery.Paginate(list, r, visitors, total, func(item models.Visitor) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       visitors,
+			"pagination": page,
+		})
+	}
+}
+
+// GetVisitor returns a visitor with their most recent visits.
+func GetVisitor(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid visitor ID")
+			return
+		}
+
+		var visitor models.Visitor
+		if err := db.NewSelect().Model(&visitor).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Visitor not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch visitor")
+			return
+		}
+
+		visits := make([]models.Visit, 0)
+		err = db.NewSelect().
+			Model(&visits).
+			Relation("Host").
+			Where("visit.visitor_id = ?", id).
+			Order("visit.checked_in_at DESC", "visit.id DESC").
+			Limit(20).
+			Scan(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch visitor")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"visitor": visitor,
+			"visits":  visits,
+		})
+	}
+}
+
+// UpdateVisitor corrects a visitor's details or attaches their photo.
+func UpdateVisitor(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid visitor ID")
+			return
+		}
+
+		var before models.Visitor
+		if err := db.NewSelect().Model(&before).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Visitor not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update visitor")
+			return
+		}
+		audit.SetBefore(r.Context(), before)
+
+		visitor := before
+		if err := json.NewDecoder(r.Body).Decode(&visitor); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		visitor.ID = id
+		visitor.CreatedAt = before.CreatedAt
+
+		if status, err := normalize(ctx, db, &visitor); err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, status, "Failed to update visitor")
+				return
+			}
+			utils.RespondWithError(w, status, err.Error())
+			return
+		}
+
+		existing, err := findByDocument(ctx, db, &visitor, id)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update visitor")
+			return
+		}
+		if existing != nil {
+			utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":   "Another visitor is registered with this ID document",
+				"visitor": existing,
+			})
+			return
+		}
+
+		visitor.UpdatedAt = time.Now()
+		if _, err := db.NewUpdate().Model(&visitor).WherePK().Exec(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update visitor")
+			return
+		}
+
+		audit.SetAfter(r.Context(), visitor)
+		utils.RespondWithJSON(w, http.StatusOK, visitor)
+	}
+}
@@ -0,0 +1,384 @@
+package visitor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/audit"
+	"homeland/config"
+	"homeland/events"
+	"homeland/models"
+	"homeland/printout"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+// Visitor events go to building security, which is run by Homeland Security.
+const securityDepartment = string(models.DeptHomelandSecurity)
+
+var (
+	errVisitorNotFound     = errors.New("visitor_id does not match a visitor")
+	errAppointmentNotFound = errors.New("appointment_id does not match an appointment")
+	errHostNotFound        = errors.New("host_staff_id does not match a staff member")
+	errPurposeRequired     = errors.New("purpose is required")
+	errAlreadyOnSite       = errors.New("visitor is already checked in")
+	errAppointmentUsed     = errors.New("appointment has already been checked in")
+	errAlreadyCheckedOut   = errors.New("visit has already been checked out")
+)
+
+type CheckInRequest struct {
+	VisitorID     int64  `json:"visitor_id"`
+	AppointmentID int64  `json:"appointment_id"`
+	HostStaffID   int64  `json:"host_staff_id"`
+	Purpose       string `json:"purpose"`
+}
+
+// loadVisit fetches a visit with its visitor and host.
+func loadVisit(ctx context.Context, db bun.IDB, where string, arg interface{}) (*models.Visit, error) {
+	var visit models.Visit
+	err := db.NewSelect().
+		Model(&visit).
+		Relation("Visitor").
+		Relation("Host").
+		Where(where, arg).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &visit, nil
+}
+
+// CheckIn stamps a visitor's arrival and issues a badge. Checking in for an
+// appointment takes the host and purpose from it.
+func CheckIn(db *bun.DB, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req CheckInRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.VisitorID == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "visitor_id is required")
+			return
+		}
+		if req.AppointmentID == 0 && req.HostStaffID == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "appointment_id or host_staff_id is required")
+			return
+		}
+
+		visit := models.Visit{
+			VisitorID:     req.VisitorID,
+			AppointmentID: req.AppointmentID,
+			HostStaffID:   req.HostStaffID,
+			Purpose:       strings.TrimSpace(req.Purpose),
+			CheckedInAt:   time.Now(),
+			CheckedInBy:   user.UserID,
+		}
+
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			// Locking the visitor stops two desks checking them in at once.
+			var visitor models.Visitor
+			if err := tx.NewSelect().Model(&visitor).Where("id = ?", visit.VisitorID).For("UPDATE").Scan(ctx); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errVisitorNotFound
+				}
+				return err
+			}
+
+			onSite, err := tx.NewSelect().
+				Model((*models.Visit)(nil)).
+				Where("visitor_id = ?", visit.VisitorID).
+				Where("checked_out_at IS NULL").
+				Exists(ctx)
+			if err != nil {
+				return err
+			}
+			if onSite {
+				return errAlreadyOnSite
+			}
+
+			if visit.AppointmentID != 0 {
+				var appointment models.Appointment
+				if err := tx.NewSelect().Model(&appointment).Where("id = ?", visit.AppointmentID).For("UPDATE").Scan(ctx); err != nil {
+					if errors.Is(err, sql.ErrNoRows) {
+						return errAppointmentNotFound
+					}
+					return err
+				}
+				used, err := tx.NewSelect().Model((*models.Visit)(nil)).Where("appointment_id = ?", appointment.ID).Exists(ctx)
+				if err != nil {
+					return err
+				}
+				if used {
+					return errAppointmentUsed
+				}
+				if visit.HostStaffID == 0 {
+					visit.HostStaffID = appointment.StaffID
+				}
+				if visit.Purpose == "" {
+					visit.Purpose = appointment.Purpose
+				}
+			}
+
+			if visit.HostStaffID != 0 {
+				exists, err := tx.NewSelect().Model((*models.Staff)(nil)).Where("id = ?", visit.HostStaffID).Exists(ctx)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					return errHostNotFound
+				}
+			}
+			if visit.Purpose == "" {
+				return errPurposeRequired
+			}
+
+			// Codes are random, so a clash is unlikely; try a few times anyway.
+			for attempt := 0; attempt < 5; attempt++ {
+				code, err := utils.GenerateBadgeCode()
+				if err != nil {
+					return err
+				}
+				taken, err := tx.NewSelect().Model((*models.Visit)(nil)).Where("badge_code = ?", code).Exists(ctx)
+				if err != nil {
+					return err
+				}
+				if !taken {
+					visit.BadgeCode = code
+					break
+				}
+			}
+			if visit.BadgeCode == "" {
+				return errors.New("could not allocate a badge code")
+			}
+
+			_, err = tx.NewInsert().Model(&visit).Exec(ctx)
+			return err
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, errAlreadyOnSite), errors.Is(err, errAppointmentUsed):
+				utils.RespondWithError(w, http.StatusConflict, err.Error())
+			case errors.Is(err, errVisitorNotFound), errors.Is(err, errAppointmentNotFound), errors.Is(err, errHostNotFound),
+				errors.Is(err, errPurposeRequired):
+				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			default:
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check visitor in")
+			}
+			return
+		}
+
+		loaded, err := loadVisit(ctx, db, "visit.id = ?", visit.ID)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			loaded = &visit
+		}
+
+		audit.SetResource(r.Context(), "visits", strconv.FormatInt(visit.ID, 10))
+		audit.SetAction(r.Context(), "check_in")
+		audit.SetAfter(r.Context(), loaded)
+		broker.Publish(events.VisitorCheckedIn, loaded, securityDepartment)
+		utils.RespondWithJSON(w, http.StatusCreated, loaded)
+	}
+}
+
+func checkOut(db *bun.DB, broker *events.Broker, w http.ResponseWriter, r *http.Request, where string, arg interface{}) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user := utils.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	visit, err := loadVisit(ctx, db, where, arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusNotFound, "Visit not found")
+			return
+		}
+		log.Printf("DB error: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check visitor out")
+		return
+	}
+	audit.SetResource(r.Context(), "visits", strconv.FormatInt(visit.ID, 10))
+	audit.SetAction(r.Context(), "check_out")
+	audit.SetBefore(r.Context(), visit)
+
+	visit.CheckedOutAt = time.Now()
+	visit.CheckedOutBy = user.UserID
+	res, err := db.NewUpdate().
+		Model(visit).
+		Column("checked_out_at", "checked_out_by").
+		WherePK().
+		Where("checked_out_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("DB error: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check visitor out")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		utils.RespondWithError(w, http.StatusConflict, errAlreadyCheckedOut.Error())
+		return
+	}
+
+	audit.SetAfter(r.Context(), visit)
+	broker.Publish(events.VisitorCheckedOut, visit, securityDepartment)
+	utils.RespondWithJSON(w, http.StatusOK, visit)
+}
+
+// CheckOut stamps a visitor's departure.
+func CheckOut(db *bun.DB, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid visit ID")
+			return
+		}
+		checkOut(db, broker, w, r, "visit.id = ?", id)
+	}
+}
+
+// CheckOutByBadge checks out the visit a scanned or typed badge belongs to.
+func CheckOutByBadge(db *bun.DB, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checkOut(db, broker, w, r, "visit.badge_code = ?", strings.ToUpper(chi.URLParam(r, "code")))
+	}
+}
+
+// GetVisitByBadge looks a badge up, e.g. when security stops a visitor.
+func GetVisitByBadge(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		visit, err := loadVisit(ctx, db, "visit.badge_code = ?", strings.ToUpper(chi.URLParam(r, "code")))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Badge not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch visit")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, visit)
+	}
+}
+
+// GetOnSite lists everyone checked in and not yet checked out, longest on
+// site first, optionally only those visiting host_staff_id.
+func GetOnSite(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		visits := make([]models.Visit, 0)
+		q := db.NewSelect().
+			Model(&visits).
+			Relation("Visitor").
+			Relation("Host").
+			Where("visit.checked_out_at IS NULL").
+			Order("visit.checked_in_at ASC", "visit.id ASC")
+
+		if value := r.URL.Query().Get("host_staff_id"); value != "" {
+			host, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid host_staff_id")
+				return
+			}
+			q = q.Where("visit.host_staff_id = ?", host)
+		}
+
+		if err := q.Scan(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch on-site visitors")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":  visits,
+			"count": len(visits),
+			"as_of": time.Now(),
+		})
+	}
+}
+
+// PrintBadge renders a visit's badge as a PDF to print at the front desk.
+func PrintBadge(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid visit ID")
+			return
+		}
+
+		visit, err := loadVisit(ctx, db, "visit.id = ?", id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Visit not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch visit")
+			return
+		}
+		if !visit.CheckedOutAt.IsZero() {
+			utils.RespondWithError(w, http.StatusConflict, errAlreadyCheckedOut.Error())
+			return
+		}
+
+		doc := printout.New(printout.HeaderFromConfig(cfg), "Visitor Badge", visit.BadgeCode, user.Email)
+		doc.Banner(visit.BadgeCode)
+		fields := []printout.Field{
+			{Label: "Checked in", Value: printout.Time(visit.CheckedInAt)},
+			{Label: "Host", Value: printout.StaffName(visit.Host)},
+			{Label: "Purpose", Value: visit.Purpose},
+		}
+		if v := visit.Visitor; v != nil {
+			fields = append([]printout.Field{
+				{Label: "Visitor", Value: v.FullName},
+				{Label: "Organization", Value: v.Organization},
+			}, fields...)
+		}
+		doc.Fields(fields...)
+		doc.Text("This badge must be worn visibly at all times and returned at the front desk on leaving.")
+
+		if err := doc.Serve(w, fmt.Sprintf("badge-%s.pdf", strings.ToLower(visit.BadgeCode))); err != nil {
+			log.Printf("Print badge %s failed: %v", visit.BadgeCode, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render badge")
+		}
+	}
+}
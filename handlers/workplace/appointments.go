@@ -2,30 +2,35 @@ package workplace
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"homeland/listquery"
 	"homeland/models"
+	"homeland/notify"
+	"homeland/pagination"
 	"homeland/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-var allowedRoles = map[models.RoleEnum]bool{
-	models.RoleAdmin:    true,
-	models.RoleSSA:      true,
-	models.RoleDirector: true,
-	models.RoleStaff:    true,
-}
-
+// CreateAppointment books a visitor with a staff member. Bookings that
+// overlap another appointment or fall outside the staff member's availability
+// are rejected with 409, unless on_conflict=warn accepts them with warnings.
 func CreateAppointment(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		user := utils.GetUserFromContext(r.Context())
-		if user.Department != string(models.DeptHomelandSecurity) || !allowedRoles[models.RoleEnum(user.Role)] {
-			utils.RespondWithError(w, http.StatusForbidden, "Unauthorized")
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		mode, err := parseConflictMode(r.URL.Query().Get("on_conflict"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
@@ -34,13 +39,29 @@ func CreateAppointment(db *bun.DB) http.HandlerFunc {
 			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
+		appointment.ID = 0
+		appointment.Normalize()
+
+		if err := resolveStaff(ctx, db, &appointment); err != nil {
+			if !respondScheduleError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create appointment")
+			}
+			return
+		}
+		if err := appointment.Validate(); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		appointment.CreatedAt = time.Now()
 		appointment.UpdatedAt = time.Now()
 
-		_, err := db.NewInsert().Model(&appointment).Exec(context.Background())
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create appointment")
+		if err := saveAppointment(ctx, db, &appointment, mode); err != nil {
+			if !respondScheduleError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create appointment")
+			}
 			return
 		}
 
@@ -48,43 +69,56 @@ func CreateAppointment(db *bun.DB) http.HandlerFunc {
 	}
 }
 
+// appointmentListSpec is the filter grammar of GET /appointments; see
+// listquery.
+var appointmentListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"department":       listquery.Text,
+		"priority":         listquery.Text,
+		"who_to_see":       listquery.Text,
+		"staff_id":         listquery.Int,
+		"appointment_date": listquery.Time,
+		"time_in":          listquery.Time,
+		"created_at":       listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":               "",
+		"appointment_date": "",
+		"time_in":          "",
+		"priority":         "CASE ?TableAlias.priority WHEN 'high' THEN 3 WHEN 'medium' THEN 2 ELSE 1 END",
+		"created_at":       "",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "appointment_date",
+	Search:      listquery.TextSearch("visitor_name", "purpose", "who_to_see", "notes"),
+}
+
 func GetAppointments(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil || limit <= 0 {
-			limit = 10
-		}
-
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-		if err != nil || offset < 0 {
-			offset = 0
+		list, err := listquery.Parse(r.URL.Query(), appointmentListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
 		var appointments []models.Appointment
 
-		err = db.NewSelect().Model(&appointments).
-			Limit(limit).
-			Offset(offset).
-			Order("created_at DESC").
-			Scan(ctx)
-
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&appointments)))
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch appointments")
 			return
 		}
 
-		total, err := db.NewSelect().Model((*models.Appointment)(nil)).Count(ctx)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch appointment count")
-			return
-		}
+		appointments, page := listquery.Paginate(list, r, appointments, total, func(item models.Appointment) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
 
 		response := map[string]interface{}{
 			"data":       appointments,
-			"pagination": map[string]int{"total": total, "limit": limit, "offset": offset},
+			"pagination": page,
 		}
 
 		utils.RespondWithJSON(w, http.StatusOK, response)
@@ -110,36 +144,69 @@ func GetAppointmentByID(db *bun.DB) http.HandlerFunc {
 	}
 }
 
+// UpdateAppointment applies the same checks as CreateAppointment, ignoring
+// the appointment's own booking. Updating an occurrence of a series detaches
+// it, so later edits to the series leave it alone.
 func UpdateAppointment(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		user := utils.GetUserFromContext(r.Context())
-		if user.Department != string(models.DeptHomelandSecurity) || !allowedRoles[models.RoleEnum(user.Role)] {
-			utils.RespondWithError(w, http.StatusForbidden, "Unauthorized")
-			return
-		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
 
 		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
 			return
 		}
+		mode, err := parseConflictMode(r.URL.Query().Get("on_conflict"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		var appointment models.Appointment
-		err = db.NewSelect().Model(&appointment).Where("id = ?", id).Scan(context.Background())
+		err = db.NewSelect().Model(&appointment).Where("id = ?", id).Scan(ctx)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusNotFound, "Appointment not found")
 			return
 		}
 
+		before := appointment
 		if err := json.NewDecoder(r.Body).Decode(&appointment); err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
+		appointment.ID = id
+		appointment.SeriesID = before.SeriesID
+		appointment.RecurrenceID = before.RecurrenceID
+		appointment.ICSUID = before.ICSUID
+		// An occurrence edited on its own no longer follows its series.
+		appointment.Detached = before.SeriesID != 0
+		appointment.Normalize()
+
+		if err := resolveStaff(ctx, db, &appointment); err != nil {
+			if !respondScheduleError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update appointment")
+			}
+			return
+		}
+		if err := appointment.Validate(); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		appointment.UpdatedAt = time.Now()
-		_, err = db.NewUpdate().Model(&appointment).Where("id = ?", id).Exec(context.Background())
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := saveAppointmentTx(ctx, tx, &appointment, mode); err != nil {
+				return err
+			}
+			return notify.AppointmentUpdated(ctx, tx, &before, &appointment)
+		})
 		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update appointment")
+			if !respondScheduleError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update appointment")
+			}
 			return
 		}
 
@@ -147,13 +214,12 @@ func UpdateAppointment(db *bun.DB) http.HandlerFunc {
 	}
 }
 
+// DeleteAppointment deletes an appointment. Deleting an occurrence of a
+// series cancels it, so the series does not book it again.
 func DeleteAppointment(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		user := utils.GetUserFromContext(r.Context())
-		if user.Department != string(models.DeptHomelandSecurity) || !allowedRoles[models.RoleEnum(user.Role)] {
-			utils.RespondWithError(w, http.StatusForbidden, "Unauthorized")
-			return
-		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
 
 		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 		if err != nil {
@@ -161,8 +227,29 @@ func DeleteAppointment(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		_, err = db.NewDelete().Model((*models.Appointment)(nil)).Where("id = ?", id).Exec(context.Background())
+		var appointment models.Appointment
+		if err := db.NewSelect().Model(&appointment).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Appointment not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete appointment")
+			return
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if appointment.SeriesID != 0 {
+				if err := cancelOccurrence(ctx, tx, appointment.SeriesID, appointment.RecurrenceID); err != nil {
+					return err
+				}
+			} else if _, err := tx.NewDelete().Model(&appointment).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			return notify.AppointmentCancelled(ctx, tx, &appointment)
+		})
 		if err != nil {
+			log.Printf("DB error: %v", err)
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete appointment")
 			return
 		}
@@ -0,0 +1,687 @@
+package workplace
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/calendar"
+	"homeland/config"
+	"homeland/models"
+	"homeland/rbac"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// Feeds reach this far back so recent meetings stay in clients'
+	// calendars.
+	feedHistory    = 90 * 24 * time.Hour
+	maxImportBytes = 2 << 20
+	importTimeout  = 60 * time.Second
+)
+
+var departments = map[models.DepartmentEnum]bool{
+	models.DeptHomelandSecurity: true,
+	models.DeptAVS:              true,
+	models.DeptEMS:              true,
+	models.DeptFireService:      true,
+}
+
+var (
+	errCalendarNotFound     = errors.New("calendar not found")
+	errOverrideWithoutRRULE = errors.New("event has edited occurrences but no recurring event")
+)
+
+// calendarTarget is whose appointments a calendar shows: a staff member's or
+// a department's.
+type calendarTarget struct {
+	scope      models.CalendarFeedScope
+	target     string
+	name       string
+	staffID    int64
+	department models.DepartmentEnum
+}
+
+func resolveCalendarTarget(ctx context.Context, db bun.IDB, scope models.CalendarFeedScope, target string) (*calendarTarget, error) {
+	t := &calendarTarget{scope: scope, target: target}
+	switch scope {
+	case models.FeedScopeStaff:
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return nil, errCalendarNotFound
+		}
+		var staff models.Staff
+		if err := db.NewSelect().Model(&staff).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, errCalendarNotFound
+			}
+			return nil, err
+		}
+		t.staffID = staff.ID
+		t.department = staff.Department
+		t.name = staff.FullName() + " appointments"
+	case models.FeedScopeDepartment:
+		t.department = models.DepartmentEnum(target)
+		if !departments[t.department] {
+			return nil, errCalendarNotFound
+		}
+		t.name = target + " appointments"
+	default:
+		return nil, errCalendarNotFound
+	}
+	return t, nil
+}
+
+// visibleTo reports whether staff with role in department may read the
+// calendar.
+func (t *calendarTarget) visibleTo(role models.RoleEnum, department models.DepartmentEnum) bool {
+	return models.SeesAllDepartments(role, department) || t.department == department
+}
+
+func (t *calendarTarget) filter(q *bun.SelectQuery) *bun.SelectQuery {
+	if t.scope == models.FeedScopeStaff {
+		return q.Where("?TableAlias.staff_id = ?", t.staffID)
+	}
+	return q.Where("?TableAlias.department = ?", t.department)
+}
+
+// buildCalendar collects the calendar's appointments from feedHistory ago:
+// single appointments as they are, and each series as one recurring event
+// with its cancelled and edited occurrences. Past occurrences are included
+// too, so that a later change to the series does not rewrite them.
+func buildCalendar(ctx context.Context, db bun.IDB, t *calendarTarget, office *time.Location) (*calendar.Feed, error) {
+	now := calendar.Now(office)
+	from := now.Add(-feedHistory)
+
+	singles := make([]*models.Appointment, 0)
+	err := t.filter(db.NewSelect().Model(&singles)).
+		Where("?TableAlias.series_id IS NULL").
+		Where("?TableAlias.time_out >= ?::timestamp", from).
+		Order("time_in ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]*models.AppointmentSeries, 0)
+	if err := t.filter(db.NewSelect().Model(&series)).Order("id ASC").Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[int64][]*models.Appointment)
+	if len(series) > 0 {
+		ids := make([]int64, len(series))
+		for i, s := range series {
+			ids[i] = s.ID
+		}
+		occurrences := make([]*models.Appointment, 0)
+		err := db.NewSelect().
+			Model(&occurrences).
+			Where("series_id IN (?)", bun.In(ids)).
+			Where("(detached = true OR time_in < ?::timestamp)", now).
+			Order("recurrence_id ASC").
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range occurrences {
+			overrides[a.SeriesID] = append(overrides[a.SeriesID], a)
+		}
+	}
+
+	feed := calendar.NewFeed(t.name, office)
+	for _, a := range singles {
+		feed.AddAppointment(a)
+	}
+	for _, s := range series {
+		if err := feed.AddSeries(s, overrides[s.ID]); err != nil {
+			log.Printf("Series %d left out of calendar: %v", s.ID, err)
+		}
+	}
+	return feed, nil
+}
+
+func serveCalendar(w http.ResponseWriter, feed *calendar.Feed, filename string) {
+	var buf bytes.Buffer
+	if err := feed.Write(&buf); err != nil {
+		log.Printf("Calendar error: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build calendar")
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// calendarFilename turns a calendar name into a file name such as
+// "fire-service-appointments.ics".
+func calendarFilename(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-")) + ".ics"
+}
+
+// GetCalendar serves the .ics calendar of a staff member
+// (/calendars/staff/{target}.ics) or a department
+// (/calendars/departments/{target}.ics). Staff who cannot see every
+// department only get calendars of their own.
+func GetCalendar(db *bun.DB, cfg *config.Config, scope models.CalendarFeedScope) http.HandlerFunc {
+	office := calendar.Office(cfg.OfficeTimezone)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		target, err := url.PathUnescape(chi.URLParam(r, "target"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+			return
+		}
+		t, err := resolveCalendarTarget(ctx, db, scope, target)
+		if err != nil {
+			if errors.Is(err, errCalendarNotFound) {
+				utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build calendar")
+			return
+		}
+		if !t.visibleTo(models.RoleEnum(user.Role), models.DepartmentEnum(user.Department)) {
+			utils.RespondWithError(w, http.StatusForbidden, "You may only read calendars of your own department")
+			return
+		}
+
+		feed, err := buildCalendar(ctx, db, t, office)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build calendar")
+			return
+		}
+		serveCalendar(w, feed, calendarFilename(t.name))
+	}
+}
+
+// CreateCalendarFeed issues a secret subscription URL for a calendar, for
+// clients that cannot send a bearer token. The token is shown only once.
+func CreateCalendarFeed(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req struct {
+			Scope  models.CalendarFeedScope `json:"scope"`
+			Target string                   `json:"target"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.Scope != models.FeedScopeStaff && req.Scope != models.FeedScopeDepartment {
+			utils.RespondWithError(w, http.StatusBadRequest, "scope must be staff or department")
+			return
+		}
+
+		t, err := resolveCalendarTarget(ctx, db, req.Scope, req.Target)
+		if err != nil {
+			if errors.Is(err, errCalendarNotFound) {
+				utils.RespondWithError(w, http.StatusBadRequest, "target does not match a staff member or department")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create calendar feed")
+			return
+		}
+		if !t.visibleTo(models.RoleEnum(user.Role), models.DepartmentEnum(user.Department)) {
+			utils.RespondWithError(w, http.StatusForbidden, "You may only read calendars of your own department")
+			return
+		}
+
+		token, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create calendar feed")
+			return
+		}
+		feed := models.CalendarFeed{
+			StaffID:   user.UserID,
+			Scope:     t.scope,
+			Target:    t.target,
+			TokenHash: utils.HashToken(token),
+			CreatedAt: time.Now(),
+		}
+		if _, err := db.NewInsert().Model(&feed).Exec(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create calendar feed")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"feed":  feed,
+			"token": token,
+			"url":   "/api/v1/calendars/feed/" + token + ".ics",
+		})
+	}
+}
+
+// GetCalendarFeeds lists the caller's active feeds.
+func GetCalendarFeeds(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		feeds := make([]models.CalendarFeed, 0)
+		err := db.NewSelect().
+			Model(&feeds).
+			Where("staff_id = ?", user.UserID).
+			Where("revoked_at IS NULL").
+			Order("created_at DESC").
+			Scan(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch calendar feeds")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": feeds})
+	}
+}
+
+// RevokeCalendarFeed stops one of the caller's feeds from working.
+func RevokeCalendarFeed(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid feed ID")
+			return
+		}
+
+		res, err := db.NewUpdate().
+			Model((*models.CalendarFeed)(nil)).
+			Set("revoked_at = ?", time.Now()).
+			Where("id = ?", id).
+			Where("staff_id = ?", user.UserID).
+			Where("revoked_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke calendar feed")
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "Calendar feed not found")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Calendar feed revoked"})
+	}
+}
+
+// GetFeedCalendar serves a calendar to a subscribed client. It is reached
+// without a bearer token, so the feed's owner is checked on every request:
+// a feed stops working once its owner could no longer read the calendar.
+func GetFeedCalendar(db *bun.DB, cfg *config.Config, policy *rbac.Engine) http.HandlerFunc {
+	office := calendar.Office(cfg.OfficeTimezone)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var feed models.CalendarFeed
+		err := db.NewSelect().
+			Model(&feed).
+			Where("token_hash = ?", utils.HashToken(chi.URLParam(r, "token"))).
+			Where("revoked_at IS NULL").
+			Scan(ctx)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("DB error: %v", err)
+			}
+			utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+			return
+		}
+
+		var owner models.Staff
+		if err := db.NewSelect().Model(&owner).Where("id = ?", feed.StaffID).Scan(ctx); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("DB error: %v", err)
+			}
+			utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+			return
+		}
+		subject := rbac.Subject{Role: string(owner.Role), Department: string(owner.Department), Position: string(owner.Position)}
+		if !policy.Allowed(subject, "appointments", "read") {
+			utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+			return
+		}
+
+		t, err := resolveCalendarTarget(ctx, db, feed.Scope, feed.Target)
+		if err != nil || !t.visibleTo(owner.Role, owner.Department) {
+			if err != nil && !errors.Is(err, errCalendarNotFound) {
+				log.Printf("DB error: %v", err)
+			}
+			utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+			return
+		}
+
+		calendarFeed, err := buildCalendar(ctx, db, t, office)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build calendar")
+			return
+		}
+
+		if _, err := db.NewUpdate().
+			Model(&feed).
+			Set("last_accessed_at = ?", time.Now()).
+			WherePK().
+			Exec(ctx); err != nil {
+			log.Printf("DB error: %v", err)
+		}
+
+		serveCalendar(w, calendarFeed, calendarFilename(t.name))
+	}
+}
+
+type importError struct {
+	UID   string `json:"uid"`
+	Error string `json:"error"`
+}
+
+// ImportAppointments books the events of an .ics file for a staff member:
+// POST /appointments/import?staff_id=&on_conflict=, with the file as a
+// text/calendar body or a multipart "file" field. Recurring events become
+// series and their edited or cancelled occurrences are applied. Each event
+// is booked on its own, so one that fails does not stop the rest; events
+// already imported or cancelled are skipped.
+func ImportAppointments(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	office := calendar.Office(cfg.OfficeTimezone)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), importTimeout)
+		defer cancel()
+
+		user := utils.GetUserFromContext(r.Context())
+		if user == nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		mode, err := parseConflictMode(r.URL.Query().Get("on_conflict"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		staffID := user.UserID
+		if value := r.URL.Query().Get("staff_id"); value != "" {
+			if staffID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff_id")
+				return
+			}
+		}
+		var staff models.Staff
+		if err := db.NewSelect().Model(&staff).Where("id = ?", staffID).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, errStaffNotFound.Error())
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to import appointments")
+			return
+		}
+		if !models.SeesAllDepartments(models.RoleEnum(user.Role), models.DepartmentEnum(user.Department)) &&
+			staff.Department != models.DepartmentEnum(user.Department) {
+			utils.RespondWithError(w, http.StatusForbidden, "You may only import appointments for staff of your own department")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+		var body io.Reader = r.Body
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Expected the calendar in a multipart \"file\" field")
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		entries, err := calendar.Parse(body, office)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Calendar file is too large")
+				return
+			}
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid iCalendar file: "+err.Error())
+			return
+		}
+
+		created, skipped := 0, 0
+		failures := make([]importError, 0)
+		for _, entry := range entries {
+			ok, err := importEntry(ctx, db, entry, &staff, office, mode)
+			switch {
+			case err != nil:
+				failures = append(failures, importError{UID: entry.UID, Error: importErrorMessage(err)})
+			case ok:
+				created++
+			default:
+				skipped++
+			}
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"created": created,
+			"skipped": skipped,
+			"errors":  failures,
+		})
+	}
+}
+
+// importErrorMessage is what the client is told about an event that failed.
+// Database errors are logged rather than shown.
+func importErrorMessage(err error) string {
+	var ve *validationError
+	var se *scheduleError
+	var sse *seriesScheduleError
+	switch {
+	case errors.As(err, &ve), errors.As(err, &se), errors.As(err, &sse),
+		errors.Is(err, errOverrideWithoutRRULE), errors.Is(err, calendar.ErrInvalidRule):
+		return err.Error()
+	}
+	log.Printf("DB error: %v", err)
+	return "Failed to import event"
+}
+
+// importEntry books one event with its edited occurrences, reporting whether
+// anything was booked.
+func importEntry(ctx context.Context, db *bun.DB, entry *calendar.Entry, staff *models.Staff, office *time.Location, mode conflictMode) (bool, error) {
+	master := entry.Master
+	switch {
+	case entry.UID == "":
+		return false, &validationError{errors.New("event has no UID")}
+	case master == nil:
+		return false, errOverrideWithoutRRULE
+	case master.Err != nil:
+		return false, &validationError{master.Err}
+	case master.Cancelled:
+		return false, nil
+	}
+
+	imported, err := db.NewSelect().
+		Model((*models.AppointmentSeries)(nil)).
+		Where("ics_uid = ?", entry.UID).
+		Exists(ctx)
+	if err == nil && !imported {
+		imported, err = db.NewSelect().
+			Model((*models.Appointment)(nil)).
+			Where("ics_uid = ?", entry.UID).
+			Where("series_id IS NULL").
+			Exists(ctx)
+	}
+	if err != nil || imported {
+		return false, err
+	}
+
+	if master.Rule == nil {
+		a := importedAppointment(master, staff)
+		a.ICSUID = entry.UID
+		if err := a.Validate(); err != nil {
+			return false, &validationError{err}
+		}
+		if err := saveAppointment(ctx, db, a, mode); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	series := &models.AppointmentSeries{
+		VisitorName:     master.Visitor,
+		VisitorEmail:    master.VisitorEmail,
+		Purpose:         master.Summary,
+		WhoToSee:        staff.FullName(),
+		StaffID:         staff.ID,
+		Department:      staff.Department,
+		Priority:        master.Priority,
+		Notes:           master.Description,
+		StartsAt:        master.Start,
+		DurationMinutes: int(master.End.Sub(master.Start) / time.Minute),
+		RRule:           master.Rule.String(),
+		ExDates:         master.ExDates,
+		ICSUID:          entry.UID,
+	}
+	if series.ExDates == nil {
+		series.ExDates = []time.Time{}
+	}
+	if err := series.Validate(); err != nil {
+		return false, &validationError{err}
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := createSeriesTx(ctx, tx, series, office, mode); err != nil {
+			return err
+		}
+		windows, err := availabilityFor(ctx, tx, series.StaffID)
+		if err != nil {
+			return err
+		}
+		for _, o := range entry.Overrides {
+			if err := importOverride(ctx, tx, series, master, o, staff, windows, mode); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err == nil, err
+}
+
+// importOverride applies an edited or cancelled occurrence of an imported
+// series.
+func importOverride(ctx context.Context, tx bun.Tx, series *models.AppointmentSeries, master, o *calendar.Event, staff *models.Staff, windows []*models.StaffAvailability, mode conflictMode) error {
+	if o.Err != nil {
+		return &validationError{o.Err}
+	}
+	if o.Cancelled {
+		return cancelOccurrence(ctx, tx, series.ID, o.RecurrenceID)
+	}
+
+	var stored models.Appointment
+	err := tx.NewSelect().
+		Model(&stored).
+		Where("series_id = ?", series.ID).
+		Where("recurrence_id = ?::timestamp", o.RecurrenceID).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) && (o.RecurrenceID.Before(series.StartsAt) || series.IsExDate(o.RecurrenceID)) {
+		return nil
+	}
+
+	a := importedAppointment(o, staff)
+	if a.VisitorName == "" {
+		a.VisitorName = master.Visitor
+	}
+	if a.VisitorEmail == "" {
+		a.VisitorEmail = master.VisitorEmail
+	}
+	if a.Purpose == "" {
+		a.Purpose = master.Summary
+	}
+	a.ID = stored.ID
+	a.CreatedAt = stored.CreatedAt
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = a.UpdatedAt
+	}
+	a.SeriesID = series.ID
+	a.RecurrenceID = o.RecurrenceID
+	a.Detached = true
+	if err := a.Validate(); err != nil {
+		return &validationError{err}
+	}
+	if err := checkSchedule(ctx, tx, a, windows, mode); err != nil {
+		return err
+	}
+
+	if a.ID == 0 {
+		_, err = tx.NewInsert().Model(a).Exec(ctx)
+	} else {
+		_, err = tx.NewUpdate().Model(a).WherePK().Exec(ctx)
+	}
+	return err
+}
+
+func importedAppointment(e *calendar.Event, staff *models.Staff) *models.Appointment {
+	a := &models.Appointment{
+		VisitorName:  e.Visitor,
+		VisitorEmail: e.VisitorEmail,
+		Purpose:      e.Summary,
+		WhoToSee:     staff.FullName(),
+		StaffID:      staff.ID,
+		Department:   staff.Department,
+		TimeIn:       e.Start,
+		TimeOut:      e.End,
+		Priority:     e.Priority,
+		Notes:        e.Description,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	a.Normalize()
+	return a
+}
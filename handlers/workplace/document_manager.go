@@ -1,78 +1,244 @@
 package workplace
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"homeland/audit"
+	"homeland/listquery"
 	"homeland/models"
+	"homeland/pagination"
+	"homeland/storage"
 	"homeland/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
 )
 
-var allowedUploadPositions = map[models.PositionEnum]bool{
-	models.PositionSSA:        true,
-	models.PositionIT:         true,
-	models.PositionHR:         true,
-	models.PositionDirector:   true,
-	models.PositionCallCenter: true,
+// allowedDocumentTypes is checked against the sniffed content type, never the
+// client-supplied one. Office formats sniff as application/zip.
+var allowedDocumentTypes = map[string]bool{
+	"application/pdf":           true,
+	"application/zip":           true,
+	"image/png":                 true,
+	"image/jpeg":                true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"text/plain; charset=utf-8": true,
+	"text/csv; charset=utf-8":   true,
 }
 
-func UploadDocument(db *bun.DB) http.HandlerFunc {
+func newStorageKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("2006/01/") + hex.EncodeToString(b), nil
+}
+
+func UploadDocument(db *bun.DB, store storage.Backend, maxBytes int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := utils.GetUserFromContext(r.Context())
 
-		if user.Department != string(models.DeptHomelandSecurity) {
-			utils.RespondWithError(w, http.StatusForbidden, "Unauthorized: Only Homeland Security can upload documents")
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Expected a multipart/form-data upload")
 			return
 		}
 
+		// Blobs written by this request are removed on every error return, so
+		// only a saved document leaves one behind.
+		var stored []string
+		saved := false
+		defer func() {
+			if saved {
+				return
+			}
+			for _, key := range stored {
+				if err := store.Delete(context.Background(), key); err != nil {
+					log.Printf("Failed to remove orphaned document %s: %v", key, err)
+				}
+			}
+		}()
+
 		var doc models.Document
-		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Malformed multipart body")
+				return
+			}
+
+			switch part.FormName() {
+			case "name":
+				value, _ := io.ReadAll(io.LimitReader(part, 256))
+				doc.Name = strings.TrimSpace(string(value))
+
+			case "file":
+				if doc.StorageKey != "" {
+					utils.RespondWithError(w, http.StatusBadRequest, "Only one file may be uploaded per request")
+					return
+				}
+
+				head := make([]byte, 512)
+				n, err := io.ReadFull(part, head)
+				if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+					utils.RespondWithError(w, http.StatusBadRequest, "Failed to read upload")
+					return
+				}
+				head = head[:n]
+				if n == 0 {
+					utils.RespondWithError(w, http.StatusBadRequest, "Uploaded file is empty")
+					return
+				}
+
+				contentType := http.DetectContentType(head)
+				if !allowedDocumentTypes[contentType] {
+					utils.RespondWithError(w, http.StatusUnsupportedMediaType, "File type "+contentType+" is not allowed")
+					return
+				}
+
+				key, err := newStorageKey()
+				if err != nil {
+					utils.RespondWithError(w, http.StatusInternalServerError, "Failed to upload document")
+					return
+				}
+
+				hasher := sha256.New()
+				body := io.LimitReader(io.MultiReader(bytes.NewReader(head), part), maxBytes+1)
+				stored = append(stored, key)
+				size, err := store.Put(r.Context(), key, io.TeeReader(body, hasher))
+				if err != nil {
+					log.Printf("Storage error: %v", err)
+					utils.RespondWithError(w, http.StatusInternalServerError, "Failed to store document")
+					return
+				}
+				if size > maxBytes {
+					utils.RespondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds the %d byte limit", maxBytes))
+					return
+				}
+
+				doc.StorageKey = key
+				doc.OriginalFilename = filepath.Base(part.FileName())
+				doc.ContentType = contentType
+				doc.Size = size
+				doc.Checksum = hex.EncodeToString(hasher.Sum(nil))
+			}
+			part.Close()
+		}
+
+		if doc.StorageKey == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "A file field is required")
 			return
 		}
+		if doc.Name == "" {
+			doc.Name = doc.OriginalFilename
+		}
 
 		doc.UploadedBy = user.Email
 		doc.CreatedAt = time.Now()
 
-		_, err := db.NewInsert().Model(&doc).Exec(context.Background())
+		_, err = db.NewInsert().Model(&doc).Exec(context.Background())
 		if err != nil {
+			log.Printf("DB error: %v", err)
 			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to upload document")
 			return
 		}
+		saved = true
+
+		audit.SetResource(r.Context(), "documents", strconv.FormatInt(doc.ID, 10))
+		audit.SetAfter(r.Context(), doc)
 
 		utils.RespondWithJSON(w, http.StatusCreated, doc)
 	}
 }
 
+func DownloadDocument(db *bun.DB, store storage.Backend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		var doc models.Document
+		err := db.NewSelect().Model(&doc).Where("id = ?", id).Scan(r.Context())
+		if err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "Document not found")
+			return
+		}
+
+		if doc.StorageKey == "" {
+			utils.RespondWithError(w, http.StatusNotFound, "Document has no stored file")
+			return
+		}
+
+		object, err := store.Open(r.Context(), doc.StorageKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				utils.RespondWithError(w, http.StatusNotFound, "Stored file is missing")
+				return
+			}
+			log.Printf("Storage error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to open document")
+			return
+		}
+		defer object.Close()
+
+		w.Header().Set("Content-Type", doc.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.OriginalFilename}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", `"`+doc.Checksum+`"`)
+		http.ServeContent(w, r, doc.OriginalFilename, object.ModTime(), object)
+	}
+}
+
+// documentListSpec is the filter grammar of GET /documents; see listquery.
+var documentListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"uploaded_by":  listquery.Text,
+		"content_type": listquery.Text,
+		"size":         listquery.Int,
+		"created_at":   listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"name":       "",
+		"size":       "",
+		"created_at": "",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "created_at",
+	Search:      listquery.TextSearch("name", "original_filename"),
+}
+
 func GetDocuments(db *bun.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil || limit <= 0 {
-			limit = 10
-		}
-
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-		if err != nil || offset < 0 {
-			offset = 0
+		list, err := listquery.Parse(r.URL.Query(), documentListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
 		var docs []models.Document
 
-		err = db.NewSelect().Model(&docs).
-			Limit(limit).
-			Offset(offset).
-			Order("created_at DESC").
-			Scan(ctx)
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&docs)))
 
 		if err != nil {
 			log.Printf("DB error: %v", err)
@@ -86,16 +252,13 @@ func GetDocuments(db *bun.DB) http.HandlerFunc {
 			return
 		}
 
-		total, err := db.NewSelect().Model((*models.Document)(nil)).Count(ctx)
-		if err != nil {
-			log.Printf("Count query error: %v", err)
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch document count.")
-			return
-		}
+		docs, page := listquery.Paginate(list, r, docs, total, func(item models.Document) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
 
 		response := map[string]interface{}{
 			"data":       docs,
-			"pagination": map[string]int{"total": total, "limit": limit, "offset": offset},
+			"pagination": page,
 		}
 
 		utils.RespondWithJSON(w, http.StatusOK, response)
@@ -0,0 +1,63 @@
+package workplace
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"homeland/export"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+var appointmentColumns = []export.Column[models.Appointment]{
+	{Header: "id", Value: func(a *models.Appointment) interface{} { return a.ID }},
+	{Header: "appointment_date", Value: func(a *models.Appointment) interface{} { return a.AppointmentDate }},
+	{Header: "time_in", Value: func(a *models.Appointment) interface{} { return a.TimeIn }},
+	{Header: "time_out", Value: func(a *models.Appointment) interface{} { return a.TimeOut }},
+	{Header: "visitor_name", Value: func(a *models.Appointment) interface{} { return a.VisitorName }},
+	{Header: "visitor_email", Value: func(a *models.Appointment) interface{} { return a.VisitorEmail }},
+	{Header: "purpose", Value: func(a *models.Appointment) interface{} { return a.Purpose }},
+	{Header: "who_to_see", Value: func(a *models.Appointment) interface{} { return a.WhoToSee }},
+	{Header: "staff_id", Value: func(a *models.Appointment) interface{} { return a.StaffID }},
+	{Header: "department", Value: func(a *models.Appointment) interface{} { return string(a.Department) }},
+	{Header: "priority", Value: func(a *models.Appointment) interface{} { return string(a.Priority) }},
+	{Header: "notes", Value: func(a *models.Appointment) interface{} { return a.Notes }},
+	{Header: "created_at", Value: func(a *models.Appointment) interface{} { return a.CreatedAt }},
+}
+
+// ExportAppointments streams the appointments matching the list filters as
+// CSV or XLSX.
+func ExportAppointments(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		format, err := export.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, err := listquery.Parse(r.URL.Query(), appointmentListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		query := list.Order(list.Filter(db.NewSelect().Model((*models.Appointment)(nil))))
+
+		if err := export.Stream(ctx, w, db, query, format, "appointments", appointmentColumns); err != nil {
+			if errors.Is(err, export.ErrTooManyRows) {
+				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to export appointments")
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package workplace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// Staff without availability windows are offered slots within these
+	// hours.
+	defaultDayStart = 8 * time.Hour
+	defaultDayEnd   = 17 * time.Hour
+	slotStep        = 15 * time.Minute
+	defaultDuration = 30
+	maxDuration     = 8 * 60
+)
+
+var (
+	errStaffRequired       = errors.New("staff_id is required")
+	errStaffNotFound       = errors.New("staff_id does not match a staff member")
+	errDoubleBooked        = errors.New("staff member already has an appointment at that time")
+	errOutsideAvailability = errors.New("appointment is outside the staff member's availability")
+)
+
+// conflictMode is how a booking that overlaps another appointment or falls
+// outside availability is handled: rejected, or accepted with warnings.
+type conflictMode string
+
+const (
+	conflictReject conflictMode = "reject"
+	conflictWarn   conflictMode = "warn"
+)
+
+func parseConflictMode(value string) (conflictMode, error) {
+	switch conflictMode(value) {
+	case "", conflictReject:
+		return conflictReject, nil
+	case conflictWarn:
+		return conflictWarn, nil
+	}
+	return "", errors.New("on_conflict must be reject or warn")
+}
+
+// scheduleError carries what made a booking fail so the handler can show it.
+type scheduleError struct {
+	err          error
+	conflicts    []*models.Appointment
+	availability []*models.StaffAvailability
+}
+
+func (e *scheduleError) Error() string { return e.err.Error() }
+func (e *scheduleError) Unwrap() error { return e.err }
+
+// resolveStaff links a to its staff member and fills in WhoToSee. For older
+// clients an agent ID in who_to_see stands in for staff_id.
+func resolveStaff(ctx context.Context, db bun.IDB, a *models.Appointment) error {
+	var staff models.Staff
+	q := db.NewSelect().Model(&staff)
+	switch {
+	case a.StaffID != 0:
+		q = q.Where("id = ?", a.StaffID)
+	case a.WhoToSee != "":
+		q = q.Where("lower(agent_id) = lower(?)", a.WhoToSee)
+	default:
+		return errStaffRequired
+	}
+	if err := q.Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errStaffNotFound
+		}
+		return err
+	}
+
+	a.StaffID = staff.ID
+	a.WhoToSee = staff.FullName()
+	if a.Department == "" {
+		a.Department = staff.Department
+	}
+	return nil
+}
+
+func availabilityFor(ctx context.Context, db bun.IDB, staffID int64) ([]*models.StaffAvailability, error) {
+	windows := make([]*models.StaffAvailability, 0)
+	err := db.NewSelect().
+		Model(&windows).
+		Where("staff_id = ?", staffID).
+		Order("weekday ASC", "start_time ASC").
+		Scan(ctx)
+	return windows, err
+}
+
+// withinAvailability reports whether a fits in one of windows. Staff without
+// any windows can be booked at any time.
+func withinAvailability(a *models.Appointment, windows []*models.StaffAvailability) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	start, end := a.TimeIn.Sub(a.AppointmentDate), a.TimeOut.Sub(a.AppointmentDate)
+	for _, w := range windows {
+		if w.Weekday == a.AppointmentDate.Weekday() && start >= w.Start() && end <= w.End() {
+			return true
+		}
+	}
+	return false
+}
+
+func dayWindows(windows []*models.StaffAvailability, day time.Weekday) []*models.StaffAvailability {
+	out := make([]*models.StaffAvailability, 0)
+	for _, w := range windows {
+		if w.Weekday == day {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// overlapping returns the staff member's appointments that overlap [from,
+// to), other than exclude.
+func overlapping(ctx context.Context, db bun.IDB, staffID int64, from, to time.Time, exclude int64) ([]*models.Appointment, error) {
+	appointments := make([]*models.Appointment, 0)
+	q := db.NewSelect().
+		Model(&appointments).
+		Where("staff_id = ?", staffID).
+		Where("time_in < ?::timestamp", to).
+		Where("time_out > ?::timestamp", from).
+		Order("time_in ASC")
+	if exclude != 0 {
+		q = q.Where("id <> ?", exclude)
+	}
+	err := q.Scan(ctx)
+	return appointments, err
+}
+
+// lockStaff locks the staff member's row for the rest of tx. Bookings for the
+// same staff member are serialised on it so two requests cannot both take
+// the same time.
+func lockStaff(ctx context.Context, tx bun.Tx, staffID int64) error {
+	_, err := tx.NewSelect().
+		Model((*models.Staff)(nil)).
+		Column("id").
+		Where("id = ?", staffID).
+		For("UPDATE").
+		Exec(ctx)
+	return err
+}
+
+// checkSchedule checks a against the staff member's windows and other
+// appointments. In warn mode problems are recorded on a instead.
+func checkSchedule(ctx context.Context, db bun.IDB, a *models.Appointment, windows []*models.StaffAvailability, mode conflictMode) error {
+	conflicts, err := overlapping(ctx, db, a.StaffID, a.TimeIn, a.TimeOut, a.ID)
+	if err != nil {
+		return err
+	}
+
+	a.Conflicts, a.Warnings = nil, nil
+	if !withinAvailability(a, windows) {
+		if mode == conflictReject {
+			return &scheduleError{err: errOutsideAvailability, availability: dayWindows(windows, a.AppointmentDate.Weekday())}
+		}
+		a.Warnings = append(a.Warnings, errOutsideAvailability.Error())
+	}
+	if len(conflicts) > 0 {
+		if mode == conflictReject {
+			return &scheduleError{err: errDoubleBooked, conflicts: conflicts}
+		}
+		a.Conflicts = conflicts
+		a.Warnings = append(a.Warnings, errDoubleBooked.Error())
+	}
+	return nil
+}
+
+// saveAppointment validates a against the staff member's calendar and inserts
+// it, or updates it when it already has an ID.
+func saveAppointment(ctx context.Context, db *bun.DB, a *models.Appointment, mode conflictMode) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return saveAppointmentTx(ctx, tx, a, mode)
+	})
+}
+
+func saveAppointmentTx(ctx context.Context, tx bun.Tx, a *models.Appointment, mode conflictMode) error {
+	if err := lockStaff(ctx, tx, a.StaffID); err != nil {
+		return err
+	}
+	windows, err := availabilityFor(ctx, tx, a.StaffID)
+	if err != nil {
+		return err
+	}
+	if err := checkSchedule(ctx, tx, a, windows, mode); err != nil {
+		return err
+	}
+
+	if a.ID == 0 {
+		_, err = tx.NewInsert().Model(a).Exec(ctx)
+	} else {
+		_, err = tx.NewUpdate().Model(a).WherePK().Exec(ctx)
+	}
+	return err
+}
+
+// respondScheduleError answers the errors of resolveStaff and
+// saveAppointment, reporting whether err was one of them.
+func respondScheduleError(w http.ResponseWriter, err error) bool {
+	var se *scheduleError
+	switch {
+	case errors.Is(err, errStaffRequired), errors.Is(err, errStaffNotFound):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.As(err, &se) && errors.Is(err, errDoubleBooked):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     se.Error(),
+			"conflicts": se.conflicts,
+		})
+	case errors.As(err, &se):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":        se.Error(),
+			"availability": se.availability,
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// Slot is a free interval a visitor can be booked into.
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// freeSlots lists the duration-long slots on day, on a 15-minute grid, that
+// fit the windows and overlap none of booked. Slots starting before
+// notBefore are left out.
+func freeSlots(day time.Time, windows []*models.StaffAvailability, booked []*models.Appointment, duration time.Duration, notBefore time.Time) []Slot {
+	type span struct{ start, end time.Duration }
+
+	var open []span
+	if len(windows) == 0 {
+		open = []span{{defaultDayStart, defaultDayEnd}}
+	}
+	for _, w := range dayWindows(windows, day.Weekday()) {
+		open = append(open, span{w.Start(), w.End()})
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].start < open[j].start })
+
+	slots := make([]Slot, 0)
+	for _, o := range open {
+		for start := o.start; start+duration <= o.end; start += slotStep {
+			slot := Slot{Start: day.Add(start), End: day.Add(start + duration)}
+			if slot.Start.Before(notBefore) {
+				continue
+			}
+			free := true
+			for _, a := range booked {
+				if a.TimeIn.Before(slot.End) && a.TimeOut.After(slot.Start) {
+					free = false
+					break
+				}
+			}
+			if free {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	return slots
+}
+
+// GetFreeSlots lists the times a staff member can still see a visitor on a
+// day: GET /appointments/slots?staff_id=&date=YYYY-MM-DD&duration=minutes.
+func GetFreeSlots(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		query := r.URL.Query()
+		staffID, err := strconv.ParseInt(query.Get("staff_id"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "staff_id is required")
+			return
+		}
+		day, err := time.Parse("2006-01-02", query.Get("date"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "date is required, use YYYY-MM-DD")
+			return
+		}
+		minutes := defaultDuration
+		if value := query.Get("duration"); value != "" {
+			minutes, err = strconv.Atoi(value)
+			if err != nil || minutes < 1 || minutes > maxDuration {
+				utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("duration must be between 1 and %d minutes", maxDuration))
+				return
+			}
+		}
+		duration := time.Duration(minutes) * time.Minute
+
+		exists, err := db.NewSelect().Model((*models.Staff)(nil)).Where("id = ?", staffID).Exists(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch free slots")
+			return
+		}
+		if !exists {
+			utils.RespondWithError(w, http.StatusNotFound, "Staff not found")
+			return
+		}
+
+		windows, err := availabilityFor(ctx, db, staffID)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch free slots")
+			return
+		}
+		booked, err := overlapping(ctx, db, staffID, day, day.AddDate(0, 0, 1), 0)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch free slots")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"staff_id": staffID,
+			"date":     day.Format("2006-01-02"),
+			"duration": minutes,
+			"slots":    freeSlots(day, windows, booked, duration, models.WallClock(time.Now())),
+		})
+	}
+}
+
+func GetStaffAvailability(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "staffID"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		windows, err := availabilityFor(ctx, db, staffID)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch availability")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": windows})
+	}
+}
+
+type SetAvailabilityRequest struct {
+	Windows []*models.StaffAvailability `json:"windows"`
+}
+
+// SetStaffAvailability replaces a staff member's weekly availability. An
+// empty list makes the staff member bookable at any time. Existing
+// appointments are not affected.
+func SetStaffAvailability(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		staffID, err := strconv.ParseInt(chi.URLParam(r, "staffID"), 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid staff ID")
+			return
+		}
+
+		var req SetAvailabilityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		for i, window := range req.Windows {
+			if window == nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			if err := window.Validate(); err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			for _, other := range req.Windows[:i] {
+				if other.Weekday == window.Weekday && other.Start() < window.End() && window.Start() < other.End() {
+					utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("availability windows overlap on %s", window.Weekday))
+					return
+				}
+			}
+			window.ID = 0
+			window.StaffID = staffID
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			exists, err := tx.NewSelect().Model((*models.Staff)(nil)).Where("id = ?", staffID).Exists(ctx)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return sql.ErrNoRows
+			}
+			if _, err := tx.NewDelete().Model((*models.StaffAvailability)(nil)).Where("staff_id = ?", staffID).Exec(ctx); err != nil {
+				return err
+			}
+			if len(req.Windows) == 0 {
+				return nil
+			}
+			_, err = tx.NewInsert().Model(&req.Windows).Exec(ctx)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, http.StatusNotFound, "Staff not found")
+				return
+			}
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update availability")
+			return
+		}
+
+		windows, err := availabilityFor(ctx, db, staffID)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch availability")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"data": windows})
+	}
+}
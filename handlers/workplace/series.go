@@ -0,0 +1,638 @@
+package workplace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"homeland/audit"
+	"homeland/calendar"
+	"homeland/config"
+	"homeland/listquery"
+	"homeland/models"
+	"homeland/notify"
+	"homeland/pagination"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// Occurrences of a series are stored this far ahead; ExtendSeries tops
+	// them up as time passes.
+	seriesHorizon  = 365 * 24 * time.Hour
+	maxOccurrences = 500
+	// Writing a series checks and stores every occurrence, which takes longer
+	// than a single booking.
+	seriesTimeout = 30 * time.Second
+)
+
+var errNotAnOccurrence = errors.New("recurrence_id is not an occurrence of the series")
+
+// occurrenceError is why one occurrence of a series could not be booked.
+type occurrenceError struct {
+	RecurrenceID time.Time                   `json:"recurrence_id"`
+	Error        string                      `json:"error"`
+	Conflicts    []*models.Appointment       `json:"conflicts,omitempty"`
+	Availability []*models.StaffAvailability `json:"availability,omitempty"`
+}
+
+type seriesScheduleError struct {
+	occurrences []occurrenceError
+}
+
+func (e *seriesScheduleError) Error() string {
+	return fmt.Sprintf("%d occurrences of the series could not be booked", len(e.occurrences))
+}
+
+// seriesListSpec is the filter grammar of GET /appointments/series; see
+// listquery.
+var seriesListSpec = &listquery.Spec{
+	Filters: map[string]listquery.Kind{
+		"department": listquery.Text,
+		"priority":   listquery.Text,
+		"staff_id":   listquery.Int,
+		"starts_at":  listquery.Time,
+		"created_at": listquery.Time,
+	},
+	Sorts: map[string]string{
+		"id":         "",
+		"starts_at":  "",
+		"created_at": "",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "starts_at",
+	Search:      listquery.TextSearch("visitor_name", "purpose", "who_to_see", "notes"),
+}
+
+// resolveSeriesStaff is resolveStaff for a series.
+func resolveSeriesStaff(ctx context.Context, db bun.IDB, s *models.AppointmentSeries) error {
+	probe := s.Occurrence(s.StartsAt)
+	if err := resolveStaff(ctx, db, probe); err != nil {
+		return err
+	}
+	s.StaffID, s.WhoToSee, s.Department = probe.StaffID, probe.WhoToSee, probe.Department
+	return nil
+}
+
+// prepareSeries checks s and puts its times and rule in stored form.
+// Validation failures are returned wrapped in a *validationError.
+func prepareSeries(ctx context.Context, db bun.IDB, s *models.AppointmentSeries, office *time.Location) error {
+	s.Normalize()
+	if err := resolveSeriesStaff(ctx, db, s); err != nil {
+		return err
+	}
+	if err := s.Validate(); err != nil {
+		return &validationError{err}
+	}
+	rule, err := calendar.ParseRule(s.RRule, office)
+	if err != nil {
+		return &validationError{err}
+	}
+	s.RRule = rule.String()
+
+	exdates := make([]time.Time, 0, len(s.ExDates))
+	for _, d := range s.ExDates {
+		exdates = append(exdates, models.WallClock(d))
+	}
+	s.ExDates = exdates
+	return nil
+}
+
+// validationError marks a request the client has to correct.
+type validationError struct{ err error }
+
+func (e *validationError) Error() string { return e.err.Error() }
+func (e *validationError) Unwrap() error { return e.err }
+
+// respondSeriesError answers the errors of prepareSeries and the series
+// writes, reporting whether err was one of them.
+func respondSeriesError(w http.ResponseWriter, err error) bool {
+	var ve *validationError
+	var se *seriesScheduleError
+	switch {
+	case errors.As(err, &ve):
+		utils.RespondWithError(w, http.StatusBadRequest, ve.Error())
+	case errors.As(err, &se):
+		utils.RespondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":       se.Error(),
+			"occurrences": se.occurrences,
+		})
+	default:
+		return respondScheduleError(w, err)
+	}
+	return true
+}
+
+// generateOccurrences stores the occurrences of s starting in [from, until)
+// that are neither cancelled nor stored already, and records how far it got.
+// In reject mode a single occurrence that cannot be booked fails the lot.
+// tx must hold the staff member's lock.
+func generateOccurrences(ctx context.Context, tx bun.Tx, s *models.AppointmentSeries, from, until time.Time, mode conflictMode) ([]*models.Appointment, error) {
+	rule, err := calendar.ParseRule(s.RRule, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	starts := rule.Expand(s.StartsAt, from, until, maxOccurrences)
+	generatedUntil := until
+	if len(starts) == maxOccurrences {
+		generatedUntil = starts[len(starts)-1].Add(time.Second)
+	}
+
+	var stored []time.Time
+	err = tx.NewSelect().
+		Model((*models.Appointment)(nil)).
+		Column("recurrence_id").
+		Where("series_id = ?", s.ID).
+		Where("recurrence_id >= ?::timestamp", from).
+		Where("recurrence_id < ?::timestamp", until).
+		Scan(ctx, &stored)
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[int64]bool, len(stored))
+	for _, t := range stored {
+		taken[t.Unix()] = true
+	}
+
+	windows, err := availabilityFor(ctx, tx, s.StaffID)
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]*models.Appointment, 0, len(starts))
+	failed := make([]occurrenceError, 0)
+	now := time.Now()
+	for _, start := range starts {
+		if taken[start.Unix()] || s.IsExDate(start) {
+			continue
+		}
+		a := s.Occurrence(start)
+		a.CreatedAt, a.UpdatedAt = now, now
+		if err := checkSchedule(ctx, tx, a, windows, mode); err != nil {
+			var se *scheduleError
+			if !errors.As(err, &se) {
+				return nil, err
+			}
+			failed = append(failed, occurrenceError{
+				RecurrenceID: start,
+				Error:        se.Error(),
+				Conflicts:    se.conflicts,
+				Availability: se.availability,
+			})
+			continue
+		}
+		if _, err := tx.NewInsert().Model(a).Exec(ctx); err != nil {
+			return nil, err
+		}
+		created = append(created, a)
+	}
+	if len(failed) > 0 {
+		return nil, &seriesScheduleError{occurrences: failed}
+	}
+
+	s.GeneratedUntil = generatedUntil
+	_, err = tx.NewUpdate().
+		Model(s).
+		Column("generated_until").
+		WherePK().
+		Exec(ctx)
+	return created, err
+}
+
+// createSeries stores s and its occurrences.
+func createSeries(ctx context.Context, db *bun.DB, s *models.AppointmentSeries, office *time.Location, mode conflictMode) ([]*models.Appointment, error) {
+	var created []*models.Appointment
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		created, err = createSeriesTx(ctx, tx, s, office, mode)
+		return err
+	})
+	return created, err
+}
+
+// createSeriesTx stores s and its occurrences from the start of today, or
+// from its first occurrence when that is later.
+func createSeriesTx(ctx context.Context, tx bun.Tx, s *models.AppointmentSeries, office *time.Location, mode conflictMode) ([]*models.Appointment, error) {
+	now := calendar.Now(office)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if s.StartsAt.After(from) {
+		from = s.StartsAt
+	}
+	s.CreatedAt = time.Now()
+	s.UpdatedAt = s.CreatedAt
+
+	if err := lockStaff(ctx, tx, s.StaffID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.NewInsert().Model(s).Exec(ctx); err != nil {
+		return nil, err
+	}
+	return generateOccurrences(ctx, tx, s, from, now.Add(seriesHorizon), mode)
+}
+
+// cancelOccurrence records start as cancelled in the series and removes its
+// stored occurrence, if any.
+func cancelOccurrence(ctx context.Context, tx bun.Tx, seriesID int64, start time.Time) error {
+	_, err := tx.NewUpdate().
+		Model((*models.AppointmentSeries)(nil)).
+		Set("exdates = array_append(exdates, ?::timestamp)", start).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", seriesID).
+		Where("NOT (?::timestamp = ANY(exdates))", start).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NewDelete().
+		Model((*models.Appointment)(nil)).
+		Where("series_id = ?", seriesID).
+		Where("recurrence_id = ?::timestamp", start).
+		Exec(ctx)
+	return err
+}
+
+// CreateSeries books a recurring appointment. The body is a series: the
+// appointment fields plus starts_at, duration_minutes, an RFC 5545 rrule
+// such as "FREQ=WEEKLY;BYDAY=MO,WE" and optional exdates. Every stored
+// occurrence is checked like a single booking; with on_conflict=reject one
+// conflict rejects the series and lists the occurrences at fault.
+func CreateSeries(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	office := calendar.Office(cfg.OfficeTimezone)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), seriesTimeout)
+		defer cancel()
+
+		mode, err := parseConflictMode(r.URL.Query().Get("on_conflict"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var series models.AppointmentSeries
+		if err := json.NewDecoder(r.Body).Decode(&series); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		series.ID = 0
+		series.GeneratedUntil = time.Time{}
+		series.ICSUID = ""
+
+		if err := prepareSeries(ctx, db, &series, office); err != nil {
+			if !respondSeriesError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create appointment series")
+			}
+			return
+		}
+
+		occurrences, err := createSeries(ctx, db, &series, office, mode)
+		if err != nil {
+			if !respondSeriesError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create appointment series")
+			}
+			return
+		}
+
+		audit.SetResource(r.Context(), "appointment_series", strconv.FormatInt(series.ID, 10))
+		audit.SetAfter(r.Context(), series)
+		utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"series":      series,
+			"occurrences": occurrences,
+		})
+	}
+}
+
+func GetSeries(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		list, err := listquery.Parse(r.URL.Query(), seriesListSpec)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var series []models.AppointmentSeries
+		total, err := list.Scan(ctx, list.Apply(db.NewSelect().Model(&series)))
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch appointment series")
+			return
+		}
+
+		series, page := listquery.Paginate(list, r, series, total, func(item models.AppointmentSeries) pagination.Key {
+			return pagination.Key{Time: item.CreatedAt, ID: item.ID}
+		})
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       series,
+			"pagination": page,
+		})
+	}
+}
+
+func loadSeries(ctx context.Context, db bun.IDB, r *http.Request) (*models.AppointmentSeries, int, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("Invalid series ID")
+	}
+	var series models.AppointmentSeries
+	if err := db.NewSelect().Model(&series).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.StatusNotFound, errors.New("Appointment series not found")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	return &series, 0, nil
+}
+
+// GetSeriesByID returns a series with its next 50 occurrences.
+func GetSeriesByID(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	office := calendar.Office(cfg.OfficeTimezone)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		series, status, err := loadSeries(ctx, db, r)
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, status, "Failed to fetch appointment series")
+				return
+			}
+			utils.RespondWithError(w, status, err.Error())
+			return
+		}
+
+		occurrences := make([]models.Appointment, 0)
+		err = db.NewSelect().
+			Model(&occurrences).
+			Where("series_id = ?", series.ID).
+			Where("time_out > ?::timestamp", calendar.Now(office)).
+			Order("time_in ASC").
+			Limit(50).
+			Scan(ctx)
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch appointment series")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"series":      series,
+			"occurrences": occurrences,
+		})
+	}
+}
+
+// UpdateSeries changes a series from now on: upcoming occurrences are
+// rebuilt from the new template and rule. Past occurrences and occurrences
+// edited on their own are left as they are.
+func UpdateSeries(db *bun.DB, cfg *config.Config) http.HandlerFunc {
+	office := calendar.Office(cfg.OfficeTimezone)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), seriesTimeout)
+		defer cancel()
+
+		mode, err := parseConflictMode(r.URL.Query().Get("on_conflict"))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		before, status, err := loadSeries(ctx, db, r)
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, status, "Failed to update appointment series")
+				return
+			}
+			utils.RespondWithError(w, status, err.Error())
+			return
+		}
+		audit.SetBefore(r.Context(), before)
+
+		series := *before
+		if err := json.NewDecoder(r.Body).Decode(&series); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		series.ID = before.ID
+		series.ICSUID = before.ICSUID
+		series.CreatedAt = before.CreatedAt
+
+		if err := prepareSeries(ctx, db, &series, office); err != nil {
+			if !respondSeriesError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update appointment series")
+			}
+			return
+		}
+
+		now := calendar.Now(office)
+		from := now
+		if series.StartsAt.After(from) {
+			from = series.StartsAt
+		}
+		series.UpdatedAt = time.Now()
+
+		var occurrences []*models.Appointment
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := lockStaff(ctx, tx, series.StaffID); err != nil {
+				return err
+			}
+			if _, err := tx.NewDelete().
+				Model((*models.Appointment)(nil)).
+				Where("series_id = ?", series.ID).
+				Where("detached = false").
+				Where("time_in >= ?::timestamp", now).
+				Exec(ctx); err != nil {
+				return err
+			}
+			if _, err := tx.NewUpdate().Model(&series).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			var err error
+			occurrences, err = generateOccurrences(ctx, tx, &series, from, now.Add(seriesHorizon), mode)
+			if err != nil {
+				return err
+			}
+			return notify.SeriesUpdated(ctx, tx, before, &series)
+		})
+		if err != nil {
+			if !respondSeriesError(w, err) {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update appointment series")
+			}
+			return
+		}
+
+		audit.SetAfter(r.Context(), series)
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"series":      series,
+			"occurrences": occurrences,
+		})
+	}
+}
+
+// DeleteSeries deletes a series with all of its occurrences.
+func DeleteSeries(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		series, status, err := loadSeries(ctx, db, r)
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, status, "Failed to delete appointment series")
+				return
+			}
+			utils.RespondWithError(w, status, err.Error())
+			return
+		}
+		audit.SetBefore(r.Context(), series)
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewDelete().Model(series).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			return notify.SeriesCancelled(ctx, tx, series)
+		})
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete appointment series")
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Appointment series deleted"})
+	}
+}
+
+// CancelOccurrence cancels the occurrence of a series starting at
+// recurrence_id, including one too far ahead to be stored yet.
+func CancelOccurrence(db *bun.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		series, status, err := loadSeries(ctx, db, r)
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Printf("DB error: %v", err)
+				utils.RespondWithError(w, status, "Failed to cancel occurrence")
+				return
+			}
+			utils.RespondWithError(w, status, err.Error())
+			return
+		}
+		audit.SetBefore(r.Context(), series)
+
+		var body struct {
+			RecurrenceID time.Time `json:"recurrence_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RecurrenceID.IsZero() {
+			utils.RespondWithError(w, http.StatusBadRequest, "recurrence_id is required")
+			return
+		}
+		start := models.WallClock(body.RecurrenceID)
+
+		rule, err := calendar.ParseRule(series.RRule, time.UTC)
+		if err != nil {
+			log.Printf("Series %d has an invalid rule: %v", series.ID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to cancel occurrence")
+			return
+		}
+		if len(rule.Expand(series.StartsAt, start, start.Add(time.Second), 1)) == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, errNotAnOccurrence.Error())
+			return
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			// Occurrences beyond the generated range have no stored
+			// appointment and nobody to notify yet.
+			var stored models.Appointment
+			err := tx.NewSelect().
+				Model(&stored).
+				Where("series_id = ?", series.ID).
+				Where("recurrence_id = ?::timestamp", start).
+				Scan(ctx)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if err := cancelOccurrence(ctx, tx, series.ID, start); err != nil {
+				return err
+			}
+			if stored.ID != 0 {
+				if err := notify.AppointmentCancelled(ctx, tx, &stored); err != nil {
+					return err
+				}
+			}
+			return tx.NewSelect().Model(series).WherePK().Scan(ctx)
+		})
+		if err != nil {
+			log.Printf("DB error: %v", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to cancel occurrence")
+			return
+		}
+
+		audit.SetAfter(r.Context(), series)
+		utils.RespondWithJSON(w, http.StatusOK, series)
+	}
+}
+
+// ExtendSeries stores the occurrences of every series that have come within
+// the booking horizon since it was last extended. Occurrences that clash are
+// stored anyway, as a person would have to resolve them. It returns the
+// number of occurrences stored.
+func ExtendSeries(ctx context.Context, db *bun.DB, office *time.Location) (int, error) {
+	until := calendar.Now(office).Add(seriesHorizon)
+
+	var due []*models.AppointmentSeries
+	err := db.NewSelect().
+		Model(&due).
+		Where("generated_until IS NULL OR generated_until < ?::timestamp", until).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, s := range due {
+		from := s.GeneratedUntil
+		if from.IsZero() {
+			from = s.StartsAt
+		}
+		var created []*models.Appointment
+		err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := lockStaff(ctx, tx, s.StaffID); err != nil {
+				return err
+			}
+			var err error
+			created, err = generateOccurrences(ctx, tx, s, from, until, conflictWarn)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to extend appointment series %d: %v", s.ID, err)
+			continue
+		}
+		for _, a := range created {
+			if len(a.Warnings) > 0 {
+				log.Printf("Series %d occurrence at %s: %v", s.ID, a.TimeIn.Format("2006-01-02 15:04"), a.Warnings)
+			}
+		}
+		total += len(created)
+	}
+	return total, nil
+}
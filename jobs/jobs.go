@@ -0,0 +1,363 @@
+// Package jobs runs background work stored in the jobs table. Jobs survive
+// restarts, are retried with exponential backoff when they fail, and are
+// dead-lettered once they run out of attempts so someone can look at them
+// and retry them.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"homeland/models"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	DefaultMaxAttempts = 8
+
+	pollInterval = 5 * time.Second
+	batchSize    = 10
+	jobTimeout   = 2 * time.Minute
+	// A running job not finished within staleAfter is assumed to have lost
+	// its worker and is handed out again.
+	staleAfter = 10 * time.Minute
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Handler runs one job. Returning an error retries the job later, unless the
+// error is marked Permanent.
+type Handler func(ctx context.Context, job *models.Job) error
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one retrying will not fix, so the job is
+// dead-lettered straight away.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type options struct {
+	runAt       time.Time
+	dedupeKey   string
+	maxAttempts int
+}
+
+type Option func(*options)
+
+// At delays the job until t.
+func At(t time.Time) Option {
+	return func(o *options) { o.runAt = t }
+}
+
+// DedupeKey makes enqueueing a no-op when a job with the same key exists,
+// whatever its status.
+func DedupeKey(key string) Option {
+	return func(o *options) { o.dedupeKey = key }
+}
+
+func MaxAttempts(n int) Option {
+	return func(o *options) { o.maxAttempts = n }
+}
+
+// Enqueue stores a job of kind with payload encoded as JSON. Pass a
+// transaction to enqueue the job only if the surrounding change commits. It
+// returns nil when the job was dropped as a duplicate.
+func Enqueue(ctx context.Context, db bun.IDB, kind string, payload interface{}, opts ...Option) (*models.Job, error) {
+	o := options{runAt: time.Now(), maxAttempts: DefaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if payload == nil {
+		payload = struct{}{}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s payload: %w", kind, err)
+	}
+
+	now := time.Now()
+	job := &models.Job{
+		Kind:        kind,
+		Payload:     data,
+		Status:      models.JobPending,
+		RunAt:       o.runAt,
+		MaxAttempts: o.maxAttempts,
+		DedupeKey:   o.dedupeKey,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	q := db.NewInsert().Model(job)
+	if o.dedupeKey != "" {
+		q = q.On("CONFLICT (dedupe_key) DO NOTHING")
+	}
+	if _, err := q.Exec(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if job.ID == 0 {
+		return nil, nil
+	}
+	return job, nil
+}
+
+// Scheduler claims due jobs and runs them with the handler registered for
+// their kind.
+type Scheduler struct {
+	db       *bun.DB
+	worker   string
+	handlers map[string]Handler
+	periodic map[string]time.Duration
+}
+
+func New(db *bun.DB) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		db:       db,
+		worker:   fmt.Sprintf("%s/%d", host, os.Getpid()),
+		handlers: make(map[string]Handler),
+		periodic: make(map[string]time.Duration),
+	}
+}
+
+func (s *Scheduler) Register(kind string, h Handler) {
+	s.handlers[kind] = h
+}
+
+// Every registers h to run every interval. A periodic job is a single row
+// that is rescheduled after each run, failed or not, so it never
+// dead-letters.
+func (s *Scheduler) Every(kind string, interval time.Duration, h Handler) {
+	s.handlers[kind] = h
+	s.periodic[kind] = interval
+}
+
+// Run works through due jobs until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for kind := range s.periodic {
+		if _, err := Enqueue(ctx, s.db, kind, nil, DedupeKey("periodic:"+kind)); err != nil {
+			log.Printf("Failed to schedule periodic job %s: %v", kind, err)
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.requeueStale(ctx); err != nil {
+			log.Printf("Failed to requeue stale jobs: %v", err)
+		}
+		for {
+			claimed, err := s.claim(ctx)
+			if err != nil {
+				log.Printf("Failed to claim jobs: %v", err)
+				break
+			}
+			for _, job := range claimed {
+				s.run(ctx, job)
+			}
+			if len(claimed) < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks up to batchSize due jobs as running by this worker. Rows
+// locked by another worker are skipped rather than waited for.
+func (s *Scheduler) claim(ctx context.Context) ([]*models.Job, error) {
+	now := time.Now()
+	due := s.db.NewSelect().
+		Model((*models.Job)(nil)).
+		Column("id").
+		Where("status = ?", models.JobPending).
+		Where("run_at <= ?", now).
+		OrderExpr("run_at ASC, id ASC").
+		Limit(batchSize).
+		For("UPDATE SKIP LOCKED")
+
+	claimed := make([]*models.Job, 0)
+	err := s.db.NewUpdate().
+		Model((*models.Job)(nil)).
+		Set("status = ?", models.JobRunning).
+		Set("attempts = attempts + 1").
+		Set("locked_at = ?", now).
+		Set("locked_by = ?", s.worker).
+		Set("updated_at = ?", now).
+		Where("id IN (?)", due).
+		Returning("*").
+		Scan(ctx, &claimed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return claimed, nil
+	}
+	return claimed, err
+}
+
+// requeueStale hands out again the jobs whose worker died mid-run. The
+// interrupted run still counts as an attempt.
+func (s *Scheduler) requeueStale(ctx context.Context) error {
+	now := time.Now()
+	_, err := s.db.NewUpdate().
+		Model((*models.Job)(nil)).
+		Set("status = ?", models.JobPending).
+		Set("locked_at = NULL").
+		Set("locked_by = NULL").
+		Set("last_error = ?", "worker stopped before the job finished").
+		Set("updated_at = ?", now).
+		Where("status = ?", models.JobRunning).
+		Where("locked_at < ?", now.Add(-staleAfter)).
+		Exec(ctx)
+	return err
+}
+
+func (s *Scheduler) run(ctx context.Context, job *models.Job) {
+	handler, ok := s.handlers[job.Kind]
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	} else {
+		err = s.call(ctx, handler, job)
+	}
+	if err != nil {
+		log.Printf("Job %d (%s) attempt %d failed: %v", job.ID, job.Kind, job.Attempts, err)
+	}
+	if ferr := s.finish(ctx, job, err); ferr != nil {
+		log.Printf("Failed to record result of job %d: %v", job.ID, ferr)
+	}
+}
+
+// call runs handler with a timeout, turning a panic into a failed attempt.
+func (s *Scheduler) call(ctx context.Context, handler Handler, job *models.Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (s *Scheduler) finish(ctx context.Context, job *models.Job, runErr error) error {
+	now := time.Now()
+	next := decide(job, runErr, s.periodic[job.Kind], now)
+
+	q := s.db.NewUpdate().
+		Model(job).
+		Set("status = ?", next.status).
+		Set("locked_at = NULL").
+		Set("locked_by = NULL").
+		Set("updated_at = ?", now).
+		WherePK()
+	if next.runAt.IsZero() {
+		q = q.Set("finished_at = ?", now)
+	} else {
+		q = q.Set("run_at = ?", next.runAt)
+	}
+	if next.resetAttempts {
+		q = q.Set("attempts = 0")
+	}
+	if runErr != nil {
+		q = q.Set("last_error = ?", runErr.Error())
+	} else {
+		q = q.Set("last_error = NULL")
+	}
+	_, err := q.Exec(ctx)
+	return err
+}
+
+// outcome is what becomes of a job after a run.
+type outcome struct {
+	status models.JobStatus
+	// runAt is when the job runs again, or zero when it is finished.
+	runAt         time.Time
+	resetAttempts bool
+}
+
+// decide works out the outcome of a run of job that ended at now with runErr.
+// interval is how often the job runs if it is periodic, or zero.
+func decide(job *models.Job, runErr error, interval time.Duration, now time.Time) outcome {
+	var permanent *permanentError
+	switch {
+	case interval > 0:
+		// A failed run is retried with backoff; once out of attempts the
+		// job waits for its next regular run instead of dead-lettering.
+		if runErr != nil && job.Attempts < job.MaxAttempts && !errors.As(runErr, &permanent) {
+			if retry := now.Add(backoff(job.Attempts)); !retry.After(now.Add(interval)) {
+				return outcome{status: models.JobPending, runAt: retry}
+			}
+		}
+		return outcome{status: models.JobPending, runAt: now.Add(interval), resetAttempts: true}
+	case runErr == nil:
+		return outcome{status: models.JobSucceeded}
+	case errors.As(runErr, &permanent) || job.Attempts >= job.MaxAttempts:
+		return outcome{status: models.JobDead}
+	default:
+		return outcome{status: models.JobPending, runAt: now.Add(backoff(job.Attempts))}
+	}
+}
+
+// backoff is the wait before retrying after the given number of attempts:
+// doubling from baseBackoff up to maxBackoff, with up to 10% jitter so
+// failures do not retry in lockstep.
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts.
+func Retry(ctx context.Context, db bun.IDB, id int64) (*models.Job, error) {
+	var job models.Job
+	now := time.Now()
+	err := db.NewUpdate().
+		Model(&job).
+		Set("status = ?", models.JobPending).
+		Set("attempts = 0").
+		Set("run_at = ?", now).
+		Set("finished_at = NULL").
+		Set("updated_at = ?", now).
+		Where("id = ?", id).
+		Where("status = ?", models.JobDead).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Prune deletes jobs that succeeded more than age ago.
+func Prune(ctx context.Context, db bun.IDB, age time.Duration) (int64, error) {
+	res, err := db.NewDelete().
+		Model((*models.Job)(nil)).
+		Where("status = ?", models.JobSucceeded).
+		Where("finished_at < ?", time.Now().Add(-age)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
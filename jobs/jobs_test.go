@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"homeland/models"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{attempts: 0, base: 30 * time.Second},
+		{attempts: 1, base: 30 * time.Second},
+		{attempts: 2, base: time.Minute},
+		{attempts: 3, base: 2 * time.Minute},
+		{attempts: 8, base: 64 * time.Minute},
+		{attempts: 10, base: 256 * time.Minute},
+		{attempts: 11, base: maxBackoff},
+		{attempts: 100, base: maxBackoff},
+	}
+
+	for _, tt := range tests {
+		// Jitter adds up to a tenth, so sample a few times.
+		for i := 0; i < 20; i++ {
+			got := backoff(tt.attempts)
+			if got < tt.base || got > tt.base+tt.base/10 {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempts, got, tt.base, tt.base+tt.base/10)
+			}
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	failed := errors.New("smtp: connection refused")
+
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		interval time.Duration
+		status   models.JobStatus
+		// retryAfter is the backoff base the next run is due after, or zero
+		// when the job finishes or runs at its next interval.
+		retryAfter time.Duration
+		finished   bool
+		reset      bool
+	}{
+		{name: "success", attempts: 1, status: models.JobSucceeded, finished: true},
+		{name: "failure is retried", attempts: 1, err: failed, status: models.JobPending, retryAfter: 30 * time.Second},
+		{name: "retries back off", attempts: 4, err: failed, status: models.JobPending, retryAfter: 4 * time.Minute},
+		{name: "out of attempts", attempts: 8, err: failed, status: models.JobDead, finished: true},
+		{name: "permanent failure", attempts: 1, err: Permanent(failed), status: models.JobDead, finished: true},
+		{name: "wrapped permanent failure", attempts: 1, err: errors.Join(errors.New("sending"), Permanent(failed)), status: models.JobDead, finished: true},
+		{name: "periodic success", attempts: 1, interval: time.Hour, status: models.JobPending, reset: true},
+		{name: "periodic failure is retried", attempts: 2, err: failed, interval: time.Hour, status: models.JobPending, retryAfter: time.Minute},
+		{name: "periodic retry past the interval", attempts: 5, err: failed, interval: 5 * time.Minute, status: models.JobPending, reset: true},
+		{name: "periodic out of attempts", attempts: 8, err: failed, interval: 24 * time.Hour, status: models.JobPending, reset: true},
+		{name: "periodic permanent failure", attempts: 1, err: Permanent(failed), interval: time.Hour, status: models.JobPending, reset: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &models.Job{Attempts: tt.attempts, MaxAttempts: DefaultMaxAttempts}
+			got := decide(job, tt.err, tt.interval, now)
+
+			if got.status != tt.status {
+				t.Errorf("status = %s, want %s", got.status, tt.status)
+			}
+			if got.resetAttempts != tt.reset {
+				t.Errorf("resetAttempts = %v, want %v", got.resetAttempts, tt.reset)
+			}
+			switch {
+			case tt.finished:
+				if !got.runAt.IsZero() {
+					t.Errorf("runAt = %v, want the job finished", got.runAt)
+				}
+			case tt.retryAfter > 0:
+				wait := got.runAt.Sub(now)
+				if wait < tt.retryAfter || wait > tt.retryAfter+tt.retryAfter/10 {
+					t.Errorf("runAt is %v away, want about %v", wait, tt.retryAfter)
+				}
+			default:
+				if want := now.Add(tt.interval); !got.runAt.Equal(want) {
+					t.Errorf("runAt = %v, want %v", got.runAt, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+// Package listquery parses the filter, sort, search and paging parameters
+// shared by the list endpoints and applies them to a bun select query.
+//
+// Filters name a field, optionally followed by an operator in brackets:
+//
+//	severity=High,Critical       any of the values
+//	severity[ne]=Low             eq, ne, gt, gte, lt, lte
+//	people_involved[gte]=2
+//	created_at[lt]=2024-06-01    times are RFC3339 or YYYY-MM-DD
+//
+// from and to are shorthand for the list's date field. sort takes a
+// comma-separated list of fields, each prefixed with "-" for descending
+// order, and q runs a full-text search on lists that support it. Paging
+// parameters are read by the pagination package.
+package listquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/pagination"
+
+	"github.com/uptrace/bun"
+)
+
+type Kind int
+
+const (
+	Text Kind = iota
+	Int
+	Time
+	Bool
+)
+
+// Spec describes what a list endpoint lets clients filter and sort on. Field
+// names are column names of the queried model.
+type Spec struct {
+	// Filters maps each filterable field to the type of its values.
+	Filters map[string]Kind
+	// Sorts maps each sortable field to the SQL expression it orders by, or
+	// to "" to order by the column itself.
+	Sorts map[string]string
+	// DefaultSort is used when the request has no sort, e.g. "-created_at".
+	// It must be a single descending timestamp so the default order can be
+	// paged by keyset.
+	DefaultSort string
+	// DateField is the field from and to apply to.
+	DateField string
+	// Search is a tsvector expression matched against q. Lists without one
+	// reject q.
+	Search string
+	// TieBreak is a column that, with id, identifies a row, for lists over
+	// several tables whose ids repeat. Rows are ordered by it after id.
+	TieBreak string
+
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Error is a malformed list parameter; handlers answer it with 400.
+type Error struct {
+	Param   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func invalid(param, format string, args ...interface{}) *Error {
+	return &Error{Param: param, Message: fmt.Sprintf(format, args...)}
+}
+
+// reserved parameters are never treated as filters.
+var reserved = map[string]bool{
+	"limit": true, "offset": true, "cursor": true, "include_total": true,
+	"sort": true, "q": true, "from": true, "to": true,
+}
+
+var operators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=",
+}
+
+type condition struct {
+	column string
+	op     string
+	values []interface{}
+}
+
+type order struct {
+	expr string
+	desc bool
+}
+
+// Query is a parsed list request.
+type Query struct {
+	spec       *Spec
+	conditions []condition
+	orders     []order
+	sorted     bool
+	search     string
+	// count is the filtered query before paging, which totals are counted
+	// on.
+	count *bun.SelectQuery
+
+	Page *pagination.Page
+}
+
+// Parse validates values against spec. Parameters that are neither reserved
+// nor bracketed and do not name a filter are ignored, so handlers can read
+// their own parameters alongside.
+func Parse(values url.Values, spec *Spec) (*Query, error) {
+	q := &Query{spec: spec}
+
+	defaultLimit, maxLimit := spec.DefaultLimit, spec.MaxLimit
+	if defaultLimit == 0 {
+		defaultLimit = 10
+	}
+	if maxLimit == 0 {
+		maxLimit = 100
+	}
+	page, err := pagination.Parse(values, defaultLimit, maxLimit)
+	switch {
+	case errors.Is(err, pagination.ErrInvalidLimit):
+		return nil, invalid("limit", "Invalid limit, must be a positive integer")
+	case errors.Is(err, pagination.ErrInvalidOffset):
+		return nil, invalid("offset", "Invalid offset, must be a non-negative integer")
+	case err != nil:
+		return nil, invalid("cursor", "Invalid cursor")
+	}
+	q.Page = page
+	if err := q.parseSort(values.Get("sort")); err != nil {
+		return nil, err
+	}
+
+	if search := strings.TrimSpace(values.Get("q")); search != "" {
+		if spec.Search == "" {
+			return nil, invalid("q", "Full-text search is not supported on this list")
+		}
+		q.search = search
+	}
+
+	if page.Cursor != nil && page.Cursor.Key != nil && !q.keyset() {
+		return nil, invalid("cursor", "Cursor does not match the requested sort or search")
+	}
+
+	if spec.DateField != "" {
+		for _, bound := range []struct{ param, op string }{{"from", ">="}, {"to", "<"}} {
+			if value := values.Get(bound.param); value != "" {
+				t, err := parseTime(value)
+				if err != nil {
+					return nil, invalid(bound.param, "Invalid %s time, use RFC3339 or YYYY-MM-DD", bound.param)
+				}
+				q.conditions = append(q.conditions, condition{column: spec.DateField, op: bound.op, values: []interface{}{t}})
+			}
+		}
+	}
+
+	for key, raw := range values {
+		if reserved[key] {
+			continue
+		}
+		field, opName := key, "eq"
+		if i := strings.IndexByte(key, '['); i >= 0 && strings.HasSuffix(key, "]") {
+			field, opName = key[:i], key[i+1:len(key)-1]
+		}
+
+		kind, ok := spec.Filters[field]
+		if !ok {
+			if field != key {
+				return nil, invalid(key, "Cannot filter on %s", field)
+			}
+			continue
+		}
+		op, ok := operators[opName]
+		if !ok || (kind == Text || kind == Bool) && op != "=" && op != "<>" {
+			return nil, invalid(key, "Unsupported operator %q for %s", opName, field)
+		}
+
+		cond := condition{column: field, op: op}
+		for _, value := range raw {
+			for _, part := range strings.Split(value, ",") {
+				v, err := parseValue(kind, strings.TrimSpace(part))
+				if err != nil {
+					return nil, invalid(key, "Invalid value %q for %s", part, field)
+				}
+				cond.values = append(cond.values, v)
+			}
+		}
+		if len(cond.values) > 1 && op != "=" && op != "<>" {
+			return nil, invalid(key, "%s takes a single value", key)
+		}
+		q.conditions = append(q.conditions, cond)
+	}
+
+	return q, nil
+}
+
+func (q *Query) parseSort(value string) error {
+	explicit := value != ""
+	q.sorted = explicit
+	if !explicit {
+		value = q.spec.DefaultSort
+	}
+
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		field := strings.TrimPrefix(key, "-")
+
+		expr, ok := q.spec.Sorts[field]
+		if !ok && explicit {
+			return invalid("sort", "Cannot sort on %s", field)
+		}
+		if expr == "" {
+			expr = "?TableAlias." + field
+		}
+		q.orders = append(q.orders, order{expr: expr, desc: desc})
+	}
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func parseValue(kind Kind, value string) (interface{}, error) {
+	switch kind {
+	case Int:
+		return strconv.ParseInt(value, 10, 64)
+	case Time:
+		return parseTime(value)
+	case Bool:
+		return strconv.ParseBool(value)
+	default:
+		if value == "" {
+			return nil, fmt.Errorf("empty value")
+		}
+		return value, nil
+	}
+}
+
+// Filter applies the filters and search without ordering or paging.
+func (q *Query) Filter(sq *bun.SelectQuery) *bun.SelectQuery {
+	for _, cond := range q.conditions {
+		column := bun.Ident(cond.column)
+		switch {
+		case len(cond.values) > 1 && cond.op == "=":
+			sq = sq.Where("?TableAlias.? IN (?)", column, bun.In(cond.values))
+		case len(cond.values) > 1:
+			sq = sq.Where("?TableAlias.? NOT IN (?)", column, bun.In(cond.values))
+		default:
+			sq = sq.Where("?TableAlias.? "+cond.op+" ?", column, cond.values[0])
+		}
+	}
+	if q.search != "" {
+		sq = sq.Where(q.spec.Search+" @@ websearch_to_tsquery('english', ?)", q.search)
+	}
+	return sq
+}
+
+// keyset reports whether the list is in its default order, which is paged by
+// keyset on the DefaultSort column.
+func (q *Query) keyset() bool {
+	return !q.sorted && q.search == "" && q.Page.CanKeyset()
+}
+
+// Apply filters, orders and pages sq.
+func (q *Query) Apply(sq *bun.SelectQuery) *bun.SelectQuery {
+	sq = q.Filter(sq)
+	q.count = sq.Clone()
+
+	if q.keyset() {
+		return q.Page.Keyset(sq, strings.TrimPrefix(q.spec.DefaultSort, "-"), q.spec.TieBreak)
+	}
+
+	return q.Page.Window(q.Order(sq))
+}
+
+// Order sorts sq as requested, without paging. A search without an explicit
+// sort ranks the best matches first.
+func (q *Query) Order(sq *bun.SelectQuery) *bun.SelectQuery {
+	if q.search != "" && !q.sorted {
+		sq = sq.OrderExpr("ts_rank("+q.spec.Search+", websearch_to_tsquery('english', ?)) DESC", q.search)
+	}
+	for _, o := range q.orders {
+		if o.desc {
+			sq = sq.OrderExpr(o.expr + " DESC")
+		} else {
+			sq = sq.OrderExpr(o.expr + " ASC")
+		}
+	}
+	// id breaks ties so pages do not overlap.
+	sq = sq.OrderExpr("?TableAlias.id DESC")
+	if q.spec.TieBreak != "" {
+		sq = sq.OrderExpr("?TableAlias.? DESC", bun.Ident(q.spec.TieBreak))
+	}
+	return sq
+}
+
+// Scan runs sq, counting the matching rows only when the client asked for a
+// total. The count ignores the cursor, so every page reports the same total.
+func (q *Query) Scan(ctx context.Context, sq *bun.SelectQuery) (int, error) {
+	if !q.Page.WithTotal {
+		return 0, sq.Scan(ctx)
+	}
+	if q.count == nil {
+		return sq.ScanAndCount(ctx)
+	}
+	if err := sq.Scan(ctx); err != nil {
+		return 0, err
+	}
+	return q.count.Count(ctx)
+}
+
+// Paginate trims items to the page and builds the pagination object of the
+// response. key gives an item's DefaultSort timestamp and id, and its
+// TieBreak value when the spec has one.
+func Paginate[T any](q *Query, r *http.Request, items []T, total int, key func(T) pagination.Key) ([]T, pagination.Info) {
+	return pagination.Finish(q.Page, r, items, total, key)
+}
+
+// TextSearch builds a tsvector expression over the given columns of the
+// queried table, for lists whose search columns are not indexed.
+func TextSearch(columns ...string) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = "coalesce(?TableAlias." + column + "::text, '')"
+	}
+	return "to_tsvector('english', " + strings.Join(parts, " || ' ' || ") + ")"
+}
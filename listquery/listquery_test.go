@@ -0,0 +1,207 @@
+package listquery
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"homeland/pagination"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+type item struct {
+	bun.BaseModel `bun:"table:items,alias:item"`
+
+	ID        int64     `bun:"id,pk"`
+	Name      string    `bun:"name"`
+	Priority  string    `bun:"priority"`
+	OwnerID   int64     `bun:"owner_id"`
+	Archived  bool      `bun:"archived"`
+	CreatedAt time.Time `bun:"created_at"`
+}
+
+var testSpec = &Spec{
+	Filters: map[string]Kind{
+		"name":       Text,
+		"owner_id":   Int,
+		"archived":   Bool,
+		"created_at": Time,
+	},
+	Sorts: map[string]string{
+		"name":       "",
+		"created_at": "",
+		"priority":   "CASE ?TableAlias.priority WHEN 'High' THEN 1 ELSE 2 END",
+	},
+	DefaultSort: "-created_at",
+	DateField:   "created_at",
+	Search:      TextSearch("name"),
+}
+
+// testDB renders queries without connecting to a database.
+var testDB = bun.NewDB(sql.OpenDB(pgdriver.NewConnector()), pgdialect.New())
+
+func TestParseErrors(t *testing.T) {
+	keyCursor := pagination.Cursor{Key: &pagination.Key{Time: time.Unix(0, 0), ID: 1}}.Encode()
+
+	tests := []struct {
+		name  string
+		query string
+		param string
+	}{
+		{name: "bad limit", query: "limit=0", param: "limit"},
+		{name: "bad offset", query: "offset=-1", param: "offset"},
+		{name: "bad cursor", query: "cursor=!!", param: "cursor"},
+		{name: "unknown sort", query: "sort=secret", param: "sort"},
+		{name: "search unsupported", query: "q=fire", param: "q"},
+		{name: "bad from", query: "from=yesterday", param: "from"},
+		{name: "unknown bracketed filter", query: "secret[eq]=1", param: "secret[eq]"},
+		{name: "unknown operator", query: "owner_id[like]=1", param: "owner_id[like]"},
+		{name: "range on text", query: "name[gt]=a", param: "name[gt]"},
+		{name: "range on bool", query: "archived[lt]=true", param: "archived[lt]"},
+		{name: "bad int", query: "owner_id=abc", param: "owner_id"},
+		{name: "bad bool", query: "archived=maybe", param: "archived"},
+		{name: "empty text", query: "name=a,", param: "name"},
+		{name: "range with several values", query: "owner_id[gt]=1,2", param: "owner_id[gt]"},
+		{name: "keyset cursor with sort", query: "sort=name&cursor=" + keyCursor, param: "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec := testSpec
+			if tt.param == "q" {
+				unsearchable := *testSpec
+				unsearchable.Search = ""
+				spec = &unsearchable
+			}
+			_, err = Parse(values, spec)
+			var listErr *Error
+			if !errors.As(err, &listErr) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.query, err)
+			}
+			if listErr.Param != tt.param {
+				t.Errorf("Parse(%q) param = %q, want %q", tt.query, listErr.Param, tt.param)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "default order is keyset",
+			query: "",
+			want:  []string{`ORDER BY "item"."created_at" DESC, "item".id DESC LIMIT 11`},
+		},
+		{
+			name:  "unknown plain parameters are ignored",
+			query: "format=csv",
+			want:  []string{`ORDER BY "item"."created_at" DESC, "item".id DESC LIMIT 11`},
+		},
+		{
+			name:  "equality",
+			query: "owner_id=7",
+			want:  []string{`WHERE ("item"."owner_id" = 7)`},
+		},
+		{
+			name:  "list is IN",
+			query: "name=a,b",
+			want:  []string{`WHERE ("item"."name" IN ('a', 'b'))`},
+		},
+		{
+			name:  "negated list is NOT IN",
+			query: "name[ne]=a,b",
+			want:  []string{`WHERE ("item"."name" NOT IN ('a', 'b'))`},
+		},
+		{
+			name:  "range",
+			query: "owner_id[gte]=3",
+			want:  []string{`WHERE ("item"."owner_id" >= 3)`},
+		},
+		{
+			name:  "bool",
+			query: "archived=false",
+			want:  []string{`WHERE ("item"."archived" = FALSE)`},
+		},
+		{
+			name:  "date bounds",
+			query: "from=2026-10-01",
+			want:  []string{`WHERE ("item"."created_at" >= '2026-10-01 00:00:00+00:00')`},
+		},
+		{
+			name:  "explicit sort pages by offset",
+			query: "sort=name,-created_at&limit=5&offset=10",
+			want:  []string{`ORDER BY "item".name ASC, "item".created_at DESC, "item".id DESC LIMIT 6 OFFSET 10`},
+		},
+		{
+			name:  "sort expression",
+			query: "sort=-priority",
+			want:  []string{`ORDER BY CASE "item".priority WHEN 'High' THEN 1 ELSE 2 END DESC, "item".id DESC`},
+		},
+		{
+			name:  "search ranks matches, newest first among equals",
+			query: "q=fire",
+			want: []string{
+				`@@ websearch_to_tsquery('english', 'fire')`,
+				`ORDER BY ts_rank(to_tsvector('english', coalesce("item".name::text, '')), websearch_to_tsquery('english', 'fire')) DESC, "item".created_at DESC, "item".id DESC`,
+			},
+		},
+		{
+			name:  "keyset cursor",
+			query: "cursor=" + pagination.Cursor{Key: &pagination.Key{Time: time.Unix(0, 0), ID: 4}}.Encode(),
+			want:  []string{`WHERE (("item"."created_at", "item".id) < ('1970-01-01 00:00:00+00:00', 4))`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := Parse(values, testSpec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.query, err)
+			}
+			got := q.Apply(testDB.NewSelect().Model((*item)(nil))).String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Apply(%q) =\n%s\nwant it to contain\n%s", tt.query, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTieBreak(t *testing.T) {
+	spec := *testSpec
+	spec.TieBreak = "priority"
+	cursor := pagination.Cursor{Key: &pagination.Key{Time: time.Unix(0, 0), ID: 4, Tie: "High"}, Backward: true}
+
+	q, err := Parse(url.Values{"cursor": {cursor.Encode()}}, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := q.Apply(testDB.NewSelect().Model((*item)(nil))).String()
+	for _, want := range []string{
+		`WHERE (("item"."created_at", "item".id, "item"."priority") > ('1970-01-01 00:00:00+00:00', 4, 'High'))`,
+		`ORDER BY "item"."created_at" ASC, "item".id ASC, "item"."priority" ASC`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Apply() =\n%s\nwant it to contain\n%s", got, want)
+		}
+	}
+}
@@ -6,12 +6,24 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	routes "homeland/api"
+	"homeland/calendar"
 	"homeland/config"
+	"homeland/events"
+	"homeland/handlers/auth"
+	"homeland/handlers/workplace"
+	"homeland/jobs"
+	"homeland/mfa"
 	"homeland/middleware"
 	"homeland/models"
+	"homeland/notify"
+	"homeland/passwordpolicy"
+	"homeland/rbac"
+	"homeland/storage"
+	"homeland/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/uptrace/bun"
@@ -30,27 +42,76 @@ func main() {
 	sqldb := sql.OpenDB(connector)
 	db := bun.NewDB(sqldb, pgdialect.New())
 
-	if err := createTables(db); err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if err := ensureSchemaCurrent(db); err != nil {
+		log.Fatal(err)
 	}
 
 	seedAdmin(db, cfg)
 
+	if err := utils.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	policy, err := rbac.Load(cfg.RBACPolicyFile)
+	if err != nil {
+		log.Fatalf("Failed to load RBAC policy: %v", err)
+	}
+
+	store, err := storage.NewLocalBackend(cfg.StorageDir)
+	if err != nil {
+		log.Fatalf("Failed to initialise document storage: %v", err)
+	}
+
+	mfaService, err := mfa.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialise two-factor authentication: %v", err)
+	}
+
+	broker := events.NewBroker(1000)
+
+	scheduler := jobs.New(db)
+	registerJobs(scheduler, db, cfg)
+	go scheduler.Run(context.Background())
+
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logging)
 
 	r.Route("/api/v1", func(r chi.Router) {
-		routes.RegisterAuthRoutes(r, db, cfg)
+		// Sign-in and password recovery carry no claims, so they are audited
+		// with an anonymous actor.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Audit(db))
+			routes.RegisterAuthRoutes(r, db, cfg, mfaService)
+		})
+		routes.RegisterCalendarFeedRoutes(r, db, cfg, policy)
 
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.JWTMiddleware(cfg.JWTSecret))
-
-			routes.RegisterAdminRoutes(r, db, cfg)
-			routes.RegisterStaffRoutes(r, db)
-			routes.RegisterIncidentRoutes(r, db)
-			routes.RegisterWorkplaceRoutes(r, db)
-			routes.RegisterReportingRoutes(r, db)
+			r.Use(middleware.ActiveSession(db))
+			r.Use(middleware.RequirePasswordChange("/api/v1/me/password", "/api/v1/logout"))
+			r.Use(middleware.RequireMFAEnrollment("/api/v1/me/mfa/enroll", "/api/v1/me/mfa/confirm", "/api/v1/me/password", "/api/v1/logout"))
+			r.Use(middleware.Audit(db))
+
+			routes.RegisterSessionRoutes(r, db)
+			routes.RegisterMeRoutes(r, db, cfg, policy, mfaService)
+			routes.RegisterAdminRoutes(r, db, cfg, policy)
+			routes.RegisterStaffRoutes(r, db, policy)
+			routes.RegisterIncidentRoutes(r, db, cfg, broker, policy)
+			routes.RegisterCallerRoutes(r, db, policy)
+			routes.RegisterWorkplaceRoutes(r, db, cfg, policy, store)
+			routes.RegisterCalendarRoutes(r, db, cfg, policy)
+			routes.RegisterVisitorRoutes(r, db, cfg, broker, policy)
+			routes.RegisterReportingRoutes(r, db, cfg, broker, policy)
+			routes.RegisterAnalyticsRoutes(r, db, policy)
+			routes.RegisterStreamRoutes(r, broker, policy)
 		})
 	})
 
@@ -60,51 +121,31 @@ func main() {
 	}
 }
 
-func createTables(db *bun.DB) error {
-	ctx := context.Background()
-
-	_, err := db.NewCreateTable().
-		Model((*models.Staff)(nil)).
-		IfNotExists().
-		Exec(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.NewCreateTable().
-		Model((*models.Incident)(nil)).
-		IfNotExists().
-		Exec(ctx)
-	if err != nil {
-		return err
-	}
+// jobRetention is how long finished jobs are kept before being pruned. Dead
+// jobs are kept until someone retries or removes them.
+const jobRetention = 30 * 24 * time.Hour
 
-	_, err = db.NewCreateTable().
-		Model((*models.Appointment)(nil)).
-		IfNotExists().
-		Exec(ctx)
-	if err != nil {
-		return err
-	}
+func registerJobs(s *jobs.Scheduler, db *bun.DB, cfg *config.Config) {
+	office := calendar.Office(cfg.OfficeTimezone)
 
-	_, err = db.NewCreateTable().
-		Model((*models.Document)(nil)).
-		IfNotExists().
-		Exec(ctx)
-	if err != nil {
+	// Book the occurrences of recurring appointments that come within the
+	// booking horizon.
+	s.Every("appointments.extend_series", 24*time.Hour, func(ctx context.Context, _ *models.Job) error {
+		n, err := workplace.ExtendSeries(ctx, db, office)
+		if n > 0 {
+			log.Printf("Booked %d occurrences of recurring appointments", n)
+		}
 		return err
-	}
-
-	_, err = db.NewCreateTable().
-		Model((*models.Report)(nil)).
-		IfNotExists().
-		Exec(ctx)
-	if err != nil {
+	})
+	s.Every("jobs.prune", 24*time.Hour, func(ctx context.Context, _ *models.Job) error {
+		n, err := jobs.Prune(ctx, db, jobRetention)
+		if n > 0 {
+			log.Printf("Pruned %d finished jobs", n)
+		}
 		return err
-	}
-
-	log.Println("Tables created or already exist.")
-	return nil
+	})
+	s.Register(auth.KindPasswordReset, auth.SendPasswordReset(db, cfg))
+	notify.Register(s, db, cfg)
 }
 
 func seedAdmin(db *bun.DB, cfg *config.Config) {
@@ -120,6 +161,11 @@ func seedAdmin(db *bun.DB, cfg *config.Config) {
 	}
 
 	if count == 0 {
+		policy := passwordpolicy.New(cfg)
+		if violations := policy.Validate("ADMIN_PASSWORD", cfg.AdminPassword, nil); len(violations) > 0 {
+			log.Printf("Warning: ADMIN_PASSWORD does not meet the password policy (%v)", violations)
+		}
+
 		hashed, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
 		if err != nil {
 			log.Printf("Failed to hash admin password: %v", err)
@@ -148,6 +194,10 @@ func seedAdmin(db *bun.DB, cfg *config.Config) {
 			return
 		}
 
+		if err := policy.Record(ctx, db, admin.ID, admin.Password); err != nil {
+			log.Printf("Error recording admin password history: %v", err)
+		}
+
 		log.Printf("Admin account seeded with email: %s", cfg.AdminEmail)
 	} else {
 		log.Println("Admin account already exists; skipping seeding")
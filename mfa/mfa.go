@@ -0,0 +1,312 @@
+package mfa
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"homeland/config"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/uptrace/bun"
+)
+
+const (
+	ChallengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+	// period and skew follow RFC 6238's recommendations: 30 second steps and
+	// one step of clock drift either way.
+	period = 30
+	skew   = 1
+)
+
+var (
+	ErrInvalidCode      = errors.New("invalid authentication code")
+	ErrInvalidChallenge = errors.New("invalid or expired MFA challenge")
+	ErrNotEnrolled      = errors.New("two-factor authentication is not set up")
+)
+
+var validateOpts = hotp.ValidateOpts{Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// Service enrolls staff in TOTP two-factor authentication and verifies their
+// codes. TOTP secrets are stored encrypted with AES-GCM.
+type Service struct {
+	issuer string
+	aead   cipher.AEAD
+}
+
+func New(cfg *config.Config) (*Service, error) {
+	if cfg.MFAEncryptionKey == "" {
+		return nil, errors.New("MFA_ENCRYPTION_KEY must be set")
+	}
+	if cfg.MFAEncryptionKey == cfg.JWTSecret {
+		return nil, errors.New("MFA_ENCRYPTION_KEY must differ from JWT_SECRET")
+	}
+
+	key := sha256.Sum256([]byte(cfg.MFAEncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{issuer: cfg.MFAIssuer, aead: aead}, nil
+}
+
+// Required reports whether staff with the given role must enroll.
+func Required(cfg *config.Config, role models.RoleEnum) bool {
+	for _, required := range cfg.MFARequiredRoles {
+		if required == string(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnrollmentPending reports whether staff must still enroll before using the
+// API.
+func EnrollmentPending(cfg *config.Config, staff *models.Staff) bool {
+	return Required(cfg, staff.Role) && !staff.MFAEnabled
+}
+
+// NewKey generates a TOTP secret for account. The key's URL is the otpauth://
+// provisioning URI authenticator apps read from a QR code.
+func (s *Service) NewKey(account string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: account,
+		Period:      period,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+}
+
+// Seal encrypts a TOTP secret for storage.
+func (s *Service) Seal(secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Service) open(sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < s.aead.NonceSize() {
+		return "", errors.New("sealed secret is too short")
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	secret, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// VerifyTOTP checks code against the staff member's stored secret and returns
+// the time step it matched. Steps at or before MFALastStep are rejected so a
+// code cannot be replayed.
+func (s *Service) VerifyTOTP(staff *models.Staff, code string) (int64, error) {
+	if staff.MFASecret == "" {
+		return 0, ErrNotEnrolled
+	}
+
+	secret, err := s.open(staff.MFASecret)
+	if err != nil {
+		return 0, fmt.Errorf("decrypt MFA secret: %w", err)
+	}
+
+	current := time.Now().Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= staff.MFALastStep {
+			continue
+		}
+		ok, err := hotp.ValidateCustom(code, uint64(step), secret, validateOpts)
+		if err != nil {
+			return 0, ErrInvalidCode
+		}
+		if ok {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// Authenticate accepts either a current TOTP code or an unused recovery code
+// for an enrolled staff member and records it as spent.
+func (s *Service) Authenticate(ctx context.Context, db bun.IDB, staff *models.Staff, code string) error {
+	if !staff.MFAEnabled {
+		return ErrNotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == validateOpts.Digits.Length() {
+		step, err := s.VerifyTOTP(staff, code)
+		if err != nil {
+			return err
+		}
+		staff.MFALastStep = step
+		_, err = db.NewUpdate().Model(staff).Column("mfa_last_step").WherePK().Exec(ctx)
+		return err
+	}
+
+	res, err := db.NewUpdate().
+		Model((*models.MFARecoveryCode)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("staff_id = ?", staff.ID).
+		Where("code_hash = ?", utils.HashToken(normalizeRecoveryCode(code))).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// GenerateRecoveryCodes replaces the staff member's recovery codes and returns
+// the new ones. They are only ever shown once.
+func GenerateRecoveryCodes(ctx context.Context, db bun.IDB, staffID int64) ([]string, error) {
+	if _, err := db.NewDelete().Model((*models.MFARecoveryCode)(nil)).Where("staff_id = ?", staffID).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(raw)
+		codes[i] = code[:4] + "-" + code[4:]
+		rows[i] = models.MFARecoveryCode{StaffID: staffID, CodeHash: utils.HashToken(code)}
+	}
+
+	if _, err := db.NewInsert().Model(&rows).Exec(ctx); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes the staff member's secret and recovery codes.
+func Disable(ctx context.Context, db bun.IDB, staffID int64) error {
+	_, err := db.NewUpdate().
+		Model((*models.Staff)(nil)).
+		Set("mfa_enabled = FALSE").
+		Set("mfa_secret = NULL").
+		Set("mfa_enrolled_at = NULL").
+		Set("mfa_last_step = 0").
+		Where("id = ?", staffID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().Model((*models.MFARecoveryCode)(nil)).Where("staff_id = ?", staffID).Exec(ctx)
+	return err
+}
+
+// CreateChallenge issues the short-lived token a client presents with its
+// second factor after the password has been verified.
+func CreateChallenge(ctx context.Context, db bun.IDB, staffID int64, userAgent, ipAddress string) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.NewInsert().Model(&models.MFAChallenge{
+		StaffID:   staffID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ChallengeTTL),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}).Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResolveChallenge verifies code for the challenge token and consumes the
+// challenge. A wrong code counts against the challenge, which is discarded
+// after maxChallengeAttempts; the staff member is still returned alongside
+// ErrInvalidCode so the failure can be recorded against the account.
+func (s *Service) ResolveChallenge(ctx context.Context, db *bun.DB, token, code string) (*models.Staff, error) {
+	var staff models.Staff
+	var badCode bool
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var challenge models.MFAChallenge
+		err := tx.NewSelect().
+			Model(&challenge).
+			Where("token_hash = ?", utils.HashToken(token)).
+			For("UPDATE").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidChallenge
+		}
+		if err != nil {
+			return err
+		}
+		if !challenge.UsedAt.IsZero() || time.Now().After(challenge.ExpiresAt) {
+			return ErrInvalidChallenge
+		}
+
+		if err := tx.NewSelect().Model(&staff).Where("id = ?", challenge.StaffID).For("UPDATE").Scan(ctx); err != nil {
+			return err
+		}
+
+		err = s.Authenticate(ctx, tx, &staff, code)
+		if errors.Is(err, ErrInvalidCode) {
+			badCode = true
+			challenge.Attempts++
+			if challenge.Attempts >= maxChallengeAttempts {
+				challenge.UsedAt = time.Now()
+			}
+			_, err = tx.NewUpdate().Model(&challenge).Column("attempts", "used_at").WherePK().Exec(ctx)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		challenge.UsedAt = time.Now()
+		_, err = tx.NewUpdate().Model(&challenge).Column("used_at").WherePK().Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if badCode {
+		return &staff, ErrInvalidCode
+	}
+	return &staff, nil
+}
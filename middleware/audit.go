@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeland/audit"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+)
+
+const maxAuditBody = 1 << 20
+
+// anonymousActor is the actor role recorded for requests without claims, such
+// as sign-in and password recovery.
+const anonymousActor = "anonymous"
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+var auditActions = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "update",
+	http.MethodDelete: "delete",
+}
+
+// Audit records every mutating request in audit_events. Handlers enrich the
+// record through the audit package; anything they leave unset is derived from
+// the route pattern and the JSON request body.
+func Audit(db *bun.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defaultAction, mutating := auditActions[r.Method]
+			if !mutating {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" || mediaType == "" {
+				body, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditBody))
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			}
+
+			ctx, entry := audit.WithEntry(r.Context())
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			// A rejected request changed nothing, so its body is not a
+			// state the resource ever had.
+			succeeded := status >= 200 && status < 300
+			if succeeded && !entry.HasAfter() && r.Method != http.MethodDelete {
+				audit.SetAfter(ctx, body)
+			}
+
+			event := buildAuditEvent(r, entry, defaultAction, status)
+
+			writeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := db.NewInsert().Model(event).Exec(writeCtx); err != nil {
+				log.Printf("Failed to write audit event for %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		})
+	}
+}
+
+// AuditRedact masks the named request body fields in the audit log of the
+// routes it wraps, for fields that are only secret there.
+func AuditRedact(fields ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			audit.Redact(r.Context(), fields...)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func buildAuditEvent(r *http.Request, entry *audit.Entry, defaultAction string, status int) *models.AuditEvent {
+	resourceType, resourceID, action := entry.Resource()
+	before, after := entry.States()
+
+	var pattern string
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		pattern = rctx.RoutePattern()
+		if resourceID == "" {
+			resourceID = rctx.URLParam("id")
+		}
+	}
+
+	segments := patternSegments(pattern)
+	if resourceType == "" && len(segments) > 0 {
+		resourceType = segments[0]
+	}
+	if action == "" {
+		action = defaultAction
+		// Sub-resource actions such as /incidents/{id}/transition are named
+		// after their trailing segment.
+		if n := len(segments); n > 1 && strings.HasPrefix(segments[n-2], "{") && !strings.HasPrefix(segments[n-1], "{") {
+			action = segments[n-1]
+		}
+	}
+	if resourceID == "" && after != nil {
+		if id, ok := after["id"]; ok && id != nil {
+			resourceID = jsonScalar(id)
+		}
+	}
+
+	event := &models.AuditEvent{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       before,
+		After:        after,
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		StatusCode:   status,
+		IPAddress:    utils.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+	}
+
+	if before != nil || after != nil {
+		changes := make(map[string]interface{})
+		for key, change := range audit.Diff(before, after) {
+			changes[key] = change
+		}
+		event.Changes = changes
+	}
+
+	if claims := utils.GetUserFromContext(r.Context()); claims != nil {
+		event.ActorID = claims.UserID
+		event.ActorEmail = claims.Email
+		event.ActorRole = claims.Role
+	} else {
+		event.ActorRole = anonymousActor
+	}
+
+	return event
+}
+
+// patternSegments returns the route pattern's path segments below /api/v1.
+func patternSegments(pattern string) []string {
+	pattern = strings.TrimPrefix(pattern, "/api/v1")
+	var segments []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment != "" && segment != "*" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func jsonScalar(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatInt(int64(value), 10)
+	default:
+		return ""
+	}
+}
@@ -8,16 +8,22 @@ import (
 	"homeland/utils"
 )
 
-type contextKey string
-
 const (
-	ContextKeyClaims = contextKey("claims")
+	ContextKeyClaims = utils.ContextKeyClaims
 )
 
+// WebSocketTokenProtocol is the subprotocol a browser offers, followed by
+// its access token, to authenticate a WebSocket handshake:
+// new WebSocket(url, ["bearer", token]).
+const WebSocketTokenProtocol = "bearer"
+
 func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				authHeader = webSocketToken(r)
+			}
 			if authHeader == "" {
 				http.Error(w, "Authorization header missing", http.StatusUnauthorized)
 				return
@@ -41,29 +47,26 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 	}
 }
 
-func RoleAuthorization(allowedRoles ...string) func(http.Handler) http.Handler {
-	roleSet := make(map[string]struct{})
-	for _, role := range allowedRoles {
-		roleSet[role] = struct{}{}
-	}
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims, ok := r.Context().Value(ContextKeyClaims).(*utils.Claims)
-			if !ok {
-				http.Error(w, "No claims found", http.StatusForbidden)
-				return
-			}
-			if _, allowed := roleSet[claims.Role]; !allowed {
-				http.Error(w, "Insufficient privileges", http.StatusForbidden)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 func FromContext(r *http.Request) *utils.Claims {
 	claims, _ := r.Context().Value(ContextKeyClaims).(*utils.Claims)
 	return claims
 }
+
+// webSocketToken reads the token of a WebSocket handshake offering the
+// protocols "bearer, <token>", since browsers cannot set headers on one. It
+// returns the token as an Authorization header value, or "" if there is none.
+func webSocketToken(r *http.Request) string {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	if len(protocols) != 2 || protocols[0] != WebSocketTokenProtocol {
+		return ""
+	}
+	return "Bearer " + protocols[1]
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"homeland/ratelimit"
+	"homeland/utils"
+)
+
+// RateLimit rejects requests with 429 once the bucket for keyFunc(r) is empty,
+// telling the client when to retry.
+func RateLimit(limiter *ratelimit.Limiter, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, retryAfter := limiter.Allow(keyFunc(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				utils.RespondWithError(w, http.StatusTooManyRequests, "Too many requests, try again later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
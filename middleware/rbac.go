@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"homeland/rbac"
+	"homeland/utils"
+)
+
+func SubjectFromClaims(claims *utils.Claims) rbac.Subject {
+	return rbac.Subject{
+		Role:       claims.Role,
+		Department: claims.Department,
+		Position:   claims.Position,
+	}
+}
+
+// RequirePermission rejects the request unless the policy grants the caller
+// action on resource.
+func RequirePermission(policy *rbac.Engine, resource, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := FromContext(r)
+			if claims == nil {
+				utils.RespondWithError(w, http.StatusUnauthorized, "No claims found")
+				return
+			}
+			if !policy.Allowed(SubjectFromClaims(claims), resource, action) {
+				utils.RespondWithError(w, http.StatusForbidden, "Insufficient privileges")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"homeland/utils"
+)
+
+// RequirePasswordChange confines tokens issued to staff who must change their
+// password to the given paths, so a temporary password cannot be used for
+// anything but replacing it.
+func RequirePasswordChange(allowedPaths ...string) func(http.Handler) http.Handler {
+	return restrictTo(allowedPaths, "Password change required before continuing", func(claims *utils.Claims) bool {
+		return claims.MustChangePassword
+	})
+}
+
+// RequireMFAEnrollment confines tokens of staff whose role requires two-factor
+// authentication, but who have not enrolled yet, to the given paths.
+func RequireMFAEnrollment(allowedPaths ...string) func(http.Handler) http.Handler {
+	return restrictTo(allowedPaths, "Two-factor authentication must be set up before continuing", func(claims *utils.Claims) bool {
+		return claims.MFAEnrollment
+	})
+}
+
+func restrictTo(allowedPaths []string, message string, restricted func(*utils.Claims) bool) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedPaths))
+	for _, path := range allowedPaths {
+		allowed[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := FromContext(r)
+			if claims != nil && restricted(claims) && !allowed[r.URL.Path] {
+				utils.RespondWithError(w, http.StatusForbidden, message)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
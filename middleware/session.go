@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"homeland/sessions"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// ActiveSession rejects access tokens whose session has been logged out,
+// revoked or has expired, so revocation takes effect before the token does.
+func ActiveSession(db *bun.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := FromContext(r)
+			if claims == nil || claims.SessionID == 0 {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Session required")
+				return
+			}
+
+			active, err := sessions.IsActive(r.Context(), db, claims.SessionID)
+			if err != nil {
+				log.Printf("Failed to check session %d: %v", claims.SessionID, err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify session")
+				return
+			}
+			if !active {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Session has ended, please log in again")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"homeland/migrations"
+
+	"github.com/uptrace/bun"
+)
+
+const migrateUsage = "usage: homeland migrate up | down [steps] | status"
+
+func runMigrateCommand(db *bun.DB, args []string) error {
+	ctx := context.Background()
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		for _, m := range applied {
+			log.Printf("Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("Schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		reverted, err := runner.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+// ensureSchemaCurrent refuses to start the server while migrations are pending
+// so handlers never run against a schema older than the models expect.
+func ensureSchemaCurrent(db *bun.DB) error {
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		return err
+	}
+
+	pending, err := runner.Pending(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check schema version: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind by %d migration(s), starting with %04d_%s; run `homeland migrate up`",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+
+	log.Println("Database schema is up to date")
+	return nil
+}
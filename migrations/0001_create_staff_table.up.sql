@@ -1,4 +1,4 @@
-CREATE TABLE staff (
+CREATE TABLE IF NOT EXISTS staff (
     id BIGSERIAL PRIMARY KEY,
     first_name VARCHAR(255) NOT NULL,
     middle_name VARCHAR(255),
@@ -7,7 +7,7 @@ CREATE TABLE staff (
     password TEXT NOT NULL,
     agent_id VARCHAR(255) UNIQUE NOT NULL,
     profile_photo TEXT,
-    position VARCHAR(50) NOT NULL CHECK (position IN ('SSA', 'Director', 'IT', 'Call Center', 'Staff')),
+    position VARCHAR(50) NOT NULL CHECK (position IN ('SSA', 'Director', 'IT', 'Call Center', 'Staff', 'HR')),
     address TEXT,
     department VARCHAR(50) NOT NULL CHECK (department IN ('Homeland Security', 'AVS', 'EMS', 'Fire Service')),
     date_of_birth DATE NOT NULL,
@@ -17,4 +17,3 @@ CREATE TABLE staff (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
 );
-
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS incidents (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    department VARCHAR(50) NOT NULL CHECK (department IN ('Homeland Security', 'AVS', 'EMS', 'Fire Service')),
+    incident_type VARCHAR(50) NOT NULL CHECK (incident_type IN ('Fire', 'Medical', 'Security', 'Other')),
+    severity VARCHAR(50) NOT NULL CHECK (severity IN ('Low', 'Moderate', 'High', 'Critical')),
+    caller_full_name VARCHAR(255) NOT NULL,
+    caller_phone_number VARCHAR(20) NOT NULL,
+    caller_location VARCHAR(255) NOT NULL,
+    people_involved INT NOT NULL,
+    incident_report TEXT NOT NULL,
+    -- The reporting staff member is a belongs-to relation; incidents are
+    -- records of fact and must outlive staff changes, so deletion is blocked.
+    staff_id BIGINT NOT NULL REFERENCES staff(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_incidents_staff_id ON incidents (staff_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS appointments (
+    id BIGSERIAL PRIMARY KEY,
+    visitor_name VARCHAR(255) NOT NULL,
+    purpose TEXT NOT NULL,
+    who_to_see VARCHAR(255) NOT NULL,
+    department VARCHAR(50) NOT NULL CHECK (department IN ('Homeland Security', 'AVS', 'EMS', 'Fire Service')),
+    appointment_date TIMESTAMP NOT NULL,
+    time_in TIMESTAMP NOT NULL,
+    time_out TIMESTAMP NOT NULL,
+    priority VARCHAR(20) NOT NULL CHECK (priority IN ('low', 'medium', 'high')),
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    severity VARCHAR(50) NOT NULL,
+    reported_by VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'Pending',
+    date_reported TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action_description TEXT,
+    photo_urls TEXT[],
+    department VARCHAR(50) NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS incident_status_history;
+
+ALTER TABLE incidents DROP COLUMN IF EXISTS status;
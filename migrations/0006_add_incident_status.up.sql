@@ -0,0 +1,15 @@
+ALTER TABLE incidents
+    ADD COLUMN IF NOT EXISTS status VARCHAR(50) NOT NULL DEFAULT 'Reported'
+    CHECK (status IN ('Reported', 'Triaged', 'Dispatched', 'On Scene', 'Resolved', 'Closed', 'Cancelled'));
+
+CREATE TABLE IF NOT EXISTS incident_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    incident_id BIGINT NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    changed_by BIGINT NOT NULL REFERENCES staff(id),
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_incident_status_history_incident_id ON incident_status_history (incident_id);
@@ -0,0 +1,3 @@
+ALTER TABLE reports DROP COLUMN IF EXISTS incident_id;
+
+DROP TABLE IF EXISTS dispatches;
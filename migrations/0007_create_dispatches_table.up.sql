@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS dispatches (
+    id BIGSERIAL PRIMARY KEY,
+    incident_id BIGINT NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    department VARCHAR(50) NOT NULL CHECK (department IN ('AVS', 'EMS', 'Fire Service')),
+    unit VARCHAR(255),
+    status VARCHAR(50) NOT NULL DEFAULT 'Pending' CHECK (status IN ('Pending', 'Acknowledged', 'Completed')),
+    notes TEXT,
+    dispatched_by BIGINT NOT NULL REFERENCES staff(id),
+    acknowledged_by BIGINT REFERENCES staff(id),
+    acknowledged_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispatches_department_status ON dispatches (department, status);
+CREATE INDEX IF NOT EXISTS idx_dispatches_incident_id ON dispatches (incident_id);
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS incident_id BIGINT REFERENCES incidents(id) ON DELETE SET NULL;
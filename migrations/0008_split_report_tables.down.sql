@@ -0,0 +1,33 @@
+DROP VIEW IF EXISTS report_summaries;
+
+CREATE TABLE reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    severity VARCHAR(50) NOT NULL,
+    reported_by VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'Pending',
+    date_reported TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action_description TEXT,
+    photo_urls TEXT[],
+    department VARCHAR(50) NOT NULL,
+    incident_id BIGINT REFERENCES incidents(id) ON DELETE SET NULL
+);
+
+INSERT INTO reports (report_name, location, severity, reported_by, status, date_reported,
+                     action_description, photo_urls, department, incident_id)
+SELECT report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id
+FROM fire_reports
+UNION ALL
+SELECT report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id
+FROM ems_reports
+UNION ALL
+SELECT report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id
+FROM avs_reports;
+
+DROP TABLE IF EXISTS avs_reports;
+DROP TABLE IF EXISTS ems_reports;
+DROP TABLE IF EXISTS fire_reports;
@@ -0,0 +1,89 @@
+CREATE TABLE IF NOT EXISTS fire_reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    severity VARCHAR(50) NOT NULL CHECK (severity IN ('Low', 'Moderate', 'High', 'Critical')),
+    reported_by VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'Pending',
+    date_reported TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action_description TEXT,
+    photo_urls TEXT[],
+    department VARCHAR(50) NOT NULL,
+    incident_id BIGINT REFERENCES incidents(id) ON DELETE SET NULL,
+    cause TEXT NOT NULL,
+    units_deployed INT NOT NULL CHECK (units_deployed >= 1),
+    property_damage_estimate NUMERIC(14, 2) NOT NULL CHECK (property_damage_estimate >= 0),
+    casualties INT NOT NULL CHECK (casualties >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS ems_reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    severity VARCHAR(50) NOT NULL CHECK (severity IN ('Low', 'Moderate', 'High', 'Critical')),
+    reported_by VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'Pending',
+    date_reported TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action_description TEXT,
+    photo_urls TEXT[],
+    department VARCHAR(50) NOT NULL,
+    incident_id BIGINT REFERENCES incidents(id) ON DELETE SET NULL,
+    patients INT NOT NULL CHECK (patients >= 0),
+    triage_category VARCHAR(50) NOT NULL CHECK (triage_category IN ('Immediate', 'Delayed', 'Minor', 'Expectant')),
+    hospital_transported_to VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS avs_reports (
+    id BIGSERIAL PRIMARY KEY,
+    report_name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    severity VARCHAR(50) NOT NULL CHECK (severity IN ('Low', 'Moderate', 'High', 'Critical')),
+    reported_by VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'Pending',
+    date_reported TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action_description TEXT,
+    photo_urls TEXT[],
+    department VARCHAR(50) NOT NULL,
+    incident_id BIGINT REFERENCES incidents(id) ON DELETE SET NULL,
+    vehicles_involved INT NOT NULL CHECK (vehicles_involved >= 1),
+    plate_numbers TEXT[],
+    road VARCHAR(255) NOT NULL,
+    injuries INT NOT NULL CHECK (injuries >= 0)
+);
+
+-- Legacy rows carry no domain data, so they are moved with neutral defaults.
+INSERT INTO fire_reports (report_name, location, severity, reported_by, status, date_reported,
+                          action_description, photo_urls, department, incident_id,
+                          cause, units_deployed, property_damage_estimate, casualties)
+SELECT report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'Unknown', 1, 0, 0
+FROM reports WHERE department = 'Fire Service';
+
+INSERT INTO ems_reports (report_name, location, severity, reported_by, status, date_reported,
+                         action_description, photo_urls, department, incident_id,
+                         patients, triage_category)
+SELECT report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 0, 'Minor'
+FROM reports WHERE department = 'EMS';
+
+INSERT INTO avs_reports (report_name, location, severity, reported_by, status, date_reported,
+                         action_description, photo_urls, department, incident_id,
+                         vehicles_involved, road, injuries)
+SELECT report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 1, location, 0
+FROM reports WHERE department = 'AVS';
+
+DROP TABLE IF EXISTS reports;
+
+CREATE OR REPLACE VIEW report_summaries AS
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'fire' AS report_type
+FROM fire_reports
+UNION ALL
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'ems' AS report_type
+FROM ems_reports
+UNION ALL
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'avs' AS report_type
+FROM avs_reports;
@@ -0,0 +1,10 @@
+ALTER TABLE documents
+    DROP COLUMN IF EXISTS storage_key,
+    DROP COLUMN IF EXISTS checksum_sha256,
+    DROP COLUMN IF EXISTS size,
+    DROP COLUMN IF EXISTS content_type,
+    DROP COLUMN IF EXISTS original_filename;
+
+UPDATE documents SET url = '' WHERE url IS NULL;
+
+ALTER TABLE documents ALTER COLUMN url SET NOT NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE documents
+    ALTER COLUMN url DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS original_filename VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS content_type VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS size BIGINT,
+    ADD COLUMN IF NOT EXISTS checksum_sha256 CHAR(64),
+    ADD COLUMN IF NOT EXISTS storage_key TEXT UNIQUE;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS login_attempts;
+
+ALTER TABLE staff
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS failed_logins;
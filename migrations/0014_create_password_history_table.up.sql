@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGSERIAL PRIMARY KEY,
+    staff_id BIGINT NOT NULL REFERENCES staff(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_staff_id ON password_history (staff_id, created_at DESC);
+
+INSERT INTO password_history (staff_id, password_hash, created_at)
+SELECT id, password, updated_at FROM staff
+WHERE NOT EXISTS (SELECT 1 FROM password_history WHERE password_history.staff_id = staff.id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_incidents_caller_id;
+ALTER TABLE incidents DROP COLUMN IF EXISTS caller_id;
+DROP TABLE IF EXISTS callers;
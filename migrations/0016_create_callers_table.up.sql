@@ -0,0 +1,64 @@
+CREATE TABLE IF NOT EXISTS callers (
+    id BIGSERIAL PRIMARY KEY,
+    -- Normalised to E.164 by the application; see utils.NormalizePhoneNumber.
+    phone_number VARCHAR(20) NOT NULL UNIQUE,
+    full_name VARCHAR(255),
+    last_known_location VARCHAR(255),
+    incident_count INT NOT NULL DEFAULT 0,
+    is_repeat BOOLEAN NOT NULL DEFAULT FALSE,
+    is_prank BOOLEAN NOT NULL DEFAULT FALSE,
+    flag_reason TEXT,
+    flagged_by BIGINT REFERENCES staff(id) ON DELETE SET NULL,
+    flagged_at TIMESTAMP,
+    first_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS caller_id BIGINT REFERENCES callers(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_incidents_caller_id ON incidents (caller_id, created_at DESC);
+
+-- Link existing incidents to callers. The function mirrors
+-- utils.NormalizePhoneNumber and is only needed for this backfill.
+CREATE OR REPLACE FUNCTION migration_normalize_phone(raw TEXT) RETURNS TEXT AS $$
+DECLARE
+    digits TEXT := regexp_replace(raw, '\D', '', 'g');
+BEGIN
+    IF btrim(raw) LIKE '+%' THEN
+        RETURN '+' || digits;
+    ELSIF digits LIKE '00%' THEN
+        RETURN '+' || substr(digits, 3);
+    ELSIF digits ~ '^234\d{10}$' THEN
+        RETURN '+' || digits;
+    ELSIF digits ~ '^0\d{10}$' THEN
+        RETURN '+234' || substr(digits, 2);
+    ELSIF digits ~ '^\d{10}$' THEN
+        RETURN '+234' || digits;
+    END IF;
+    RETURN digits;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+INSERT INTO callers (phone_number, full_name, last_known_location, incident_count, first_seen_at, last_seen_at)
+SELECT DISTINCT ON (phone_number)
+    phone_number, caller_full_name, caller_location,
+    COUNT(*) OVER (PARTITION BY phone_number),
+    MIN(created_at) OVER (PARTITION BY phone_number),
+    MAX(created_at) OVER (PARTITION BY phone_number)
+FROM (
+    SELECT migration_normalize_phone(caller_phone_number) AS phone_number, caller_full_name, caller_location, created_at
+    FROM incidents
+    WHERE caller_id IS NULL
+) normalized
+WHERE length(phone_number) BETWEEN 3 AND 16
+ORDER BY phone_number, created_at DESC
+ON CONFLICT (phone_number) DO NOTHING;
+
+UPDATE incidents SET caller_id = callers.id
+FROM callers
+WHERE incidents.caller_id IS NULL
+  AND callers.phone_number = migration_normalize_phone(incidents.caller_phone_number);
+
+DROP FUNCTION migration_normalize_phone(TEXT);
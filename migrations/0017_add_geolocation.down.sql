@@ -0,0 +1,42 @@
+DROP VIEW IF EXISTS report_summaries;
+
+CREATE VIEW report_summaries AS
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'fire' AS report_type
+FROM fire_reports
+UNION ALL
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'ems' AS report_type
+FROM ems_reports
+UNION ALL
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id, 'avs' AS report_type
+FROM avs_reports;
+
+ALTER TABLE avs_reports
+    DROP CONSTRAINT IF EXISTS avs_reports_coordinates_check,
+    DROP COLUMN IF EXISTS state,
+    DROP COLUMN IF EXISTS lga,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
+
+ALTER TABLE ems_reports
+    DROP CONSTRAINT IF EXISTS ems_reports_coordinates_check,
+    DROP COLUMN IF EXISTS state,
+    DROP COLUMN IF EXISTS lga,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
+
+ALTER TABLE fire_reports
+    DROP CONSTRAINT IF EXISTS fire_reports_coordinates_check,
+    DROP COLUMN IF EXISTS state,
+    DROP COLUMN IF EXISTS lga,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
+
+ALTER TABLE incidents
+    DROP CONSTRAINT IF EXISTS incidents_coordinates_check,
+    DROP COLUMN IF EXISTS state,
+    DROP COLUMN IF EXISTS lga,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,59 @@
+ALTER TABLE incidents
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS lga VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS state VARCHAR(100);
+
+ALTER TABLE fire_reports
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS lga VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS state VARCHAR(100);
+
+ALTER TABLE ems_reports
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS lga VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS state VARCHAR(100);
+
+ALTER TABLE avs_reports
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS lga VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS state VARCHAR(100);
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['incidents', 'fire_reports', 'ems_reports', 'avs_reports'] LOOP
+        IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = t || '_coordinates_check') THEN
+            EXECUTE format(
+                'ALTER TABLE %I ADD CONSTRAINT %I CHECK (
+                    (latitude IS NULL AND longitude IS NULL) OR
+                    (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+                )', t, t || '_coordinates_check');
+        END IF;
+        EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I (latitude, longitude) WHERE latitude IS NOT NULL',
+            'idx_' || t || '_coordinates', t);
+    END LOOP;
+END;
+$$;
+
+DROP VIEW IF EXISTS report_summaries;
+
+CREATE VIEW report_summaries AS
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id,
+       latitude, longitude, lga, state, 'fire' AS report_type
+FROM fire_reports
+UNION ALL
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id,
+       latitude, longitude, lga, state, 'ems' AS report_type
+FROM ems_reports
+UNION ALL
+SELECT id, report_name, location, severity, reported_by, status, date_reported,
+       action_description, photo_urls, department, incident_id,
+       latitude, longitude, lga, state, 'avs' AS report_type
+FROM avs_reports;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_incidents_created_at;
+DROP INDEX IF EXISTS idx_incidents_search_vector;
+ALTER TABLE incidents DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,14 @@
+-- Full-text search over the narrative and caller details; see the incident
+-- list spec in handlers/incident.
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(incident_report, '')), 'A') ||
+        setweight(to_tsvector('english',
+            coalesce(caller_full_name, '') || ' ' ||
+            coalesce(caller_phone_number, '') || ' ' ||
+            coalesce(caller_location, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_incidents_search_vector ON incidents USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS idx_incidents_created_at ON incidents (created_at DESC, id DESC);
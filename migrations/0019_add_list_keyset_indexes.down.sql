@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_avs_reports_date_reported;
+DROP INDEX IF EXISTS idx_ems_reports_date_reported;
+DROP INDEX IF EXISTS idx_fire_reports_date_reported;
+DROP INDEX IF EXISTS idx_documents_created_at;
+DROP INDEX IF EXISTS idx_appointments_created_at;
+DROP INDEX IF EXISTS idx_staff_created_at;
@@ -0,0 +1,7 @@
+-- Keyset pagination walks each list newest first by (timestamp, id).
+CREATE INDEX IF NOT EXISTS idx_staff_created_at ON staff (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_appointments_created_at ON appointments (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_documents_created_at ON documents (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_fire_reports_date_reported ON fire_reports (date_reported DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_ems_reports_date_reported ON ems_reports (date_reported DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_avs_reports_date_reported ON avs_reports (date_reported DESC, id DESC);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS staff_availability;
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_time_range_check;
+DROP INDEX IF EXISTS idx_appointments_staff_time;
+ALTER TABLE appointments DROP COLUMN IF EXISTS staff_id;
@@ -0,0 +1,39 @@
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS staff_id BIGINT REFERENCES staff(id) ON DELETE RESTRICT;
+
+-- Link existing appointments to the staff member named in who_to_see, by
+-- agent ID, email or full name.
+UPDATE appointments SET staff_id = staff.id
+FROM staff
+WHERE appointments.staff_id IS NULL
+  AND (
+      lower(btrim(appointments.who_to_see)) = lower(staff.agent_id)
+      OR lower(btrim(appointments.who_to_see)) = lower(staff.email)
+      OR lower(regexp_replace(btrim(appointments.who_to_see), '\s+', ' ', 'g')) =
+         lower(staff.first_name || ' ' || staff.last_name)
+  );
+
+CREATE INDEX IF NOT EXISTS idx_appointments_staff_time ON appointments (staff_id, time_in, time_out);
+
+-- Rows entered before validation may already violate this, so it only
+-- applies to new and updated rows.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'appointments_time_range_check') THEN
+        ALTER TABLE appointments ADD CONSTRAINT appointments_time_range_check CHECK (time_out > time_in) NOT VALID;
+    END IF;
+END;
+$$;
+
+-- Weekly hours in which a staff member accepts appointments. A staff member
+-- without any windows can be booked at any time.
+CREATE TABLE IF NOT EXISTS staff_availability (
+    id BIGSERIAL PRIMARY KEY,
+    staff_id BIGINT NOT NULL REFERENCES staff(id) ON DELETE CASCADE,
+    -- 0 is Sunday, as in Go's time.Weekday and PostgreSQL's extract(dow).
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL CHECK (end_time > start_time),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_staff_availability_staff ON staff_availability (staff_id, weekday);
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS visitors (
+    id BIGSERIAL PRIMARY KEY,
+    full_name VARCHAR(255) NOT NULL,
+    -- Normalised to E.164 by the application; see utils.NormalizePhoneNumber.
+    phone_number VARCHAR(20),
+    organization VARCHAR(255),
+    id_document_type VARCHAR(30) NOT NULL CHECK (id_document_type IN ('National ID', 'Passport', 'Driver''s Licence', 'Voter''s Card', 'Staff ID', 'Other')),
+    id_document_number VARCHAR(100) NOT NULL,
+    -- Photo taken at the front desk, stored as a document.
+    photo_document_id BIGINT REFERENCES documents(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (id_document_type, id_document_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_visitors_created_at ON visitors (created_at DESC, id DESC);
+
+-- A visit runs from check-in to check-out, optionally for an appointment.
+CREATE TABLE IF NOT EXISTS visits (
+    id BIGSERIAL PRIMARY KEY,
+    visitor_id BIGINT NOT NULL REFERENCES visitors(id) ON DELETE RESTRICT,
+    appointment_id BIGINT REFERENCES appointments(id) ON DELETE SET NULL,
+    host_staff_id BIGINT REFERENCES staff(id) ON DELETE SET NULL,
+    purpose TEXT NOT NULL,
+    badge_code VARCHAR(20) NOT NULL UNIQUE,
+    checked_in_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    checked_in_by BIGINT NOT NULL REFERENCES staff(id) ON DELETE RESTRICT,
+    checked_out_at TIMESTAMP,
+    checked_out_by BIGINT REFERENCES staff(id) ON DELETE RESTRICT,
+    CHECK (checked_out_at IS NULL OR checked_out_at >= checked_in_at)
+);
+
+-- A visitor can only be on site once, and an appointment only checked in once.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_visits_on_site ON visits (visitor_id) WHERE checked_out_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_visits_appointment ON visits (appointment_id) WHERE appointment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_visits_checked_in_at ON visits (checked_in_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_visits_visitor ON visits (visitor_id, checked_in_at DESC);
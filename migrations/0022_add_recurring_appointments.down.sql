@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS calendar_feeds;
+
+DROP INDEX IF EXISTS idx_appointments_ics_uid;
+DROP INDEX IF EXISTS idx_appointments_occurrence;
+ALTER TABLE appointments
+    DROP COLUMN IF EXISTS ics_uid,
+    DROP COLUMN IF EXISTS detached,
+    DROP COLUMN IF EXISTS recurrence_id,
+    DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS appointment_series;
@@ -0,0 +1,52 @@
+-- A series is a recurring appointment. Its occurrences are stored as
+-- ordinary appointments up to generated_until and topped up as time passes.
+CREATE TABLE IF NOT EXISTS appointment_series (
+    id BIGSERIAL PRIMARY KEY,
+    visitor_name VARCHAR(255) NOT NULL,
+    purpose TEXT NOT NULL,
+    who_to_see VARCHAR(255) NOT NULL,
+    staff_id BIGINT NOT NULL REFERENCES staff(id) ON DELETE RESTRICT,
+    department VARCHAR(50) NOT NULL CHECK (department IN ('Homeland Security', 'AVS', 'EMS', 'Fire Service')),
+    priority VARCHAR(20) NOT NULL CHECK (priority IN ('low', 'medium', 'high')),
+    notes TEXT,
+    -- Start of the first occurrence, the DTSTART of the rule.
+    starts_at TIMESTAMP NOT NULL,
+    duration_minutes INT NOT NULL CHECK (duration_minutes BETWEEN 1 AND 1440),
+    rrule TEXT NOT NULL,
+    -- Start times of cancelled occurrences.
+    exdates TIMESTAMP[] NOT NULL DEFAULT '{}',
+    generated_until TIMESTAMP,
+    -- UID of the event the series was imported from.
+    ics_uid VARCHAR(255) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_series_staff ON appointment_series (staff_id);
+CREATE INDEX IF NOT EXISTS idx_appointment_series_department ON appointment_series (department);
+
+ALTER TABLE appointments
+    ADD COLUMN IF NOT EXISTS series_id BIGINT REFERENCES appointment_series(id) ON DELETE CASCADE,
+    -- Original start of the occurrence, which stays put when it is moved.
+    ADD COLUMN IF NOT EXISTS recurrence_id TIMESTAMP,
+    -- Set once an occurrence is edited on its own; series edits leave it alone.
+    ADD COLUMN IF NOT EXISTS detached BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS ics_uid VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_appointments_occurrence ON appointments (series_id, recurrence_id) WHERE series_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_appointments_ics_uid ON appointments (ics_uid) WHERE ics_uid IS NOT NULL AND series_id IS NULL;
+
+-- Calendar clients cannot send a bearer token, so feeds are read with a
+-- secret token in the URL. Only its SHA-256 digest is stored.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id BIGSERIAL PRIMARY KEY,
+    staff_id BIGINT NOT NULL REFERENCES staff(id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('staff', 'department')),
+    target VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    last_accessed_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_feeds_staff ON calendar_feeds (staff_id) WHERE revoked_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_appointments_time_in;
+ALTER TABLE appointment_series DROP COLUMN IF EXISTS visitor_email;
+ALTER TABLE appointments DROP COLUMN IF EXISTS visitor_email;
+
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,30 @@
+-- Background jobs. Workers claim due pending jobs with FOR UPDATE SKIP
+-- LOCKED, so several server processes can share the table.
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 8 CHECK (max_attempts > 0),
+    last_error TEXT,
+    locked_at TIMESTAMP,
+    locked_by VARCHAR(100),
+    -- Enqueueing a job whose key is already taken is a no-op.
+    dedupe_key VARCHAR(255) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs (run_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs (locked_at) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_jobs_status_created ON jobs (status, created_at DESC, id DESC);
+
+-- Reminders and notifications go to the visitor as well as the host.
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS visitor_email VARCHAR(255);
+ALTER TABLE appointment_series ADD COLUMN IF NOT EXISTS visitor_email VARCHAR(255);
+
+-- The reminder sweep looks appointments up by start time alone.
+CREATE INDEX IF NOT EXISTS idx_appointments_time_in ON appointments (time_in);
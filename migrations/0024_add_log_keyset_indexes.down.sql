@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_incidents_caller_id ON incidents (caller_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+DROP INDEX IF EXISTS idx_incidents_caller_id_keyset;
+DROP INDEX IF EXISTS idx_callers_last_seen_at;
+DROP INDEX IF EXISTS idx_login_attempts_created_at;
+DROP INDEX IF EXISTS idx_audit_events_created_at_id;
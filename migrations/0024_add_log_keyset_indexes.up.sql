@@ -0,0 +1,7 @@
+-- The audit log, login attempts and callers are paged by keyset as well.
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at_id ON audit_events (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_callers_last_seen_at ON callers (last_seen_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_incidents_caller_id_keyset ON incidents (caller_id, created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+DROP INDEX IF EXISTS idx_incidents_caller_id;
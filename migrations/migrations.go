@@ -0,0 +1,261 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// advisoryLockID serialises migration runs across processes sharing a database.
+const advisoryLockID = 72_617_001
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type SchemaMigration struct {
+	bun.BaseModel `bun:"table:schema_migrations"`
+
+	Version   int64     `bun:"version,pk" json:"version"`
+	Name      string    `bun:"name,notnull" json:"name"`
+	AppliedAt time.Time `bun:"applied_at,nullzero,notnull,default:current_timestamp" json:"applied_at"`
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Runner struct {
+	db         *bun.DB
+	migrations []Migration
+}
+
+func NewRunner(db *bun.DB) (*Runner, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, migrations: migrations}, nil
+}
+
+// Load reads NNNN_name.up.sql / NNNN_name.down.sql pairs from fsys and returns
+// them ordered by version. Every version must have both halves.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || path.Ext(filename) != ".sql" {
+			continue
+		}
+
+		base := strings.TrimSuffix(filename, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", filename)
+		}
+
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name prefix", filename)
+		}
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", filename, err)
+		}
+
+		body, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+
+		if direction == ".up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func (r *Runner) ensureTable(ctx context.Context) error {
+	_, err := r.db.NewCreateTable().
+		Model((*SchemaMigration)(nil)).
+		IfNotExists().
+		Exec(ctx)
+	return err
+}
+
+func (r *Runner) applied(ctx context.Context, db bun.IDB) (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.NewSelect().Model(&rows).Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := r.applied(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			status.Applied = true
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (r *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for i, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, r.migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns the ones it applied.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range r.migrations {
+		m := m
+		ran := false
+		err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?)", advisoryLockID); err != nil {
+				return err
+			}
+
+			exists, err := tx.NewSelect().Model((*SchemaMigration)(nil)).Where("version = ?", m.Version).Exists(ctx)
+			if err != nil || exists {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+
+			_, err = tx.NewInsert().Model(&SchemaMigration{Version: m.Version, Name: m.Name}).Exec(ctx)
+			ran = err == nil
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s up: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// Down rolls back the most recently applied migrations, newest first.
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := r.migrations[i]
+		ran := false
+		err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?)", advisoryLockID); err != nil {
+				return err
+			}
+
+			res, err := tx.NewDelete().Model((*SchemaMigration)(nil)).Where("version = ?", m.Version).Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				return nil
+			}
+
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			ran = true
+			return nil
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s down: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
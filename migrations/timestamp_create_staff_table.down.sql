@@ -1 +0,0 @@
-DDROP TABLE IF EXISTS incidents;
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"net/mail"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -14,20 +17,247 @@ const (
 	PriorityHigh   PriorityEnum = "high"
 )
 
+// Appointment times are office wall-clock times; the columns carry no time
+// zone.
 type Appointment struct {
 	bun.BaseModel `bun:"table:appointments"`
 
-	ID              int64          `bun:"id,pk,autoincrement" json:"id"`
-	VisitorName     string         `bun:"visitor_name,notnull" json:"visitor_name"`
-	Purpose         string         `bun:"purpose,notnull" json:"purpose"`
+	ID          int64  `bun:"id,pk,autoincrement" json:"id"`
+	VisitorName string `bun:"visitor_name,notnull" json:"visitor_name"`
+	// VisitorEmail, when set, receives reminders and change notices.
+	VisitorEmail string `bun:"visitor_email,nullzero" json:"visitor_email,omitempty"`
+	Purpose      string `bun:"purpose,notnull" json:"purpose"`
+	// WhoToSee is the display name of the staff member identified by
+	// StaffID. It is filled in by the server.
 	WhoToSee        string         `bun:"who_to_see,notnull" json:"who_to_see"`
+	StaffID         int64          `bun:"staff_id,nullzero" json:"staff_id"`
 	Department      DepartmentEnum `bun:"department,notnull" json:"department"`
 	AppointmentDate time.Time      `bun:"appointment_date,notnull" json:"appointment_date"`
 	TimeIn          time.Time      `bun:"time_in,notnull" json:"time_in"`
 	TimeOut         time.Time      `bun:"time_out,notnull" json:"time_out"`
 	Priority        PriorityEnum   `bun:"priority,notnull" json:"priority"`
 	Notes           string         `bun:"notes" json:"notes"`
+	// SeriesID and RecurrenceID identify an occurrence of a recurring
+	// appointment; RecurrenceID is the occurrence's original start.
+	SeriesID     int64     `bun:"series_id,nullzero" json:"series_id,omitempty"`
+	RecurrenceID time.Time `bun:"recurrence_id,nullzero" json:"recurrence_id,omitempty"`
+	// Detached is set once an occurrence is edited on its own.
+	Detached bool   `bun:"detached,notnull,default:false" json:"detached,omitempty"`
+	ICSUID   string `bun:"ics_uid,nullzero" json:"-"`
+
+	Staff *Staff `bun:"rel:belongs-to,join:staff_id=id" json:"staff,omitempty"`
+	// Conflicts lists the overlapping appointments of a booking that was
+	// accepted with on_conflict=warn.
+	Conflicts []*Appointment `bun:"-" json:"conflicts,omitempty"`
+	Warnings  []string       `bun:"-" json:"warnings,omitempty"`
 
 	CreatedAt time.Time `bun:"created_at,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,default:current_timestamp" json:"updated_at"`
 }
+
+// WallClock drops t's zone, keeping the date and time of day as written, to
+// match the zone-less appointment columns.
+func WallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// normalizeEmail reduces a valid address such as `"Bob" <bob@example.com>`
+// to the bare address mail is sent to, leaving invalid ones for Validate to
+// reject.
+func normalizeEmail(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return address
+}
+
+// Normalize converts the times to wall-clock times, derives AppointmentDate
+// from TimeIn and reduces VisitorEmail to the bare address.
+func (a *Appointment) Normalize() {
+	a.VisitorEmail = normalizeEmail(a.VisitorEmail)
+	a.TimeIn = WallClock(a.TimeIn)
+	a.TimeOut = WallClock(a.TimeOut)
+	a.AppointmentDate = time.Date(a.TimeIn.Year(), a.TimeIn.Month(), a.TimeIn.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (a *Appointment) Validate() error {
+	if a.VisitorName == "" {
+		return errors.New("visitor_name is required")
+	}
+	if a.VisitorEmail != "" {
+		if _, err := mail.ParseAddress(a.VisitorEmail); err != nil {
+			return errors.New("visitor_email must be a valid email address")
+		}
+	}
+	if a.Purpose == "" {
+		return errors.New("purpose is required")
+	}
+	if a.StaffID == 0 {
+		return errors.New("staff_id is required")
+	}
+	switch a.Priority {
+	case PriorityLow, PriorityMedium, PriorityHigh:
+	default:
+		return errors.New("priority must be one of low, medium or high")
+	}
+	if a.TimeIn.IsZero() || a.TimeOut.IsZero() {
+		return errors.New("time_in and time_out are required")
+	}
+	if !a.TimeOut.After(a.TimeIn) {
+		return errors.New("time_out must be after time_in")
+	}
+	if a.TimeOut.Sub(a.AppointmentDate) > 24*time.Hour {
+		return errors.New("time_in and time_out must be on the same day")
+	}
+	return nil
+}
+
+// StaffAvailability is a weekly window in which a staff member accepts
+// appointments. Times are "HH:MM" office wall-clock times.
+type StaffAvailability struct {
+	bun.BaseModel `bun:"table:staff_availability"`
+
+	ID        int64        `bun:"id,pk,autoincrement" json:"id"`
+	StaffID   int64        `bun:"staff_id,notnull" json:"staff_id"`
+	Weekday   time.Weekday `bun:"weekday,notnull" json:"weekday"`
+	StartTime string       `bun:"start_time,type:time,notnull" json:"start_time"`
+	EndTime   string       `bun:"end_time,type:time,notnull" json:"end_time"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Start and End are the window's offsets from midnight.
+func (s *StaffAvailability) Start() time.Duration { return clockOffset(s.StartTime) }
+func (s *StaffAvailability) End() time.Duration   { return clockOffset(s.EndTime) }
+
+// clockOffset parses "HH:MM" or "HH:MM:SS", returning -1 when malformed.
+func clockOffset(value string) time.Duration {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+		}
+	}
+	return -1
+}
+
+func (s *StaffAvailability) Validate() error {
+	if s.Weekday < time.Sunday || s.Weekday > time.Saturday {
+		return errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	start, end := s.Start(), s.End()
+	if start < 0 || end < 0 {
+		return errors.New("start_time and end_time must be HH:MM")
+	}
+	if end <= start {
+		return fmt.Errorf("end_time must be after start_time on %s", s.Weekday)
+	}
+	s.StartTime = formatClock(start)
+	s.EndTime = formatClock(end)
+	return nil
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// AppointmentSeries is a recurring appointment. Its occurrences are stored as
+// Appointments up to GeneratedUntil.
+type AppointmentSeries struct {
+	bun.BaseModel `bun:"table:appointment_series,alias:series"`
+
+	ID           int64          `bun:"id,pk,autoincrement" json:"id"`
+	VisitorName  string         `bun:"visitor_name,notnull" json:"visitor_name"`
+	VisitorEmail string         `bun:"visitor_email,nullzero" json:"visitor_email,omitempty"`
+	Purpose      string         `bun:"purpose,notnull" json:"purpose"`
+	WhoToSee     string         `bun:"who_to_see,notnull" json:"who_to_see"`
+	StaffID      int64          `bun:"staff_id,notnull" json:"staff_id"`
+	Department   DepartmentEnum `bun:"department,notnull" json:"department"`
+	Priority     PriorityEnum   `bun:"priority,notnull" json:"priority"`
+	Notes        string         `bun:"notes" json:"notes"`
+	// StartsAt is the start of the first occurrence.
+	StartsAt        time.Time   `bun:"starts_at,notnull" json:"starts_at"`
+	DurationMinutes int         `bun:"duration_minutes,notnull" json:"duration_minutes"`
+	RRule           string      `bun:"rrule,notnull" json:"rrule"`
+	ExDates         []time.Time `bun:"exdates,array,notnull" json:"exdates"`
+	GeneratedUntil  time.Time   `bun:"generated_until,nullzero" json:"generated_until,omitempty"`
+	ICSUID          string      `bun:"ics_uid,nullzero" json:"-"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Normalize converts StartsAt to a wall-clock time and reduces VisitorEmail
+// to the bare address.
+func (s *AppointmentSeries) Normalize() {
+	s.VisitorEmail = normalizeEmail(s.VisitorEmail)
+	s.StartsAt = WallClock(s.StartsAt)
+}
+
+func (s *AppointmentSeries) Duration() time.Duration {
+	return time.Duration(s.DurationMinutes) * time.Minute
+}
+
+func (s *AppointmentSeries) Validate() error {
+	if s.StartsAt.IsZero() {
+		return errors.New("starts_at is required")
+	}
+	if s.DurationMinutes < 1 || s.DurationMinutes > 24*60 {
+		return errors.New("duration_minutes must be between 1 and 1440")
+	}
+	if s.RRule == "" {
+		return errors.New("rrule is required")
+	}
+	return s.Occurrence(s.StartsAt).Validate()
+}
+
+// Occurrence builds the appointment starting at start from the series.
+func (s *AppointmentSeries) Occurrence(start time.Time) *Appointment {
+	a := &Appointment{
+		VisitorName:  s.VisitorName,
+		VisitorEmail: s.VisitorEmail,
+		Purpose:      s.Purpose,
+		WhoToSee:     s.WhoToSee,
+		StaffID:      s.StaffID,
+		Department:   s.Department,
+		TimeIn:       start,
+		TimeOut:      start.Add(s.Duration()),
+		Priority:     s.Priority,
+		Notes:        s.Notes,
+		SeriesID:     s.ID,
+		RecurrenceID: start,
+	}
+	a.Normalize()
+	return a
+}
+
+// IsExDate reports whether the occurrence starting at start was cancelled.
+func (s *AppointmentSeries) IsExDate(start time.Time) bool {
+	for _, d := range s.ExDates {
+		if d.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+type CalendarFeedScope string
+
+const (
+	FeedScopeStaff      CalendarFeedScope = "staff"
+	FeedScopeDepartment CalendarFeedScope = "department"
+)
+
+// CalendarFeed lets a calendar client subscribe to a staff member's or a
+// department's appointments with a secret URL.
+type CalendarFeed struct {
+	bun.BaseModel `bun:"table:calendar_feeds"`
+
+	ID             int64             `bun:"id,pk,autoincrement" json:"id"`
+	StaffID        int64             `bun:"staff_id,notnull" json:"staff_id"`
+	Scope          CalendarFeedScope `bun:"scope,notnull" json:"scope"`
+	Target         string            `bun:"target,notnull" json:"target"`
+	TokenHash      string            `bun:"token_hash,notnull" json:"-"`
+	LastAccessedAt time.Time         `bun:"last_accessed_at,nullzero" json:"last_accessed_at,omitempty"`
+	RevokedAt      time.Time         `bun:"revoked_at,nullzero" json:"revoked_at,omitempty"`
+	CreatedAt      time.Time         `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type AuditEvent struct {
+	bun.BaseModel `bun:"table:audit_events"`
+
+	ID           int64                  `bun:"id,pk,autoincrement" json:"id"`
+	ActorID      int64                  `bun:"actor_id,nullzero" json:"actor_id,omitempty"`
+	ActorEmail   string                 `bun:"actor_email" json:"actor_email"`
+	ActorRole    string                 `bun:"actor_role" json:"actor_role"`
+	Action       string                 `bun:"action,notnull" json:"action"`
+	ResourceType string                 `bun:"resource_type,notnull" json:"resource_type"`
+	ResourceID   string                 `bun:"resource_id" json:"resource_id,omitempty"`
+	Before       map[string]interface{} `bun:"before,type:jsonb" json:"before,omitempty"`
+	After        map[string]interface{} `bun:"after,type:jsonb" json:"after,omitempty"`
+	Changes      map[string]interface{} `bun:"changes,type:jsonb" json:"changes,omitempty"`
+	Method       string                 `bun:"method,notnull" json:"method"`
+	Path         string                 `bun:"path,notnull" json:"path"`
+	StatusCode   int                    `bun:"status_code,notnull" json:"status_code"`
+	IPAddress    string                 `bun:"ip_address" json:"ip_address"`
+	UserAgent    string                 `bun:"user_agent" json:"user_agent"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Caller is everyone who has reported an incident, keyed by normalised phone
+// number so repeat calls from the same line are linked.
+type Caller struct {
+	bun.BaseModel `bun:"table:callers"`
+
+	ID                int64     `bun:"id,pk,autoincrement" json:"id"`
+	PhoneNumber       string    `bun:"phone_number,notnull,unique" json:"phone_number"`
+	FullName          string    `bun:"full_name" json:"full_name"`
+	LastKnownLocation string    `bun:"last_known_location" json:"last_known_location"`
+	IncidentCount     int       `bun:"incident_count,notnull,default:0" json:"incident_count"`
+	IsRepeat          bool      `bun:"is_repeat,notnull,default:false" json:"is_repeat"`
+	IsPrank           bool      `bun:"is_prank,notnull,default:false" json:"is_prank"`
+	FlagReason        string    `bun:"flag_reason" json:"flag_reason,omitempty"`
+	FlaggedBy         int64     `bun:"flagged_by,nullzero" json:"flagged_by,omitempty"`
+	FlaggedAt         time.Time `bun:"flagged_at,nullzero" json:"flagged_at,omitempty"`
+	FirstSeenAt       time.Time `bun:"first_seen_at,nullzero,notnull,default:current_timestamp" json:"first_seen_at"`
+	LastSeenAt        time.Time `bun:"last_seen_at,nullzero,notnull,default:current_timestamp" json:"last_seen_at"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type DispatchStatusEnum string
+
+const (
+	DispatchPending      DispatchStatusEnum = "Pending"
+	DispatchAcknowledged DispatchStatusEnum = "Acknowledged"
+	DispatchCompleted    DispatchStatusEnum = "Completed"
+)
+
+// ActiveDispatchStatuses are the statuses of a dispatch still being worked.
+var ActiveDispatchStatuses = []DispatchStatusEnum{DispatchPending, DispatchAcknowledged}
+
+type Dispatch struct {
+	bun.BaseModel `bun:"table:dispatches"`
+
+	ID             int64              `bun:"id,pk,autoincrement" json:"id"`
+	IncidentID     int64              `bun:"incident_id,notnull" json:"incident_id"`
+	Department     DepartmentEnum     `bun:"department,notnull" json:"department"`
+	Unit           string             `bun:"unit" json:"unit"`
+	Status         DispatchStatusEnum `bun:"status,notnull,default:'Pending'" json:"status"`
+	Notes          string             `bun:"notes" json:"notes"`
+	DispatchedBy   int64              `bun:"dispatched_by,notnull" json:"dispatched_by"`
+	AcknowledgedBy int64              `bun:"acknowledged_by,nullzero" json:"acknowledged_by,omitempty"`
+	AcknowledgedAt time.Time          `bun:"acknowledged_at,nullzero" json:"acknowledged_at,omitempty"`
+
+	Incident *Incident `bun:"rel:belongs-to,join:incident_id=id" json:"incident,omitempty"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
@@ -7,10 +7,15 @@ import (
 )
 
 type Document struct {
-	bun.BaseModel `bun:"table:documents"`
-	ID            int64     `bun:"id,pk,autoincrement"`
-	Name          string    `bun:"name,notnull"`
-	URL           string    `bun:"url,notnull"`
-	UploadedBy    string    `bun:"uploaded_by,notnull"`
-	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	bun.BaseModel    `bun:"table:documents"`
+	ID               int64     `bun:"id,pk,autoincrement"`
+	Name             string    `bun:"name,notnull"`
+	URL              string    `bun:"url,nullzero"`
+	UploadedBy       string    `bun:"uploaded_by,notnull"`
+	OriginalFilename string    `bun:"original_filename,nullzero"`
+	ContentType      string    `bun:"content_type,nullzero"`
+	Size             int64     `bun:"size,nullzero"`
+	Checksum         string    `bun:"checksum_sha256,nullzero"`
+	StorageKey       string    `bun:"storage_key,nullzero" json:"-"`
+	CreatedAt        time.Time `bun:"created_at,notnull,default:current_timestamp"`
 }
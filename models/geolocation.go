@@ -0,0 +1,29 @@
+package models
+
+import "errors"
+
+// Geolocation is embedded by records that can be placed on a map. Coordinates
+// are WGS 84 decimal degrees and are either both set or both absent.
+type Geolocation struct {
+	Latitude  *float64 `bun:"latitude" json:"latitude,omitempty"`
+	Longitude *float64 `bun:"longitude" json:"longitude,omitempty"`
+	LGA       string   `bun:"lga" json:"lga,omitempty"`
+	State     string   `bun:"state" json:"state,omitempty"`
+}
+
+func (g *Geolocation) HasCoordinates() bool {
+	return g.Latitude != nil && g.Longitude != nil
+}
+
+func (g *Geolocation) ValidateCoordinates() error {
+	if (g.Latitude == nil) != (g.Longitude == nil) {
+		return errors.New("latitude and longitude must be given together")
+	}
+	if g.Latitude != nil && (*g.Latitude < -90 || *g.Latitude > 90) {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if g.Longitude != nil && (*g.Longitude < -180 || *g.Longitude > 180) {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	return nil
+}
@@ -15,6 +15,10 @@ const (
 	SeverityCritical SeverityEnum = "Critical"
 )
 
+// SeverityRankSQL orders a severity column by urgency rather than
+// alphabetically, Critical highest.
+const SeverityRankSQL = "CASE ?TableAlias.severity WHEN 'Critical' THEN 4 WHEN 'High' THEN 3 WHEN 'Moderate' THEN 2 ELSE 1 END"
+
 type IncidentTypeEnum string
 
 const (
@@ -24,23 +28,88 @@ const (
 	IncidentOther    IncidentTypeEnum = "Other"
 )
 
+type IncidentStatusEnum string
+
+const (
+	StatusReported   IncidentStatusEnum = "Reported"
+	StatusTriaged    IncidentStatusEnum = "Triaged"
+	StatusDispatched IncidentStatusEnum = "Dispatched"
+	StatusOnScene    IncidentStatusEnum = "On Scene"
+	StatusResolved   IncidentStatusEnum = "Resolved"
+	StatusClosed     IncidentStatusEnum = "Closed"
+	StatusCancelled  IncidentStatusEnum = "Cancelled"
+)
+
+// incidentTransitions lists the statuses an incident may move to from each
+// status. Closed and Cancelled are terminal.
+var incidentTransitions = map[IncidentStatusEnum][]IncidentStatusEnum{
+	StatusReported:   {StatusTriaged, StatusCancelled},
+	StatusTriaged:    {StatusDispatched, StatusCancelled},
+	StatusDispatched: {StatusOnScene, StatusCancelled},
+	StatusOnScene:    {StatusResolved},
+	StatusResolved:   {StatusClosed, StatusOnScene},
+	StatusClosed:     {},
+	StatusCancelled:  {},
+}
+
+func (s IncidentStatusEnum) IsValid() bool {
+	_, ok := incidentTransitions[s]
+	return ok
+}
+
+func (s IncidentStatusEnum) CanTransitionTo(next IncidentStatusEnum) bool {
+	for _, allowed := range incidentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s IncidentStatusEnum) AllowedTransitions() []IncidentStatusEnum {
+	return incidentTransitions[s]
+}
+
 type Incident struct {
 	bun.BaseModel `bun:"table:incidents"`
 
-	ID                int64            `bun:"id,pk,autoincrement" json:"id"`
-	AgentID           string           `bun:"agent_id,notnull" json:"agent_id"`
-	Department        DepartmentEnum   `bun:"department,notnull" json:"department"`
-	IncidentType      IncidentTypeEnum `bun:"incident_type,notnull" json:"incident_type"`
-	Severity          SeverityEnum     `bun:"severity,notnull" json:"severity"`
-	CallerFullName    string           `bun:"caller_full_name,notnull" json:"caller_full_name"`
-	CallerPhoneNumber string           `bun:"caller_phone_number,notnull" json:"caller_phone_number"`
-	CallerLocation    string           `bun:"caller_location,notnull" json:"caller_location"`
-	PeopleInvolved    int              `bun:"people_involved,notnull" json:"people_involved"`
-	IncidentReport    string           `bun:"incident_report,notnull" json:"incident_report"`
-	StaffID           int64            `bun:"staff_id,notnull" json:"staff_id"`
+	ID                int64              `bun:"id,pk,autoincrement" json:"id"`
+	AgentID           string             `bun:"agent_id,notnull" json:"agent_id"`
+	Department        DepartmentEnum     `bun:"department,notnull" json:"department"`
+	IncidentType      IncidentTypeEnum   `bun:"incident_type,notnull" json:"incident_type"`
+	Severity          SeverityEnum       `bun:"severity,notnull" json:"severity"`
+	CallerFullName    string             `bun:"caller_full_name,notnull" json:"caller_full_name"`
+	CallerPhoneNumber string             `bun:"caller_phone_number,notnull" json:"caller_phone_number"`
+	CallerLocation    string             `bun:"caller_location,notnull" json:"caller_location"`
+	PeopleInvolved    int                `bun:"people_involved,notnull" json:"people_involved"`
+	IncidentReport    string             `bun:"incident_report,notnull" json:"incident_report"`
+	StaffID           int64              `bun:"staff_id,notnull" json:"staff_id"`
+	CallerID          int64              `bun:"caller_id,nullzero" json:"caller_id,omitempty"`
+	Status            IncidentStatusEnum `bun:"status,notnull,default:'Reported'" json:"status"`
+	Geolocation
+	// SearchVector is generated by the database; it is only read back by
+	// RETURNING *.
+	SearchVector string `bun:"search_vector,scanonly" json:"-"`
 
-	Staff *Staff `bun:"rel:belongs-to,join:staff_id=id" json:"staff"`
+	Staff      *Staff                   `bun:"rel:belongs-to,join:staff_id=id" json:"staff"`
+	Caller     *Caller                  `bun:"rel:belongs-to,join:caller_id=id" json:"caller,omitempty"`
+	Dispatches []*Dispatch              `bun:"rel:has-many,join:id=incident_id" json:"dispatches,omitempty"`
+	Reports    []*Report                `bun:"rel:has-many,join:id=incident_id" json:"reports,omitempty"`
+	History    []*IncidentStatusHistory `bun:"rel:has-many,join:id=incident_id" json:"history,omitempty"`
 
 	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
+
+type IncidentStatusHistory struct {
+	bun.BaseModel `bun:"table:incident_status_history"`
+
+	ID         int64              `bun:"id,pk,autoincrement" json:"id"`
+	IncidentID int64              `bun:"incident_id,notnull" json:"incident_id"`
+	FromStatus IncidentStatusEnum `bun:"from_status,nullzero" json:"from_status"`
+	ToStatus   IncidentStatusEnum `bun:"to_status,notnull" json:"to_status"`
+	ChangedBy  int64              `bun:"changed_by,notnull" json:"changed_by"`
+	Reason     string             `bun:"reason" json:"reason"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	// JobDead is a job that failed for good and waits for someone to retry
+	// it.
+	JobDead JobStatus = "dead"
+)
+
+// Job is a unit of background work run by the jobs scheduler.
+type Job struct {
+	bun.BaseModel `bun:"table:jobs"`
+
+	ID          int64           `bun:"id,pk,autoincrement" json:"id"`
+	Kind        string          `bun:"kind,notnull" json:"kind"`
+	Payload     json.RawMessage `bun:"payload,type:jsonb,notnull" json:"payload"`
+	Status      JobStatus       `bun:"status,notnull" json:"status"`
+	RunAt       time.Time       `bun:"run_at,notnull" json:"run_at"`
+	Attempts    int             `bun:"attempts,notnull" json:"attempts"`
+	MaxAttempts int             `bun:"max_attempts,notnull" json:"max_attempts"`
+	LastError   string          `bun:"last_error,nullzero" json:"last_error,omitempty"`
+	LockedAt    time.Time       `bun:"locked_at,nullzero" json:"locked_at,omitempty"`
+	LockedBy    string          `bun:"locked_by,nullzero" json:"locked_by,omitempty"`
+	DedupeKey   string          `bun:"dedupe_key,nullzero" json:"dedupe_key,omitempty"`
+
+	CreatedAt  time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	FinishedAt time.Time `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+}
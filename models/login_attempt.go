@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type LoginAttempt struct {
+	bun.BaseModel `bun:"table:login_attempts"`
+
+	ID        int64  `bun:"id,pk,autoincrement" json:"id"`
+	Email     string `bun:"email,notnull" json:"email"`
+	StaffID   int64  `bun:"staff_id,nullzero" json:"staff_id,omitempty"`
+	IPAddress string `bun:"ip_address" json:"ip_address"`
+	UserAgent string `bun:"user_agent" json:"user_agent"`
+	Success   bool   `bun:"success,notnull" json:"success"`
+	Reason    string `bun:"reason" json:"reason,omitempty"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator is unavailable. Only its hash is stored.
+type MFARecoveryCode struct {
+	bun.BaseModel `bun:"table:mfa_recovery_codes"`
+
+	ID       int64     `bun:"id,pk,autoincrement" json:"id"`
+	StaffID  int64     `bun:"staff_id,notnull" json:"staff_id"`
+	CodeHash string    `bun:"code_hash,notnull" json:"-"`
+	UsedAt   time.Time `bun:"used_at,nullzero" json:"used_at,omitempty"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// MFAChallenge is issued after a correct password for an account with MFA
+// enabled and is exchanged, together with a TOTP or recovery code, for a
+// session.
+type MFAChallenge struct {
+	bun.BaseModel `bun:"table:mfa_challenges"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`
+	StaffID   int64     `bun:"staff_id,notnull" json:"staff_id"`
+	TokenHash string    `bun:"token_hash,notnull,unique" json:"-"`
+	Attempts  int       `bun:"attempts,notnull,default:0" json:"attempts"`
+	ExpiresAt time.Time `bun:"expires_at,notnull" json:"expires_at"`
+	UsedAt    time.Time `bun:"used_at,nullzero" json:"used_at,omitempty"`
+	IPAddress string    `bun:"ip_address" json:"ip_address"`
+	UserAgent string    `bun:"user_agent" json:"user_agent"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PasswordHistory keeps the bcrypt hashes of a staff member's recent
+// passwords, newest included, so they cannot be reused.
+type PasswordHistory struct {
+	bun.BaseModel `bun:"table:password_history"`
+
+	ID           int64  `bun:"id,pk,autoincrement" json:"id"`
+	StaffID      int64  `bun:"staff_id,notnull" json:"staff_id"`
+	PasswordHash string `bun:"password_hash,notnull" json:"-"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PasswordReset is a single-use token emailed to a staff member who has
+// forgotten their password. Only its hash is stored.
+type PasswordReset struct {
+	bun.BaseModel `bun:"table:password_resets"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`
+	StaffID   int64     `bun:"staff_id,notnull" json:"staff_id"`
+	TokenHash string    `bun:"token_hash,notnull,unique" json:"-"`
+	ExpiresAt time.Time `bun:"expires_at,notnull" json:"expires_at"`
+	UsedAt    time.Time `bun:"used_at,nullzero" json:"used_at,omitempty"`
+	IPAddress string    `bun:"ip_address" json:"ip_address"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
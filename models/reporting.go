@@ -1,13 +1,14 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/uptrace/bun"
 )
 
-type Report struct {
-	bun.BaseModel     `bun:"table:reports"`
+type ReportBase struct {
 	ID                int64     `bun:"id,pk,autoincrement" json:"id"`
 	ReportName        string    `bun:"report_name,notnull" json:"report_name"`
 	Location          string    `bun:"location,notnull" json:"location"`
@@ -18,16 +19,131 @@ type Report struct {
 	ActionDescription string    `bun:"action_description" json:"action_description"`
 	PhotoUrls         []string  `bun:"photo_urls,array" json:"photo_urls"`
 	Department        string    `bun:"department,notnull" json:"department"`
+	IncidentID        int64     `bun:"incident_id,nullzero" json:"incident_id,omitempty"`
+	Geolocation
+}
+
+func (r *ReportBase) Validate() error {
+	if r.ReportName == "" {
+		return errors.New("report_name is required")
+	}
+	if r.Location == "" {
+		return errors.New("location is required")
+	}
+	switch SeverityEnum(r.Severity) {
+	case SeverityLow, SeverityModerate, SeverityHigh, SeverityCritical:
+	default:
+		return errors.New("severity must be one of Low, Moderate, High or Critical")
+	}
+	return r.ValidateCoordinates()
+}
+
+type ReportTypeEnum string
+
+const (
+	ReportTypeFire ReportTypeEnum = "fire"
+	ReportTypeEMS  ReportTypeEnum = "ems"
+	ReportTypeAVS  ReportTypeEnum = "avs"
+)
+
+// Report is a read-only row of the report_summaries view, which unions the
+// common columns of the fire, EMS and AVS report tables.
+type Report struct {
+	bun.BaseModel `bun:"table:report_summaries,alias:report"`
+	ReportBase
+	ReportType ReportTypeEnum `bun:"report_type" json:"report_type"`
+}
+
+// Key identifies the report across types, such as "fire-12"; IDs alone repeat
+// because each report table has its own sequence.
+func (r *Report) Key() string {
+	return fmt.Sprintf("%s-%d", r.ReportType, r.ID)
 }
 
 type FireReport struct {
-	Report
+	bun.BaseModel `bun:"table:fire_reports,alias:fire_report"`
+	ReportBase
+	Cause                  string  `bun:"cause,notnull" json:"cause"`
+	UnitsDeployed          int     `bun:"units_deployed,notnull" json:"units_deployed"`
+	PropertyDamageEstimate float64 `bun:"property_damage_estimate,notnull" json:"property_damage_estimate"`
+	Casualties             int     `bun:"casualties,notnull" json:"casualties"`
+}
+
+func (r *FireReport) Validate() error {
+	if err := r.ReportBase.Validate(); err != nil {
+		return err
+	}
+	if r.Cause == "" {
+		return errors.New("cause is required")
+	}
+	if r.UnitsDeployed < 1 {
+		return errors.New("units_deployed must be at least 1")
+	}
+	if r.PropertyDamageEstimate < 0 {
+		return errors.New("property_damage_estimate cannot be negative")
+	}
+	if r.Casualties < 0 {
+		return errors.New("casualties cannot be negative")
+	}
+	return nil
 }
 
+type TriageCategoryEnum string
+
+const (
+	TriageImmediate TriageCategoryEnum = "Immediate"
+	TriageDelayed   TriageCategoryEnum = "Delayed"
+	TriageMinor     TriageCategoryEnum = "Minor"
+	TriageExpectant TriageCategoryEnum = "Expectant"
+)
+
 type EMSReport struct {
-	Report
+	bun.BaseModel `bun:"table:ems_reports,alias:ems_report"`
+	ReportBase
+	Patients              int                `bun:"patients,notnull" json:"patients"`
+	TriageCategory        TriageCategoryEnum `bun:"triage_category,notnull" json:"triage_category"`
+	HospitalTransportedTo string             `bun:"hospital_transported_to" json:"hospital_transported_to"`
+}
+
+func (r *EMSReport) Validate() error {
+	if err := r.ReportBase.Validate(); err != nil {
+		return err
+	}
+	if r.Patients < 0 {
+		return errors.New("patients cannot be negative")
+	}
+	switch r.TriageCategory {
+	case TriageImmediate, TriageDelayed, TriageMinor, TriageExpectant:
+	default:
+		return errors.New("triage_category must be one of Immediate, Delayed, Minor or Expectant")
+	}
+	return nil
 }
 
 type AVSReport struct {
-	Report
+	bun.BaseModel `bun:"table:avs_reports,alias:avs_report"`
+	ReportBase
+	VehiclesInvolved int      `bun:"vehicles_involved,notnull" json:"vehicles_involved"`
+	PlateNumbers     []string `bun:"plate_numbers,array" json:"plate_numbers"`
+	Road             string   `bun:"road,notnull" json:"road"`
+	Injuries         int      `bun:"injuries,notnull" json:"injuries"`
+}
+
+func (r *AVSReport) Validate() error {
+	if err := r.ReportBase.Validate(); err != nil {
+		return err
+	}
+	if r.VehiclesInvolved < 1 {
+		return errors.New("vehicles_involved must be at least 1")
+	}
+	if len(r.PlateNumbers) > r.VehiclesInvolved {
+		return errors.New("plate_numbers cannot exceed vehicles_involved")
+	}
+	if r.Road == "" {
+		return errors.New("road is required")
+	}
+	if r.Injuries < 0 {
+		return errors.New("injuries cannot be negative")
+	}
+	return nil
 }
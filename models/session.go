@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Session is one signed-in device. Its refresh tokens form a single rotation
+// family; revoking the session revokes every token in it.
+type Session struct {
+	bun.BaseModel `bun:"table:sessions"`
+
+	ID            int64     `bun:"id,pk,autoincrement" json:"id"`
+	StaffID       int64     `bun:"staff_id,notnull" json:"staff_id"`
+	UserAgent     string    `bun:"user_agent" json:"user_agent"`
+	IPAddress     string    `bun:"ip_address" json:"ip_address"`
+	ExpiresAt     time.Time `bun:"expires_at,notnull" json:"expires_at"`
+	LastUsedAt    time.Time `bun:"last_used_at,nullzero,notnull,default:current_timestamp" json:"last_used_at"`
+	RevokedAt     time.Time `bun:"revoked_at,nullzero" json:"revoked_at,omitempty"`
+	RevokedReason string    `bun:"revoked_reason" json:"revoked_reason,omitempty"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`
+	SessionID int64     `bun:"session_id,notnull" json:"session_id"`
+	TokenHash string    `bun:"token_hash,notnull,unique" json:"-"`
+	ExpiresAt time.Time `bun:"expires_at,notnull" json:"expires_at"`
+	UsedAt    time.Time `bun:"used_at,nullzero" json:"used_at,omitempty"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
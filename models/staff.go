@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -35,6 +36,17 @@ const (
 	RoleStaff    RoleEnum = "Staff"
 )
 
+// SeesAllDepartments reports whether staff with role in department may see
+// every department's records. Other staff are limited to their own
+// department.
+func SeesAllDepartments(role RoleEnum, department DepartmentEnum) bool {
+	switch role {
+	case RoleAdmin, RoleSSA, RoleDirector:
+		return true
+	}
+	return department == DeptHomelandSecurity
+}
+
 type Staff struct {
 	bun.BaseModel `bun:"table:staff"`
 
@@ -53,7 +65,18 @@ type Staff struct {
 	StateOfOrigin      string         `bun:"state_of_origin,notnull" json:"state_of_origin"`
 	Role               RoleEnum       `bun:"role,notnull" json:"role"`
 	MustChangePassword bool           `bun:"must_change_password,notnull,default:true" json:"must_change_password"`
+	FailedLogins       int            `bun:"failed_logins,notnull,default:0" json:"-"`
+	LockedUntil        time.Time      `bun:"locked_until,nullzero" json:"locked_until,omitempty"`
+	MFAEnabled         bool           `bun:"mfa_enabled,notnull,default:false" json:"mfa_enabled"`
+	MFASecret          string         `bun:"mfa_secret,nullzero" json:"-"`
+	MFAEnrolledAt      time.Time      `bun:"mfa_enrolled_at,nullzero" json:"mfa_enrolled_at,omitempty"`
+	MFALastStep        int64          `bun:"mfa_last_step,notnull,default:0" json:"-"`
 
 	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
+
+// FullName joins the staff member's names, skipping an empty middle name.
+func (s *Staff) FullName() string {
+	return strings.Join(strings.Fields(s.FirstName+" "+s.MiddleName+" "+s.LastName), " ")
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type IDDocumentTypeEnum string
+
+const (
+	IDNationalID     IDDocumentTypeEnum = "National ID"
+	IDPassport       IDDocumentTypeEnum = "Passport"
+	IDDriversLicence IDDocumentTypeEnum = "Driver's Licence"
+	IDVotersCard     IDDocumentTypeEnum = "Voter's Card"
+	IDStaffID        IDDocumentTypeEnum = "Staff ID"
+	IDOther          IDDocumentTypeEnum = "Other"
+)
+
+type Visitor struct {
+	bun.BaseModel `bun:"table:visitors"`
+
+	ID               int64              `bun:"id,pk,autoincrement" json:"id"`
+	FullName         string             `bun:"full_name,notnull" json:"full_name"`
+	PhoneNumber      string             `bun:"phone_number,nullzero" json:"phone_number,omitempty"`
+	Organization     string             `bun:"organization,nullzero" json:"organization,omitempty"`
+	IDDocumentType   IDDocumentTypeEnum `bun:"id_document_type,notnull" json:"id_document_type"`
+	IDDocumentNumber string             `bun:"id_document_number,notnull" json:"id_document_number"`
+	PhotoDocumentID  int64              `bun:"photo_document_id,nullzero" json:"photo_document_id,omitempty"`
+
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (v *Visitor) Validate() error {
+	if v.FullName == "" {
+		return errors.New("full_name is required")
+	}
+	switch v.IDDocumentType {
+	case IDNationalID, IDPassport, IDDriversLicence, IDVotersCard, IDStaffID, IDOther:
+	default:
+		return errors.New("id_document_type must be one of National ID, Passport, Driver's Licence, Voter's Card, Staff ID or Other")
+	}
+	if v.IDDocumentNumber == "" {
+		return errors.New("id_document_number is required")
+	}
+	return nil
+}
+
+// Visit is one stay on site, from check-in to check-out. It is on site while
+// CheckedOutAt is zero.
+type Visit struct {
+	bun.BaseModel `bun:"table:visits"`
+
+	ID            int64     `bun:"id,pk,autoincrement" json:"id"`
+	VisitorID     int64     `bun:"visitor_id,notnull" json:"visitor_id"`
+	AppointmentID int64     `bun:"appointment_id,nullzero" json:"appointment_id,omitempty"`
+	HostStaffID   int64     `bun:"host_staff_id,nullzero" json:"host_staff_id,omitempty"`
+	Purpose       string    `bun:"purpose,notnull" json:"purpose"`
+	BadgeCode     string    `bun:"badge_code,notnull,unique" json:"badge_code"`
+	CheckedInAt   time.Time `bun:"checked_in_at,nullzero,notnull,default:current_timestamp" json:"checked_in_at"`
+	CheckedInBy   int64     `bun:"checked_in_by,notnull" json:"checked_in_by"`
+	CheckedOutAt  time.Time `bun:"checked_out_at,nullzero" json:"checked_out_at,omitempty"`
+	CheckedOutBy  int64     `bun:"checked_out_by,nullzero" json:"checked_out_by,omitempty"`
+
+	Visitor     *Visitor     `bun:"rel:belongs-to,join:visitor_id=id" json:"visitor,omitempty"`
+	Host        *Staff       `bun:"rel:belongs-to,join:host_staff_id=id" json:"host,omitempty"`
+	Appointment *Appointment `bun:"rel:belongs-to,join:appointment_id=id" json:"appointment,omitempty"`
+}
@@ -0,0 +1,303 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"homeland/calendar"
+	"homeland/jobs"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	changeUpdated   = "updated"
+	changeCancelled = "cancelled"
+
+	// Reminders falling due up to reminderLookback ago are still sent, so a
+	// sweep missed during a restart does not lose them.
+	reminderLookback = 15 * time.Minute
+
+	dateFormat  = "Monday 2 January 2006"
+	clockFormat = "15:04"
+)
+
+type reminder struct {
+	AppointmentID int64     `json:"appointment_id"`
+	TimeIn        time.Time `json:"time_in"`
+	LeadMinutes   int       `json:"lead_minutes"`
+}
+
+// scheduleReminders queues the reminders falling due before the next sweep.
+// Reminders are keyed on the appointment, lead time and start, so repeated
+// sweeps add nothing while a rescheduled appointment gets fresh reminders.
+func (n *Notifier) scheduleReminders(ctx context.Context, _ *models.Job) error {
+	if !utils.EmailConfigured(n.cfg) {
+		return nil
+	}
+	now := calendar.Now(n.office)
+	for _, lead := range n.leads {
+		var appointments []*models.Appointment
+		err := n.db.NewSelect().
+			Model(&appointments).
+			Column("id", "time_in").
+			Where("time_in > ?::timestamp", now).
+			Where("time_in >= ?::timestamp", now.Add(lead-reminderLookback)).
+			Where("time_in < ?::timestamp", now.Add(lead+2*reminderSweep)).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range appointments {
+			r := reminder{AppointmentID: a.ID, TimeIn: a.TimeIn, LeadMinutes: int(lead / time.Minute)}
+			key := fmt.Sprintf("reminder:%d:%d:%s", a.ID, r.LeadMinutes, a.TimeIn.Format("20060102T150405"))
+			runAt := calendar.Instant(a.TimeIn, n.office).Add(-lead)
+			if _, err := jobs.Enqueue(ctx, n.db, KindAppointmentRemind, r, jobs.At(runAt), jobs.DedupeKey(key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) remind(ctx context.Context, job *models.Job) error {
+	var r reminder
+	if err := json.Unmarshal(job.Payload, &r); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	var a models.Appointment
+	err := n.db.NewSelect().Model(&a).Where("id = ?", r.AppointmentID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Moved since the reminder was queued; the sweep queues one for the new
+	// time instead.
+	if !a.TimeIn.Equal(r.TimeIn) || !a.TimeIn.After(calendar.Now(n.office)) {
+		return nil
+	}
+
+	recipients, err := n.hosts(ctx, a.StaffID)
+	if err != nil {
+		return err
+	}
+	recipients = append(recipients, visitor(a.VisitorName, a.VisitorEmail)...)
+	subject := fmt.Sprintf("Reminder: appointment on %s at %s", a.TimeIn.Format(dateFormat), a.TimeIn.Format(clockFormat))
+	text := fmt.Sprintf(`This is a reminder of the following appointment:
+
+%s
+
+Purpose: %s
+`, describeAppointment(&a), a.Purpose)
+	return n.send(ctx, job, recipients, subject, text)
+}
+
+type appointmentChange struct {
+	Event  string              `json:"event"`
+	Before *models.Appointment `json:"before"`
+	After  *models.Appointment `json:"after,omitempty"`
+}
+
+// AppointmentUpdated queues a notice of the change from before to after when
+// it affects the host or visitor. Pass the transaction saving after so the
+// notice is only sent if the change commits.
+func AppointmentUpdated(ctx context.Context, db bun.IDB, before, after *models.Appointment) error {
+	if before.TimeIn.Equal(after.TimeIn) && before.TimeOut.Equal(after.TimeOut) &&
+		before.StaffID == after.StaffID && before.Purpose == after.Purpose &&
+		before.VisitorName == after.VisitorName && before.VisitorEmail == after.VisitorEmail {
+		return nil
+	}
+	change := appointmentChange{Event: changeUpdated, Before: snapshot(before), After: snapshot(after)}
+	_, err := jobs.Enqueue(ctx, db, KindAppointmentChanged, change)
+	return err
+}
+
+// AppointmentCancelled queues a notice that a has been cancelled.
+func AppointmentCancelled(ctx context.Context, db bun.IDB, a *models.Appointment) error {
+	change := appointmentChange{Event: changeCancelled, Before: snapshot(a)}
+	_, err := jobs.Enqueue(ctx, db, KindAppointmentChanged, change)
+	return err
+}
+
+// snapshot copies a without the response-only fields.
+func snapshot(a *models.Appointment) *models.Appointment {
+	c := *a
+	c.Staff = nil
+	c.Conflicts = nil
+	c.Warnings = nil
+	return &c
+}
+
+func (n *Notifier) appointmentChanged(ctx context.Context, job *models.Job) error {
+	var change appointmentChange
+	if err := json.Unmarshal(job.Payload, &change); err != nil {
+		return jobs.Permanent(err)
+	}
+	if change.Before == nil {
+		return jobs.Permanent(errors.New("invalid payload: no appointment"))
+	}
+	before, after := change.Before, change.After
+	now := calendar.Now(n.office)
+
+	var subject, text string
+	var recipients []recipient
+	var err error
+	switch change.Event {
+	case changeUpdated:
+		if after == nil {
+			return jobs.Permanent(errors.New("invalid payload: no appointment after the update"))
+		}
+		if !before.TimeIn.After(now) && !after.TimeIn.After(now) {
+			return nil
+		}
+		if recipients, err = n.hosts(ctx, before.StaffID, changedID(before.StaffID, after.StaffID)); err != nil {
+			return err
+		}
+		recipients = append(recipients, visitor(after.VisitorName, after.VisitorEmail)...)
+		subject = fmt.Sprintf("Appointment changed: %s", after.TimeIn.Format(dateFormat))
+		text = fmt.Sprintf(`An appointment has been changed.
+
+Was: %s
+Now: %s
+
+Purpose: %s
+`, describeAppointment(before), describeAppointment(after), after.Purpose)
+	case changeCancelled:
+		if !before.TimeIn.After(now) {
+			return nil
+		}
+		if recipients, err = n.hosts(ctx, before.StaffID); err != nil {
+			return err
+		}
+		recipients = append(recipients, visitor(before.VisitorName, before.VisitorEmail)...)
+		subject = fmt.Sprintf("Appointment cancelled: %s", before.TimeIn.Format(dateFormat))
+		text = fmt.Sprintf(`The following appointment has been cancelled:
+
+%s
+
+Purpose: %s
+`, describeAppointment(before), before.Purpose)
+	default:
+		return jobs.Permanent(fmt.Errorf("unknown change %q", change.Event))
+	}
+	return n.send(ctx, job, recipients, subject, text)
+}
+
+type seriesChange struct {
+	Event  string                    `json:"event"`
+	Before *models.AppointmentSeries `json:"before"`
+	After  *models.AppointmentSeries `json:"after,omitempty"`
+}
+
+// SeriesUpdated queues a notice of the change to a recurring appointment
+// when it affects the host or visitor.
+func SeriesUpdated(ctx context.Context, db bun.IDB, before, after *models.AppointmentSeries) error {
+	if before.StartsAt.Equal(after.StartsAt) && before.DurationMinutes == after.DurationMinutes &&
+		before.RRule == after.RRule && before.StaffID == after.StaffID && before.Purpose == after.Purpose &&
+		before.VisitorName == after.VisitorName && before.VisitorEmail == after.VisitorEmail {
+		return nil
+	}
+	_, err := jobs.Enqueue(ctx, db, KindSeriesChanged, seriesChange{Event: changeUpdated, Before: before, After: after})
+	return err
+}
+
+// SeriesCancelled queues a notice that the recurring appointment s has been
+// cancelled.
+func SeriesCancelled(ctx context.Context, db bun.IDB, s *models.AppointmentSeries) error {
+	_, err := jobs.Enqueue(ctx, db, KindSeriesChanged, seriesChange{Event: changeCancelled, Before: s})
+	return err
+}
+
+func (n *Notifier) seriesChanged(ctx context.Context, job *models.Job) error {
+	var change seriesChange
+	if err := json.Unmarshal(job.Payload, &change); err != nil {
+		return jobs.Permanent(err)
+	}
+	if change.Before == nil {
+		return jobs.Permanent(errors.New("invalid payload: no series"))
+	}
+	before, after := change.Before, change.After
+
+	var subject, text string
+	var recipients []recipient
+	var err error
+	switch change.Event {
+	case changeUpdated:
+		if after == nil {
+			return jobs.Permanent(errors.New("invalid payload: no series after the update"))
+		}
+		if !n.upcoming(before) && !n.upcoming(after) {
+			return nil
+		}
+		if recipients, err = n.hosts(ctx, before.StaffID, changedID(before.StaffID, after.StaffID)); err != nil {
+			return err
+		}
+		recipients = append(recipients, visitor(after.VisitorName, after.VisitorEmail)...)
+		subject = "Recurring appointment changed"
+		text = fmt.Sprintf(`A recurring appointment has been changed.
+
+Was: %s
+Now: %s
+
+Purpose: %s
+`, describeSeries(before), describeSeries(after), after.Purpose)
+	case changeCancelled:
+		if !n.upcoming(before) {
+			return nil
+		}
+		if recipients, err = n.hosts(ctx, before.StaffID); err != nil {
+			return err
+		}
+		recipients = append(recipients, visitor(before.VisitorName, before.VisitorEmail)...)
+		subject = "Recurring appointment cancelled"
+		text = fmt.Sprintf(`The following recurring appointment has been cancelled, including all of its future occurrences:
+
+%s
+
+Purpose: %s
+`, describeSeries(before), before.Purpose)
+	default:
+		return jobs.Permanent(fmt.Errorf("unknown change %q", change.Event))
+	}
+	return n.send(ctx, job, recipients, subject, text)
+}
+
+// upcoming reports whether s has an occurrence still to come.
+func (n *Notifier) upcoming(s *models.AppointmentSeries) bool {
+	rule, err := calendar.ParseRule(s.RRule, time.UTC)
+	if err != nil {
+		log.Printf("Series %d has an invalid rule: %v", s.ID, err)
+		return false
+	}
+	now := calendar.Now(n.office)
+	return len(rule.Expand(s.StartsAt, now, now.AddDate(1, 0, 0), 1)) > 0
+}
+
+// changedID returns after if it differs from before, and zero otherwise.
+func changedID(before, after int64) int64 {
+	if after == before {
+		return 0
+	}
+	return after
+}
+
+func describeAppointment(a *models.Appointment) string {
+	return fmt.Sprintf("%s, %s to %s: %s seeing %s",
+		a.TimeIn.Format(dateFormat), a.TimeIn.Format(clockFormat), a.TimeOut.Format(clockFormat), a.VisitorName, a.WhoToSee)
+}
+
+func describeSeries(s *models.AppointmentSeries) string {
+	return fmt.Sprintf("%s seeing %s for %d minutes, from %s at %s, repeating %s",
+		s.VisitorName, s.WhoToSee, s.DurationMinutes, s.StartsAt.Format(dateFormat), s.StartsAt.Format(clockFormat), s.RRule)
+}
@@ -0,0 +1,140 @@
+// Package notify emails appointment reminders and change notices. The work
+// runs as background jobs, so a notice queued in the same transaction as a
+// change is sent only if the change commits, and failed deliveries are
+// retried.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"time"
+
+	"homeland/calendar"
+	"homeland/config"
+	"homeland/jobs"
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	KindSendEmail          = "email.send"
+	KindAppointmentRemind  = "appointment.reminder"
+	KindAppointmentChanged = "appointment.changed"
+	KindSeriesChanged      = "appointment_series.changed"
+	KindScheduleReminders  = "appointment.schedule_reminders"
+
+	reminderSweep = 5 * time.Minute
+)
+
+type Notifier struct {
+	db     *bun.DB
+	cfg    *config.Config
+	office *time.Location
+	leads  []time.Duration
+}
+
+// Register adds the notification jobs to s.
+func Register(s *jobs.Scheduler, db *bun.DB, cfg *config.Config) {
+	n := &Notifier{
+		db:     db,
+		cfg:    cfg,
+		office: calendar.Office(cfg.OfficeTimezone),
+		leads:  cfg.ReminderLeadTimes,
+	}
+	if !utils.EmailConfigured(cfg) {
+		log.Println("SMTP_HOST is not set; appointment reminders and notices will not be emailed")
+	}
+	s.Register(KindSendEmail, n.sendEmail)
+	s.Register(KindAppointmentRemind, n.remind)
+	s.Register(KindAppointmentChanged, n.appointmentChanged)
+	s.Register(KindSeriesChanged, n.seriesChanged)
+	s.Every(KindScheduleReminders, reminderSweep, n.scheduleReminders)
+}
+
+type email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+func (n *Notifier) sendEmail(ctx context.Context, job *models.Job) error {
+	var e email
+	if err := json.Unmarshal(job.Payload, &e); err != nil {
+		return jobs.Permanent(err)
+	}
+	if _, err := mail.ParseAddress(e.To); err != nil {
+		return jobs.Permanent(err)
+	}
+	err := utils.SendEmail(ctx, n.cfg, e.To, e.Subject, e.Body)
+	if errors.Is(err, utils.ErrEmailNotConfigured) {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// recipient is someone a notice is addressed to; name is used in the
+// greeting.
+type recipient struct {
+	name  string
+	email string
+}
+
+// hosts returns the staff members with the given IDs, skipping zero IDs.
+func (n *Notifier) hosts(ctx context.Context, ids ...int64) ([]recipient, error) {
+	wanted := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 {
+			wanted = append(wanted, id)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	var staff []models.Staff
+	if err := n.db.NewSelect().Model(&staff).Where("id IN (?)", bun.In(wanted)).Scan(ctx); err != nil {
+		return nil, err
+	}
+	recipients := make([]recipient, 0, len(staff))
+	for _, s := range staff {
+		recipients = append(recipients, recipient{name: s.FirstName, email: s.Email})
+	}
+	return recipients, nil
+}
+
+func visitor(name, address string) []recipient {
+	if address == "" {
+		return nil
+	}
+	return []recipient{{name: name, email: address}}
+}
+
+// send queues the same message to each recipient as its own job, so a failed
+// delivery is retried without resending the others. The jobs are keyed on
+// the job composing them, which may itself be retried. Nothing is queued
+// while email is not configured.
+func (n *Notifier) send(ctx context.Context, parent *models.Job, recipients []recipient, subject, text string) error {
+	if !utils.EmailConfigured(n.cfg) {
+		return nil
+	}
+	return n.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, r := range recipients {
+			e := email{
+				To:      r.email,
+				Subject: subject,
+				Body:    fmt.Sprintf("Hello %s,\n\n%s", r.name, text),
+			}
+			key := fmt.Sprintf("email:%d:%s", parent.ID, r.email)
+			if _, err := jobs.Enqueue(ctx, tx, KindSendEmail, e, jobs.DedupeKey(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
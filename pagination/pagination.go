@@ -0,0 +1,231 @@
+// Package pagination pages list queries with opaque cursors.
+//
+// Lists in their natural newest-first order are paged by keyset on
+// (timestamp, id), so rows inserted while a client pages through do not cause
+// duplicates or skipped rows. Other orders fall back to an offset carried in
+// the cursor. Either way the client only follows next_cursor and prev_cursor,
+// or the next and prev links.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("limit must be a positive integer")
+	ErrInvalidOffset = errors.New("offset must be a non-negative integer")
+)
+
+// Key is a row's position in keyset order. Tie breaks ties on ID in lists
+// whose ids repeat, such as one drawing on several tables.
+type Key struct {
+	Time time.Time `json:"t"`
+	ID   int64     `json:"i"`
+	Tie  string    `json:"y,omitempty"`
+}
+
+// Cursor points at the row after which, or before which when Backward, a page
+// starts.
+type Cursor struct {
+	Key      *Key `json:"k,omitempty"`
+	Offset   int  `json:"o,omitempty"`
+	Backward bool `json:"b,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(value string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.Offset < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Page is the paging part of a list request.
+type Page struct {
+	Limit  int
+	Cursor *Cursor
+	// Offset is set by the legacy offset parameter.
+	Offset    int
+	UseOffset bool
+	WithTotal bool
+
+	keyset bool
+}
+
+// Parse reads limit, cursor, offset and include_total. limit defaults to
+// defaultLimit and is capped at maxLimit. A request using the older offset
+// parameter is paged by offset and always gets a total.
+func Parse(values url.Values, defaultLimit, maxLimit int) (*Page, error) {
+	p := &Page{Limit: defaultLimit}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, ErrInvalidLimit
+		}
+		p.Limit = min(limit, maxLimit)
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := DecodeCursor(value)
+		if err != nil {
+			return nil, err
+		}
+		p.Cursor = &cursor
+	} else if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, ErrInvalidOffset
+		}
+		p.Offset = offset
+		p.UseOffset = true
+	}
+
+	p.WithTotal = p.UseOffset || values.Get("include_total") == "true"
+	return p, nil
+}
+
+// CanKeyset reports whether the page may be fetched by keyset: it was not
+// requested by offset and its cursor, if any, carries a key.
+func (p *Page) CanKeyset() bool {
+	return !p.UseOffset && (p.Cursor == nil || p.Cursor.Key != nil)
+}
+
+func (p *Page) offset() int {
+	if p.Cursor != nil {
+		return p.Cursor.Offset
+	}
+	return p.Offset
+}
+
+// Keyset orders sq newest first by column and id, then by tie when it is
+// not empty, and restricts it to the rows past the cursor. It fetches one
+// extra row to detect a further page.
+func (p *Page) Keyset(sq *bun.SelectQuery, column, tie string) *bun.SelectQuery {
+	p.keyset = true
+	fields := []string{"?TableAlias.?", "?TableAlias.id"}
+	idents := []interface{}{bun.Ident(column)}
+	if tie != "" {
+		fields = append(fields, "?TableAlias.?")
+		idents = append(idents, bun.Ident(tie))
+	}
+	orderBy := func(dir string) string {
+		return strings.Join(fields, " "+dir+", ") + " " + dir
+	}
+
+	backward := p.Cursor != nil && p.Cursor.Backward
+	if p.Cursor != nil {
+		key := []interface{}{p.Cursor.Key.Time, p.Cursor.Key.ID}
+		if tie != "" {
+			key = append(key, p.Cursor.Key.Tie)
+		}
+		op := " < "
+		if backward {
+			op = " > "
+		}
+		args := append(append([]interface{}{}, idents...), key...)
+		sq = sq.Where("("+strings.Join(fields, ", ")+")"+op+"(?"+strings.Repeat(", ?", len(key)-1)+")", args...)
+	}
+
+	if !backward {
+		return sq.OrderExpr(orderBy("DESC"), idents...).Limit(p.Limit + 1)
+	}
+	// Walking backwards reads the rows just above the cursor in ascending
+	// order; Finish puts them back newest first.
+	return sq.OrderExpr(orderBy("ASC"), idents...).Limit(p.Limit + 1)
+}
+
+// Window pages an already ordered sq by offset, fetching one extra row to
+// detect a further page.
+func (p *Page) Window(sq *bun.SelectQuery) *bun.SelectQuery {
+	return sq.Limit(p.Limit + 1).Offset(p.offset())
+}
+
+// Info is the pagination object list responses carry.
+type Info struct {
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// Finish drops the look-ahead row, restores newest-first order after a
+// backward keyset page and describes the neighbouring pages. total is
+// reported only when the page asked for it.
+func Finish[T any](p *Page, r *http.Request, items []T, total int, key func(T) Key) ([]T, Info) {
+	more := len(items) > p.Limit
+	if more {
+		items = items[:p.Limit]
+	}
+
+	info := Info{Limit: p.Limit}
+	if p.WithTotal {
+		info.Total = &total
+	}
+
+	var next, prev *Cursor
+	if p.keyset {
+		backward := p.Cursor != nil && p.Cursor.Backward
+		if backward {
+			slices.Reverse(items)
+		}
+		if len(items) > 0 {
+			first, last := key(items[0]), key(items[len(items)-1])
+			if more || backward {
+				next = &Cursor{Key: &last}
+			}
+			if p.Cursor != nil && (more || !backward) {
+				prev = &Cursor{Key: &first, Backward: true}
+			}
+		}
+	} else {
+		offset := p.offset()
+		if p.UseOffset {
+			info.Offset = &offset
+		}
+		if more {
+			next = &Cursor{Offset: offset + p.Limit}
+		}
+		if offset > 0 {
+			prev = &Cursor{Offset: max(offset-p.Limit, 0)}
+		}
+	}
+
+	if next != nil {
+		info.NextCursor = next.Encode()
+		info.Next = link(r, info.NextCursor)
+	}
+	if prev != nil {
+		info.PrevCursor = prev.Encode()
+		info.Prev = link(r, info.PrevCursor)
+	}
+	return items, info
+}
+
+// link is the request URL with its cursor replaced.
+func link(r *http.Request, cursor string) string {
+	values := r.URL.Query()
+	values.Del("offset")
+	values.Set("cursor", cursor)
+	return r.URL.Path + "?" + values.Encode()
+}
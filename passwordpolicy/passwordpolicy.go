@@ -0,0 +1,249 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"homeland/config"
+	"homeland/models"
+
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = loadCommonPasswords(commonPasswordList)
+
+func loadCommonPasswords(list string) map[string]bool {
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}
+
+// Violation codes reported in Violation.Code.
+const (
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeMissingUpper  = "missing_uppercase"
+	CodeMissingLower  = "missing_lowercase"
+	CodeMissingDigit  = "missing_digit"
+	CodeMissingSymbol = "missing_symbol"
+	CodePersonalInfo  = "contains_personal_info"
+	CodeCommon        = "common_password"
+	CodeReused        = "reused_password"
+)
+
+// bcrypt ignores everything after 72 bytes, so longer passwords would be
+// silently truncated.
+const maxLength = 72
+
+// minPersonalTokenLength keeps short names such as "Al" from rejecting most
+// passwords.
+const minPersonalTokenLength = 3
+
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Violations is returned by Check when a password breaks one or more rules.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Message
+	}
+	return "password rejected: " + strings.Join(messages, "; ")
+}
+
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// HistorySize is how many previous passwords, the current one included,
+	// may not be reused. Zero disables the check.
+	HistorySize int
+}
+
+func New(cfg *config.Config) *Policy {
+	return &Policy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		HistorySize:   cfg.PasswordHistorySize,
+	}
+}
+
+// Validate applies the rules that need no database access: length, character
+// classes, personal information and the common password list. staff may be
+// nil when there is no account yet.
+func (p *Policy) Validate(field, password string, staff *models.Staff) Violations {
+	var violations Violations
+	add := func(code, message string) {
+		violations = append(violations, Violation{Field: field, Code: code, Message: message})
+	}
+
+	if length := len([]rune(password)); length < p.MinLength {
+		add(CodeTooShort, fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if len(password) > maxLength {
+		add(CodeTooLong, fmt.Sprintf("Password must be at most %d bytes long", maxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(CodeMissingUpper, "Password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add(CodeMissingLower, "Password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(CodeMissingDigit, "Password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(CodeMissingSymbol, "Password must contain a symbol")
+	}
+
+	if staff != nil && containsPersonalInfo(password, staff) {
+		add(CodePersonalInfo, "Password must not contain your name, email or agent ID")
+	}
+
+	if isCommon(password) {
+		add(CodeCommon, "Password is too common")
+	}
+
+	return violations
+}
+
+// Check runs Validate and, for an existing account, rejects any of its last
+// HistorySize passwords. It returns Violations when the password is rejected.
+func (p *Policy) Check(ctx context.Context, db bun.IDB, field, password string, staff *models.Staff) error {
+	violations := p.Validate(field, password, staff)
+
+	if staff != nil && staff.ID != 0 && p.HistorySize > 0 {
+		reused, err := p.reused(ctx, db, staff, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, Violation{
+				Field:   field,
+				Code:    CodeReused,
+				Message: fmt.Sprintf("Password must differ from your last %d passwords", p.HistorySize),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+func (p *Policy) reused(ctx context.Context, db bun.IDB, staff *models.Staff, password string) (bool, error) {
+	var hashes []string
+	err := db.NewSelect().
+		Model((*models.PasswordHistory)(nil)).
+		Column("password_hash").
+		Where("staff_id = ?", staff.ID).
+		Order("created_at DESC", "id DESC").
+		Limit(p.HistorySize).
+		Scan(ctx, &hashes)
+	if err != nil {
+		return false, err
+	}
+	if staff.Password != "" {
+		hashes = append(hashes, staff.Password)
+	}
+
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Record adds a newly set password hash to the staff member's history and
+// drops entries older than the policy retains.
+func (p *Policy) Record(ctx context.Context, db bun.IDB, staffID int64, hash string) error {
+	entry := &models.PasswordHistory{StaffID: staffID, PasswordHash: hash}
+	if _, err := db.NewInsert().Model(entry).Exec(ctx); err != nil {
+		return err
+	}
+
+	keep := p.HistorySize
+	if keep < 1 {
+		keep = 1
+	}
+	_, err := db.NewDelete().
+		Model((*models.PasswordHistory)(nil)).
+		Where("staff_id = ?", staffID).
+		Where("id NOT IN (?)", db.NewSelect().
+			Model((*models.PasswordHistory)(nil)).
+			Column("id").
+			Where("staff_id = ?", staffID).
+			Order("created_at DESC", "id DESC").
+			Limit(keep)).
+		Exec(ctx)
+	return err
+}
+
+func containsPersonalInfo(password string, staff *models.Staff) bool {
+	lowered := strings.ToLower(password)
+
+	local, _, _ := strings.Cut(staff.Email, "@")
+	candidates := []string{staff.FirstName, staff.MiddleName, staff.LastName, local, staff.AgentID}
+	for _, candidate := range candidates {
+		for _, token := range strings.FieldsFunc(strings.ToLower(candidate), func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+		}) {
+			if len(token) >= minPersonalTokenLength && strings.Contains(lowered, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isCommon(password string) bool {
+	lowered := strings.ToLower(password)
+	if commonPasswords[lowered] {
+		return true
+	}
+	// "Password123!" is as guessable as "password".
+	stripped := strings.TrimRightFunc(lowered, func(c rune) bool {
+		return unicode.IsDigit(c) || unicode.IsPunct(c) || unicode.IsSymbol(c)
+	})
+	return stripped != lowered && commonPasswords[stripped]
+}
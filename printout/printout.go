@@ -0,0 +1,290 @@
+// Package printout renders records as paginated A4 PDFs carrying the agency
+// header, for filing with the state authorities.
+package printout
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"homeland/config"
+	"homeland/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	margin     = 15.0
+	lineHeight = 5.5
+	labelWidth = 55.0
+)
+
+// Header is the agency letterhead printed on every page.
+type Header struct {
+	Agency  string
+	Address string
+	Logo    string
+}
+
+func HeaderFromConfig(cfg *config.Config) Header {
+	return Header{Agency: cfg.AgencyName, Address: cfg.AgencyAddress, Logo: cfg.AgencyLogo}
+}
+
+type Field struct {
+	Label string
+	Value string
+}
+
+// Document is a printout being built section by section.
+type Document struct {
+	pdf *gofpdf.Fpdf
+	// tr converts UTF-8 text to the encoding of the core fonts.
+	tr func(string) string
+}
+
+// New starts a document titled title. reference identifies the record, and
+// printedBy is recorded in the footer together with the print time.
+func New(header Header, title, reference, printedBy string) *Document {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin+5)
+	pdf.SetTitle(title+" "+reference, true)
+	pdf.SetCreator(header.Agency, true)
+	pdf.AliasNbPages("")
+
+	d := &Document{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+	printedAt := time.Now().Format("2006-01-02 15:04 MST")
+
+	pdf.SetHeaderFunc(func() {
+		top := pdf.GetY()
+		textX := margin
+		if header.Logo != "" {
+			if _, err := os.Stat(header.Logo); err == nil {
+				pdf.ImageOptions(header.Logo, margin, top, 0, 18, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+				textX = margin + 22
+			}
+		}
+
+		pdf.SetXY(textX, top)
+		pdf.SetFont("Helvetica", "B", 15)
+		pdf.CellFormat(0, 7, d.tr(header.Agency), "", 2, "L", false, 0, "")
+		if header.Address != "" {
+			pdf.SetFont("Helvetica", "", 9)
+			pdf.CellFormat(0, 5, d.tr(header.Address), "", 2, "L", false, 0, "")
+		}
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 7, d.tr(title), "", 2, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 5, d.tr("Reference: "+reference), "", 1, "L", false, 0, "")
+
+		y := max(pdf.GetY(), top+18) + 2
+		pageWidth, _ := pdf.GetPageSize()
+		pdf.SetLineWidth(0.5)
+		pdf.Line(margin, y, pageWidth-margin, y)
+		pdf.SetLineWidth(0.2)
+		pdf.SetXY(margin, y+4)
+	})
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-margin)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 5, d.tr(fmt.Sprintf("Printed by %s on %s", printedBy, printedAt)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+	return d
+}
+
+func (d *Document) contentWidth() float64 {
+	pageWidth, _ := d.pdf.GetPageSize()
+	return pageWidth - 2*margin
+}
+
+// ensureSpace starts a new page unless height fits above the bottom margin.
+func (d *Document) ensureSpace(height float64) {
+	_, pageHeight := d.pdf.GetPageSize()
+	if d.pdf.GetY()+height > pageHeight-margin-5 {
+		d.pdf.AddPage()
+	}
+}
+
+// Section starts a titled section.
+func (d *Document) Section(title string) {
+	d.ensureSpace(20)
+	d.pdf.Ln(3)
+	d.pdf.SetFont("Helvetica", "B", 11)
+	d.pdf.SetFillColor(230, 230, 230)
+	d.pdf.CellFormat(0, 7, d.tr(title), "", 1, "L", true, 0, "")
+	d.pdf.Ln(1)
+}
+
+// Fields prints labelled values, wrapping long values.
+func (d *Document) Fields(fields ...Field) {
+	valueWidth := d.contentWidth() - labelWidth
+	for _, field := range fields {
+		value := field.Value
+		if value == "" {
+			value = "-"
+		}
+		d.pdf.SetFont("Helvetica", "", 10)
+		lines := d.pdf.SplitLines([]byte(d.tr(value)), valueWidth)
+		d.ensureSpace(float64(len(lines)) * lineHeight)
+
+		y := d.pdf.GetY()
+		d.pdf.SetFont("Helvetica", "B", 10)
+		d.pdf.CellFormat(labelWidth, lineHeight, d.tr(field.Label), "", 0, "L", false, 0, "")
+		d.pdf.SetFont("Helvetica", "", 10)
+		d.pdf.SetXY(margin+labelWidth, y)
+		d.pdf.MultiCell(valueWidth, lineHeight, d.tr(value), "", "L", false)
+	}
+}
+
+// Text prints a wrapped paragraph.
+func (d *Document) Text(text string) {
+	if text == "" {
+		text = "-"
+	}
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.MultiCell(0, lineHeight, d.tr(text), "", "L", false)
+}
+
+// Banner prints text large and centred, e.g. a badge code to be read at a
+// distance.
+func (d *Document) Banner(text string) {
+	d.ensureSpace(30)
+	d.pdf.Ln(4)
+	d.pdf.SetFont("Courier", "B", 36)
+	d.pdf.CellFormat(0, 18, d.tr(text), "1", 1, "C", false, 0, "")
+	d.pdf.Ln(4)
+}
+
+// Table prints rows under a header row. widths are fractions of the content
+// width and must sum to 1.
+func (d *Document) Table(headers []string, widths []float64, rows [][]string) {
+	if len(rows) == 0 {
+		d.Text("None recorded.")
+		return
+	}
+
+	total := d.contentWidth()
+	cellWidths := make([]float64, len(widths))
+	for i, w := range widths {
+		cellWidths[i] = w * total
+	}
+
+	printHeader := func() {
+		d.pdf.SetFont("Helvetica", "B", 9)
+		d.pdf.SetFillColor(240, 240, 240)
+		for i, header := range headers {
+			d.pdf.CellFormat(cellWidths[i], 6, d.tr(header), "1", 0, "L", true, 0, "")
+		}
+		d.pdf.Ln(-1)
+	}
+
+	d.ensureSpace(12)
+	printHeader()
+	d.pdf.SetFont("Helvetica", "", 9)
+	for _, row := range rows {
+		height := 0.0
+		for i, cell := range row {
+			lines := d.pdf.SplitLines([]byte(d.tr(cell)), cellWidths[i]-2)
+			height = max(height, float64(len(lines))*5)
+		}
+		height = max(height, 5)
+
+		_, pageHeight := d.pdf.GetPageSize()
+		if d.pdf.GetY()+height > pageHeight-margin-5 {
+			d.pdf.AddPage()
+			printHeader()
+			d.pdf.SetFont("Helvetica", "", 9)
+		}
+
+		x, y := d.pdf.GetXY()
+		for i, cell := range row {
+			d.pdf.Rect(x, y, cellWidths[i], height, "D")
+			d.pdf.SetXY(x, y)
+			d.pdf.MultiCell(cellWidths[i], 5, d.tr(cell), "", "L", false)
+			x += cellWidths[i]
+		}
+		d.pdf.SetXY(margin, y+height)
+	}
+}
+
+// Signatures prints a signature, name and date block for each role.
+func (d *Document) Signatures(roles ...string) {
+	d.ensureSpace(50)
+	d.pdf.Ln(8)
+
+	width := d.contentWidth() / float64(len(roles))
+	y := d.pdf.GetY()
+	for i, role := range roles {
+		x := margin + float64(i)*width
+		d.pdf.SetFont("Helvetica", "B", 9)
+		d.pdf.SetXY(x, y)
+		d.pdf.CellFormat(width-6, 5, d.tr(role), "", 0, "L", false, 0, "")
+
+		d.pdf.SetFont("Helvetica", "", 8)
+		for j, label := range []string{"Signature", "Name", "Date"} {
+			lineY := y + 14 + float64(j)*9
+			d.pdf.Line(x, lineY, x+width-6, lineY)
+			d.pdf.SetXY(x, lineY)
+			d.pdf.CellFormat(width-6, 4, label, "", 0, "L", false, 0, "")
+		}
+	}
+	d.pdf.SetXY(margin, y+40)
+}
+
+// Write renders the document.
+func (d *Document) Write(w io.Writer) error {
+	return d.pdf.Output(w)
+}
+
+// Serve renders the document and sends it inline as filename. The PDF is
+// rendered in full first so a failure can still be answered with an error.
+func (d *Document) Serve(w http.ResponseWriter, filename string) error {
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// StaffName formats a staff member as "First Middle Last (agent ID)".
+func StaffName(s *models.Staff) string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", s.FullName(), s.AgentID)
+}
+
+// Time formats a timestamp for printing, or "-" when unset.
+func Time(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// Coordinates formats an optional position.
+func Coordinates(lat, lng *float64) string {
+	if lat == nil || lng == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f, %.6f", *lat, *lng)
+}
+
+// Join formats a list for a single field.
+func Join(values []string) string {
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,199 @@
+package rbac
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+//go:embed default_policy.json
+var defaultPolicy []byte
+
+// Catalog lists every permission the API enforces, as resource:action. It is
+// used to expand wildcard grants into the concrete list shown to clients.
+var Catalog = []string{
+	"analytics:read",
+	"appointments:create",
+	"appointments:delete",
+	"appointments:export",
+	"appointments:import",
+	"appointments:read",
+	"appointments:set_availability",
+	"appointments:update",
+	"audit:read",
+	"callers:flag",
+	"callers:read",
+	"dispatch:acknowledge",
+	"dispatch:read",
+	"documents:read",
+	"documents:upload",
+	"incidents:create",
+	"incidents:delete",
+	"incidents:dispatch",
+	"incidents:export",
+	"incidents:read",
+	"incidents:transition",
+	"incidents:update",
+	"jobs:read",
+	"jobs:retry",
+	"reports:export",
+	"reports:read",
+	"reports.avs:create",
+	"reports.avs:read",
+	"reports.ems:create",
+	"reports.ems:read",
+	"reports.fire:create",
+	"reports.fire:read",
+	"sessions:read",
+	"sessions:revoke",
+	"staff:delete",
+	"staff:export",
+	"staff:onboard",
+	"staff:read",
+	"staff:reset_mfa",
+	"staff:unlock",
+	"staff:update",
+	"stream:read",
+	"visitors:check_in",
+	"visitors:check_out",
+	"visitors:create",
+	"visitors:read",
+	"visitors:update",
+}
+
+type Subject struct {
+	Role       string `json:"role"`
+	Department string `json:"department"`
+	Position   string `json:"position"`
+}
+
+// Rule grants Permissions to every subject matching all of its non-empty
+// attribute lists. Permissions may use "*" for the resource, the action or
+// both.
+type Rule struct {
+	Description string   `json:"description,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Departments []string `json:"departments,omitempty"`
+	Positions   []string `json:"positions,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// Limit confines Permission to subjects in Departments, whatever the rules
+// grant. It keeps actions that belong to one department, such as filing that
+// department's reports, with that department even under wildcard grants.
+type Limit struct {
+	Description string   `json:"description,omitempty"`
+	Permission  string   `json:"permission"`
+	Departments []string `json:"departments"`
+}
+
+type Policy struct {
+	Rules  []Rule  `json:"rules"`
+	Limits []Limit `json:"limits,omitempty"`
+}
+
+type Engine struct {
+	policy Policy
+}
+
+// Load reads a JSON policy from path, or the bundled default policy when path
+// is empty.
+func Load(path string) (*Engine, error) {
+	data := defaultPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("read policy %s: %w", path, err)
+		}
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	return New(policy)
+}
+
+func New(policy Policy) (*Engine, error) {
+	for i, rule := range policy.Rules {
+		if len(rule.Permissions) == 0 {
+			return nil, fmt.Errorf("policy rule %d grants no permissions", i)
+		}
+		for _, permission := range rule.Permissions {
+			if _, _, ok := strings.Cut(permission, ":"); !ok && permission != "*" {
+				return nil, fmt.Errorf("policy rule %d: permission %q must be resource:action", i, permission)
+			}
+		}
+	}
+	for i, limit := range policy.Limits {
+		if _, _, ok := strings.Cut(limit.Permission, ":"); !ok {
+			return nil, fmt.Errorf("policy limit %d: permission %q must be resource:action", i, limit.Permission)
+		}
+		if len(limit.Departments) == 0 {
+			return nil, fmt.Errorf("policy limit %d names no departments", i)
+		}
+	}
+	return &Engine{policy: policy}, nil
+}
+
+func matchesAttribute(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if candidate == "*" || candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) appliesTo(subject Subject) bool {
+	return matchesAttribute(r.Roles, subject.Role) &&
+		matchesAttribute(r.Departments, subject.Department) &&
+		matchesAttribute(r.Positions, subject.Position)
+}
+
+func grants(permission, resource, action string) bool {
+	if permission == "*" {
+		return true
+	}
+	grantedResource, grantedAction, _ := strings.Cut(permission, ":")
+	return (grantedResource == "*" || grantedResource == resource) &&
+		(grantedAction == "*" || grantedAction == action)
+}
+
+func (e *Engine) Allowed(subject Subject, resource, action string) bool {
+	for _, limit := range e.policy.Limits {
+		if grants(limit.Permission, resource, action) && !matchesAttribute(limit.Departments, subject.Department) {
+			return false
+		}
+	}
+	for _, rule := range e.policy.Rules {
+		if !rule.appliesTo(subject) {
+			continue
+		}
+		for _, permission := range rule.Permissions {
+			if grants(permission, resource, action) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permissions returns the catalog entries granted to subject, sorted.
+func (e *Engine) Permissions(subject Subject) []string {
+	granted := make([]string, 0)
+	for _, permission := range Catalog {
+		resource, action, _ := strings.Cut(permission, ":")
+		if e.Allowed(subject, resource, action) {
+			granted = append(granted, permission)
+		}
+	}
+	sort.Strings(granted)
+	return granted
+}
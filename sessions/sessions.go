@@ -0,0 +1,212 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"homeland/models"
+	"homeland/utils"
+
+	"github.com/uptrace/bun"
+)
+
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+const (
+	ReasonLogout        = "logout"
+	ReasonLogoutAll     = "logout_all"
+	ReasonTokenReuse    = "refresh_token_reuse"
+	ReasonAdminRevoke   = "admin_revoked"
+	ReasonPasswordReset = "password_reset"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid or expired refresh token")
+	ErrTokenReused    = errors.New("refresh token reuse detected")
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// Create opens a session for the staff member and returns it with its first
+// refresh token.
+func Create(ctx context.Context, db *bun.DB, staffID int64, userAgent, ipAddress string) (*models.Session, string, error) {
+	session := &models.Session{
+		StaffID:   staffID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+
+	var refreshToken string
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(session).Exec(ctx); err != nil {
+			return err
+		}
+
+		var err error
+		refreshToken, err = issueToken(ctx, tx, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+func issueToken(ctx context.Context, tx bun.Tx, sessionID int64) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	row := &models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+	if _, err := tx.NewInsert().Model(row).Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Rotate exchanges a refresh token for a new one in the same session. Each
+// token is single-use: presenting one that was already exchanged means it
+// leaked, so the whole session is revoked and ErrTokenReused returned.
+func Rotate(ctx context.Context, db *bun.DB, refreshToken string) (*models.Session, string, error) {
+	var session models.Session
+	var next string
+	var reused bool
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var token models.RefreshToken
+		err := tx.NewSelect().
+			Model(&token).
+			Where("token_hash = ?", utils.HashToken(refreshToken)).
+			For("UPDATE").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.NewSelect().
+			Model(&session).
+			Where("id = ?", token.SessionID).
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !session.RevokedAt.IsZero() {
+			return ErrSessionRevoked
+		}
+
+		if !token.UsedAt.IsZero() {
+			reused = true
+			return revoke(ctx, tx, &session, ReasonTokenReuse)
+		}
+
+		now := time.Now()
+		if now.After(token.ExpiresAt) || now.After(session.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		token.UsedAt = now
+		if _, err := tx.NewUpdate().Model(&token).Column("used_at").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(RefreshTokenTTL)
+		if _, err := tx.NewUpdate().Model(&session).Column("last_used_at", "expires_at").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+
+		next, err = issueToken(ctx, tx, session.ID)
+		return err
+	})
+
+	if reused && err == nil {
+		return nil, "", ErrTokenReused
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &session, next, nil
+}
+
+func revoke(ctx context.Context, db bun.IDB, session *models.Session, reason string) error {
+	session.RevokedAt = time.Now()
+	session.RevokedReason = reason
+	_, err := db.NewUpdate().
+		Model(session).
+		Column("revoked_at", "revoked_reason").
+		WherePK().
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	return err
+}
+
+// Revoke ends one session belonging to staffID. It reports false when no such
+// active session exists.
+func Revoke(ctx context.Context, db *bun.DB, staffID, sessionID int64, reason string) (bool, error) {
+	res, err := db.NewUpdate().
+		Model((*models.Session)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Set("revoked_reason = ?", reason).
+		Where("id = ?", sessionID).
+		Where("staff_id = ?", staffID).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// RevokeAll ends every active session of staffID and returns how many were
+// revoked.
+func RevokeAll(ctx context.Context, db bun.IDB, staffID int64, reason string) (int64, error) {
+	res, err := db.NewUpdate().
+		Model((*models.Session)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Set("revoked_reason = ?", reason).
+		Where("staff_id = ?", staffID).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func ListActive(ctx context.Context, db *bun.DB, staffID int64) ([]models.Session, error) {
+	var list []models.Session
+	err := db.NewSelect().
+		Model(&list).
+		Where("staff_id = ?", staffID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Order("last_used_at DESC").
+		Scan(ctx)
+	return list, err
+}
+
+// IsActive reports whether the session exists, is unrevoked and unexpired.
+func IsActive(ctx context.Context, db *bun.DB, sessionID int64) (bool, error) {
+	return db.NewSelect().
+		Model((*models.Session)(nil)).
+		Where("id = ?", sessionID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Exists(ctx)
+}
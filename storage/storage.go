@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Object is an open stored file. It is seekable so downloads can honour HTTP
+// Range requests.
+type Object interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+// Backend stores opaque objects by key. Keys are slash-separated and chosen by
+// the caller. Implementations must be safe for concurrent use; an
+// S3-compatible backend maps Put/Open/Delete onto PutObject, ranged GetObject
+// and DeleteObject.
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -10,33 +10,25 @@ import (
 
 type contextKey string
 
+const AccessTokenTTL = 15 * time.Minute
+
 type Claims struct {
-	UserID     int64  `json:"user_id"`
-	Email      string `json:"email"`
-	Role       string `json:"role"`
-	Department string `json:"department"`
+	UserID             int64  `json:"user_id"`
+	Email              string `json:"email"`
+	Role               string `json:"role"`
+	Department         string `json:"department"`
+	Position           string `json:"position"`
+	SessionID          int64  `json:"sid"`
+	MustChangePassword bool   `json:"mcp,omitempty"`
+	MFAEnrollment      bool   `json:"mfa_enroll,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int64, email, role, secret string) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
-func GenerateRefreshToken(userID int64, secret string) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Subject:   string(userID),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+// GenerateToken signs a short-lived access token for the identity in claims.
+// Registered claims are set here and any supplied values are overwritten.
+func GenerateToken(claims Claims, secret string) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
@@ -46,6 +38,9 @@ func GenerateRefreshToken(userID int64, secret string) (string, error) {
 
 func ValidateToken(tokenStr, secret string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
 		return []byte(secret), nil
 	})
 	if err != nil {
@@ -54,23 +49,7 @@ func ValidateToken(tokenStr, secret string) (*Claims, error) {
 
 	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return nil, err
-	}
-	return claims, nil
-}
-
-// ValidateRefreshToken checks if a refresh token is valid
-func ValidateRefreshToken(tokenStr, secret string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok || !token.Valid {
-		return nil, err
+		return nil, fmt.Errorf("invalid token")
 	}
 	return claims, nil
 }
@@ -1,29 +1,41 @@
 package utils
 
 import (
+	"crypto/rand"
 	"fmt"
-	"math/rand"
+	"math/big"
 	"os"
-	"time"
 )
 
 const defaultCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*"
 
-func GenerateTempPassword() string {
-	length := 12
+const minTempPasswordLength = 12
+
+// GenerateTempPassword returns a random password drawn from crypto/rand. The
+// length and alphabet can be overridden with TEMP_PASSWORD_LENGTH and
+// TEMP_PASSWORD_CHARSET; lengths below 12 are raised to 12.
+func GenerateTempPassword() (string, error) {
+	length := minTempPasswordLength
 	if envLength := os.Getenv("TEMP_PASSWORD_LENGTH"); envLength != "" {
 		fmt.Sscanf(envLength, "%d", &length)
 	}
+	if length < minTempPasswordLength {
+		length = minTempPasswordLength
+	}
 
 	charset := os.Getenv("TEMP_PASSWORD_CHARSET")
 	if charset == "" {
 		charset = defaultCharset
 	}
 
-	rand.Seed(time.Now().UnixNano())
+	max := big.NewInt(int64(len(charset)))
 	password := make([]byte, length)
 	for i := range password {
-		password[i] = charset[rand.Intn(len(charset))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = charset[n.Int64()]
 	}
-	return string(password)
+	return string(password), nil
 }
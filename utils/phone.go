@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultCountryCode is assumed for numbers dialled in national format.
+const DefaultCountryCode = "234"
+
+var ErrInvalidPhoneNumber = errors.New("invalid phone number")
+
+// NormalizePhoneNumber reduces the ways a caller's number is written
+// ("0803 123 4567", "+234-803-123-4567", "2348031234567") to one E.164 form.
+// Short codes and numbers in unrecognised formats keep their digits only.
+func NormalizePhoneNumber(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	international := strings.HasPrefix(raw, "+")
+
+	var digits strings.Builder
+	for _, c := range raw {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == '+' || c == ' ' || c == '-' || c == '(' || c == ')' || c == '.':
+		default:
+			return "", ErrInvalidPhoneNumber
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case len(number) < 3 || len(number) > 15:
+		return "", ErrInvalidPhoneNumber
+	case international:
+		return "+" + number, nil
+	case strings.HasPrefix(number, "00"):
+		return "+" + number[2:], nil
+	case strings.HasPrefix(number, DefaultCountryCode) && len(number) == len(DefaultCountryCode)+10:
+		return "+" + number, nil
+	case strings.HasPrefix(number, "0") && len(number) == 11:
+		return "+" + DefaultCountryCode + number[1:], nil
+	case len(number) == 10:
+		return "+" + DefaultCountryCode + number, nil
+	default:
+		return number, nil
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
 func RespondWithError(w http.ResponseWriter, code int, message string) {
@@ -20,3 +21,23 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// RespondWithGeoJSON writes a GeoJSON document with its registered media type.
+func RespondWithGeoJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// WantsGeoJSON reports whether the client asked for GeoJSON, through
+// ?format=geojson or the Accept header.
+func WantsGeoJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "geojson" ||
+		strings.Contains(r.Header.Get("Accept"), "application/geo+json")
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+)
+
+// badgeCharset leaves out characters that are easily misread on a printed
+// badge, such as 0/O and 1/I.
+const badgeCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateOpaqueToken returns a URL-safe random token carrying 256 bits of
+// entropy. Only its HashToken digest should ever be stored.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateBadgeCode returns a short random visitor badge code such as
+// "V-7KQ2MX9P".
+func GenerateBadgeCode() (string, error) {
+	max := big.NewInt(int64(len(badgeCharset)))
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = badgeCharset[n.Int64()]
+	}
+	return "V-" + string(code), nil
+}